@@ -0,0 +1,66 @@
+package eatersim
+
+// AluOp is an operation of the alu, returning the result and the carry flag of
+// the operation on the values of the A and the B register. The zero flag is
+// derived from the result.
+type AluOp func(a, b byte) (result byte, carry bool)
+
+// The operations of the alu. The logic operations clear the carry flag, the
+// shifts shift the A register by one bit and set the carry flag to the bit
+// shifted out.
+var (
+	AluAdd AluOp = func(a, b byte) (byte, bool) { return a + b, int(a)+int(b) > 0xff }
+	AluAnd AluOp = func(a, b byte) (byte, bool) { return a & b, false }
+	AluOr  AluOp = func(a, b byte) (byte, bool) { return a | b, false }
+	AluXor AluOp = func(a, b byte) (byte, bool) { return a ^ b, false }
+	AluShl AluOp = func(a, b byte) (byte, bool) { return a << 1, a&0x80 != 0 }
+	AluShr AluOp = func(a, b byte) (byte, bool) { return a >> 1, a&0x01 != 0 }
+)
+
+// Selector returns the selector of the operation, built from the operation
+// select lines SU and Select.
+func (a *Alu) Selector() int {
+	sel := 0
+	if ptbool(a.SU) {
+		sel = 1
+	}
+	for i, line := range a.Select {
+		if ptbool(line) {
+			sel |= 2 << uint(i)
+		}
+	}
+	return sel
+}
+
+// Op returns the operation selected by sel: the operation registered for sel,
+// or else the built-in subtraction if bit 0, SU, is set and the built-in
+// addition otherwise.
+func (a *Alu) Op(sel int) AluOp {
+	if op, ok := a.Ops[sel]; ok {
+		return op
+	}
+	if sel&1 != 0 {
+		return a.sub
+	}
+	return AluAdd
+}
+
+// SetOp registers op as the operation selected by sel, e.g. to add logic
+// operations selected by additional select lines. A nil op removes the
+// operation registered for sel.
+func (a *Alu) SetOp(sel int, op AluOp) {
+	if op == nil {
+		delete(a.Ops, sel)
+		return
+	}
+	if a.Ops == nil {
+		a.Ops = make(map[int]AluOp)
+	}
+	a.Ops[sel] = op
+}
+
+// sub is the built-in subtraction, setting the carry flag according to
+// SubCarry.
+func (a *Alu) sub(x, y byte) (byte, bool) {
+	return x - y, a.SubCarry.subCarry(x, y)
+}
@@ -19,9 +19,17 @@
 //    * JZ  regaddr - Jump on zero to instruction in memory address 'regaddr'
 //    * OUT         - Output A register to Output register
 //    * HLT         - Halt the execution
-//  - support for .org and .byte directives.
+//  - support for the instructions of the extended instruction set, see .lang.
+//    * TAB         - Copy A register to B register
+//    * TBA         - Copy B register to A register
+//    * SWP         - Exchange A register and B register
+//    * LDX value   - Load the 8-bit value in the byte following the instruction to A register
+//    * ADX value   - Add the 8-bit value in the byte following the instruction to A register
+//  - support for .org, .byte and .lang directives.
 //    * .org  - instruct the assembler to move to register address passed as parameter.
-//    * .byte - instruct the assembler to store raw value to register.
+//    * .byte - instruct the assembler to store raw values to register, a list
+//              of numbers, characters and strings separated by commas.
+//    * .lang - select the language level, classic or extended, see Level.
 //  - support for symbols and labels which may be passed as parameters by name to instructions.
 //    * symbol=value
 //    * label:
@@ -31,6 +39,10 @@
 //    * 15 is decimal representation of 15
 //    * $0f is hexadecimal representation of 15
 //    * %1111 is binary representation of 15
+//  - character and string literals in single or double quotes.
+//    * 'H' is the character code of H, usable as operand or symbol value
+//    * "Hi\n" stores one byte per character in .byte
+//    * the escape sequences \n, \r, \t, \0, \\, \', \" and \xNN, see Unquote
 //
 //
 // Instructions and dot directives must be preceeded by a whitespace character.
@@ -38,11 +50,13 @@
 // Symbol names and label names may contain any graphic unicode character as
 // defined by go's unicode.IsGraphic(), except reserved characters '$', '%', '#', '.', ';' and '='.
 // Instructions and directives are not case sensitive. Symbols and labels are.
+//
+// Source files may use "\n", "\r\n" or "\r" line endings and may start with a
+// UTF-8 byte order mark.
 
 package assembler
 
 import (
-	"errors"
 	"fmt"
 	"io"
 	"strconv"
@@ -51,14 +65,6 @@ import (
 )
 
 const (
-	dotOrg  = 0x01
-	dotByte = 0x02
-
-	label  = 0x09
-	symbol = 0x0a
-
-	noCode = 0xff
-
 	nop = 0x00
 	lda = 0x10
 	add = 0x20
@@ -68,387 +74,347 @@ const (
 	jmp = 0x60
 	jc  = 0x70
 	jz  = 0x80
-
+	tab = 0x90
+	tba = 0xa0
+	swp = 0xb0
+	ldx = 0xc0
+	adx = 0xd0
 	out = 0xe0
 	hlt = 0xf0
 )
 
-type codeline struct {
-	instr byte
-	value byte
+type instrDef struct {
+	code    byte
+	operand bool
+	desc    string
+
+	// level is the lowest language level providing the instruction
+	level Level
+
+	// wide instructions take their operand from the byte following them,
+	// the four least significant bits of the instruction are unused
+	wide bool
+}
+
+// instructions maps the lower case mnemonics to their instruction definition
+var instructions = map[string]instrDef{
+	"nop": {nop, false, "No operation", LevelClassic, false},
+	"lda": {lda, true, "Load value from memory address 'regaddr' to A register", LevelClassic, false},
+	"add": {add, true, "Add value from memory address 'regaddr' to current value in A register", LevelClassic, false},
+	"sub": {sub, true, "Subtract value from memory address 'regaddr' to current value in A register", LevelClassic, false},
+	"sta": {sta, true, "Store value from A register to memory address 'regaddr'", LevelClassic, false},
+	"ldi": {ldi, true, "Load value to A register", LevelClassic, false},
+	"jmp": {jmp, true, "Jump to instruction in memory address 'regaddr'", LevelClassic, false},
+	"jc":  {jc, true, "Jump on carry to instruction in memory address 'regaddr'", LevelClassic, false},
+	"jz":  {jz, true, "Jump on zero to instruction in memory address 'regaddr'", LevelClassic, false},
+	"tab": {tab, false, "Copy A register to B register", LevelExtended, false},
+	"tba": {tba, false, "Copy B register to A register", LevelExtended, false},
+	"swp": {swp, false, "Exchange A register and B register, the flags are unchanged", LevelExtended, false},
+	"ldx": {ldx, true, "Load the value in the next byte to A register", LevelExtended, true},
+	"adx": {adx, true, "Add the value in the next byte to current value in A register", LevelExtended, true},
+	"out": {out, false, "Output A register to Output register", LevelClassic, false},
+	"hlt": {hlt, false, "Halt the execution", LevelClassic, false},
+}
 
-	label string
+// directives maps the lower case dot directives to their description
+var directives = map[string]string{
+	".org":  "Move to the register address passed as parameter",
+	".byte": "Store raw value to register",
+	".lang": "Select the language level: classic or extended",
 }
 
+// DefaultMaxSourceSize is the largest amount of source code in bytes
+// AssembleFrom will read before giving up.
+const DefaultMaxSourceSize = 1 << 20
+
 // AssembleFrom reads assembly code from reader r and returns the assembled
 // binary as a byte slice. If errors are encountered, an empty byte slice will
-// be returned, together with the error.
+// be returned, together with the error. Sources larger than
+// DefaultMaxSourceSize are rejected.
 func AssembleFrom(r io.Reader) ([]byte, error) {
-	src := make([]byte, 2048, 2048)
+	return AssembleFromLimit(r, DefaultMaxSourceSize)
+}
 
-	var e error
-	var n int
-	for e != io.EOF {
-		var i int
-		i, e = r.Read(src[n:cap(src)])
-		n += i
-		if i == 0 && e != io.EOF {
-			b := make([]byte, n+2048)
-			copy(b, src)
-			src = b[:cap(b)]
-		}
+// AssembleFromLimit works like AssembleFrom, but rejects sources larger than
+// limit bytes. A limit less than or equal to zero disables the check.
+func AssembleFromLimit(r io.Reader, limit int64) ([]byte, error) {
+	src, err := readSource(r, limit)
+	if err != nil {
+		return nil, err
+	}
 
+	// name the source in errors when reading from a file
+	var name string
+	if f, ok := r.(interface{ Name() string }); ok {
+		name = f.Name()
 	}
-	bin, e := Assemble(string(src[:n]))
+	prog, err := ParseFile(name, string(src))
+	if err != nil {
+		return nil, err
+	}
+	return AssembleProgram(prog)
+}
 
-	return bin, e
+// readSource reads all of r, failing if more than limit bytes are available.
+func readSource(r io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		src, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("error reading source: %s", err)
+		}
+		return src, nil
+	}
+
+	src, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading source: %s", err)
+	}
+	if int64(len(src)) > limit {
+		return nil, fmt.Errorf("source exceeds size limit of %v bytes", limit)
+	}
+	return src, nil
 }
 
 // Assemble parses assembly code passed as src and returns the assembled
 // binary as a byte slice. If errors are encountered, an empty byte slice will
 // be returned, together with the error.
 func Assemble(src string) ([]byte, error) {
-	cls, err := decode(src)
+	prog, err := Parse(src)
 	if err != nil {
 		return nil, err
 	}
-	bin, err := assemble(cls)
+	return AssembleProgram(prog)
+}
+
+// AssembleProgram assembles the syntax tree of a program as returned by Parse
+// and returns the assembled binary as a byte slice. If errors are encountered,
+// an empty byte slice will be returned, together with the error.
+func AssembleProgram(prog *Program) ([]byte, error) {
+	obj, err := AssembleObject(prog)
 	if err != nil {
 		return nil, err
 	}
-	return bin, nil
+	return obj.Bin, nil
 }
 
-func assemble(cls []codeline) ([]byte, error) {
-	labels, e := mapLabels(cls)
-	if e != nil {
-		return nil, e
+// Object holds the assembled binary of a program together with the
+// information tools need to relate the binary to the source code.
+type Object struct {
+	// Bin is the assembled binary
+	Bin []byte
+
+	// Symbols holds the values of all labels and symbols
+	Symbols map[string]byte
+
+	// Labels holds the names of the labels in the order they are defined
+	Labels []string
+
+	// Owner holds the statement that produced the byte at each address, or nil
+	// for addresses left unused by the program
+	Owner []Stmt
+
+	// SourceMap relates each address to the source code
+	SourceMap SourceMap
+
+	// Level is the language level the program was assembled for
+	Level Level
+}
+
+// AssembleObject assembles the syntax tree of a program as returned by Parse
+// and returns the binary together with the symbol table and source map.
+func AssembleObject(prog *Program) (*Object, error) {
+	return AssembleObjectOptions(prog, Options{})
+}
+
+func assembleObject(prog *Program, level Level) (*Object, error) {
+	labels, err := mapLabels(prog)
+	if err != nil {
+		return nil, withFile(err, prog.File)
 	}
 
 	var raddr int
-	bin := make([]byte, 16)
-	used := make([]bool, 16)
-	for i := range cls {
-		// must add check for raddr out of bounds (panic) and overwriting of already
-		// written register
-		e = cls[i].assembleLn(bin, &raddr, used, labels)
-		if e != nil {
-			return nil, e
+	bin := make([]byte, level.MemorySize())
+	owner := make([]Stmt, level.MemorySize())
+	for _, s := range prog.Stmts {
+		err = assembleStmt(s, bin, &raddr, owner, labels, level)
+		if err != nil {
+			return nil, withFile(err, prog.File)
 		}
 	}
-	return bin, nil
-}
 
-func (cl codeline) assembleLn(reg []byte, raddr *int, used []bool, labels map[string]byte) error {
-	switch cl.instr {
-	case noCode, label, symbol:
-	case nop, out, hlt:
-		if *raddr > 15 {
-			return errors.New("program exceeds registry size of 16 bytes")
+	obj := &Object{Bin: bin, Symbols: labels, Owner: owner, Level: level}
+	for _, s := range prog.Stmts {
+		if l, ok := s.(*LabelDef); ok {
+			obj.Labels = append(obj.Labels, l.Name)
 		}
-		if used[*raddr] {
-			return fmt.Errorf("registry address conflict at address %v, check .org directives", *raddr)
+	}
+	obj.SourceMap = newSourceMap(prog.File, obj)
+	return obj, nil
+}
+
+func assembleStmt(s Stmt, reg []byte, raddr *int, owner []Stmt, labels map[string]byte, level Level) error {
+	switch s := s.(type) {
+	case *Instruction:
+		if err := checkLevel(s, level); err != nil {
+			return err
 		}
-		used[*raddr] = true
-		reg[*raddr] = cl.instr
-		*raddr++
-	case lda, add, sub, sta, ldi, jmp, jc, jz:
-		if *raddr > 15 {
-			return errors.New("program exceeds registry size of 16 bytes")
+		if err := claim(s, *raddr, owner); err != nil {
+			return err
 		}
-		if used[*raddr] {
-			return fmt.Errorf("registry address conflict at address %v, check .org directives", *raddr)
+		if isWide(s) {
+			v, err := evalExpr(s.Operand, labels)
+			if err != nil {
+				return err
+			}
+			if err := claim(s, *raddr+1, owner); err != nil {
+				return err
+			}
+			reg[*raddr], reg[*raddr+1] = s.Opcode, v
+			*raddr += 2
+			return nil
 		}
-		used[*raddr] = true
-		if cl.label == "" {
-			reg[*raddr] = cl.instr | (cl.value & 0x0f)
+		if s.Operand == nil {
+			reg[*raddr] = s.Opcode
 		} else {
-			if _, ok := labels[cl.label]; !ok {
-				return errors.New("Unknown symbol: " + cl.label)
+			v, err := evalExpr(s.Operand, labels)
+			if err != nil {
+				return err
 			}
-			if labels[cl.label] > 0x0f {
-				return fmt.Errorf("symbol %s holds value greater than 15 while used as parameter in instruction.", cl.label)
+			if v > 0x0f {
+				return errorf(s.Operand.Pos(), "symbol %s holds value greater than 15 while used as parameter in instruction.", s.Operand.(*Ident).Name)
 			}
-			reg[*raddr] = cl.instr | (labels[cl.label] & 0x0f)
+			reg[*raddr] = s.Opcode | v
 		}
 		*raddr++
-	case dotOrg:
-		*raddr = int(cl.value)
-	case dotByte:
-		if *raddr > 15 {
-			return errors.New("program exceeds registry size of 16 bytes")
-		}
-		if used[*raddr] {
-			return fmt.Errorf("registry address conflict at address %v, check .org directives", *raddr)
+	case *DotDirective:
+		switch strings.ToLower(s.Name) {
+		case ".org":
+			v, err := evalExpr(s.Args[0], labels)
+			if err != nil {
+				return err
+			}
+			*raddr = int(v)
+		case ".byte":
+			for _, arg := range s.Args {
+				bs, err := byteValues(arg, labels)
+				if err != nil {
+					return err
+				}
+				for _, b := range bs {
+					if err := claim(s, *raddr, owner); err != nil {
+						return err
+					}
+					reg[*raddr] = b
+					*raddr++
+				}
+			}
 		}
-		used[*raddr] = true
-		reg[*raddr] = cl.value
-		*raddr++
 	}
 	return nil
 }
 
-func (cl codeline) mapLabel(raddr *int, labels *map[string]byte) error {
-	switch cl.instr {
-	case label:
-		if _, ok := (*labels)[cl.label]; ok {
-			return errors.New("duplicate label: " + cl.label)
-		}
-		(*labels)[cl.label] = byte(*raddr)
-
-	case symbol:
-		if _, ok := (*labels)[cl.label]; ok {
-			return errors.New("duplicate label: " + cl.label)
-		}
-		(*labels)[cl.label] = cl.value
-
-	case noCode:
-	case nop, lda, add, sub, sta, ldi, jmp, jc, jz, out, hlt:
-		*raddr++
-	case dotOrg:
-		*raddr = int(cl.value)
-	case dotByte:
-		*raddr++
-	}
-	return nil
+// isWide tells whether the instruction s takes its operand from the byte
+// following it.
+func isWide(s *Instruction) bool {
+	return instructions[strings.ToLower(s.Mnemonic)].wide
 }
 
-func mapLabels(cls []codeline) (map[string]byte, error) {
-	var regaddr int
-	labels := make(map[string]byte)
-
-	for i := range cls {
-		e := cls[i].mapLabel(&regaddr, &labels)
-		if e != nil {
-			return nil, e
-		}
+// claim marks raddr as owned by statement s, or returns an error if raddr is
+// out of bounds or already in use.
+func claim(s Stmt, raddr int, owner []Stmt) error {
+	if raddr >= len(owner) {
+		return errorf(s.Pos(), "program exceeds registry size of %d bytes", len(owner))
 	}
-	return labels, nil
-}
-
-func trimcomments(lns string) string {
-	n := strings.IndexRune(lns, ';')
-	if n != -1 {
-		return lns[:n]
+	if owner[raddr] != nil {
+		return errorf(s.Pos(), "registry address conflict at address %v, check .org directives", raddr)
 	}
-	return lns
+	owner[raddr] = s
+	return nil
 }
 
-func decode(src string) ([]codeline, error) {
-	lns := strings.Split(src, "\n")
-
-	var err error
-	cls := make([]codeline, len(lns))
-	cnt := 0
-
-	for i := range lns {
-		cls[cnt], err = decodeln(lns[i])
-		if err != nil {
-			return nil, err
-		}
-		if cls[cnt].instr != noCode {
-			cnt++
+// evalExpr returns the value of e, looking up identifiers in labels.
+func evalExpr(e Expr, labels map[string]byte) (byte, error) {
+	switch e := e.(type) {
+	case *Literal:
+		return e.Value, nil
+	case *Ident:
+		v, ok := labels[e.Name]
+		if !ok {
+			return 0, errorf(e.Pos(), "unknown symbol: %s", e.Name)
 		}
+		return v, nil
 	}
-
-	return cls[:cnt], nil
+	return 0, fmt.Errorf("unsupported expression %T", e)
 }
 
-func decodeln(ln string) (codeline, error) {
-
-	s := trimcomments(ln) // remove comments
-	s = toSingleSpace(s)  // convert all sequences of whitespace characters to a single space
-
-	switch len(s) { // must return if len(s) < 2 to avoid panic
-	case 0:
-		return codeline{instr: noCode}, nil
-
-	case 1:
-		if s[0] != ' ' {
-			return codeline{instr: noCode}, errors.New("error decoding: " + ln)
+func mapLabel(s Stmt, raddr *int, labels map[string]byte) error {
+	switch s := s.(type) {
+	case *LabelDef:
+		if _, ok := labels[s.Name]; ok {
+			return errorf(s.Pos(), "duplicate label: %s", s.Name)
 		}
-		return codeline{instr: noCode}, nil
-	}
-
-	// the following decode*(s) functions might panic if they are passed an
-	// empty string or a string containing only whitespace, however above we have
-	// verified that s is at least two characters long and that there are no
-	// double spaces, so we should be safe.
-	var cl codeline
-	var err error
-
-	switch {
-	case s[0] == ' ' && s[1] == '.':
-		// is dotdirective
-		s = strings.TrimSpace(s)
-		cl, err = decodeDotDir(s)
-
-	case s[0] == ' ' && s[1] != '.':
-		// is instruction
-		s = strings.TrimSpace(s)
-		cl, err = decodeInstr(s)
-
-	case s[0] != ' ':
-		// is symbol or label
-		s = strings.TrimSpace(s)
-		cl, err = decodeSymbol(s)
-	}
-
-	if err != nil {
-		return cl, fmt.Errorf("error decoding: \"%s\": %s", ln, err)
-	}
-	return cl, err
-}
-
-func decodeDotDir(ln string) (codeline, error) {
-	ss := strings.Split(ln, " ")
-	if len(ss) != 2 {
-		return codeline{instr: noCode}, errors.New("incorrect number of parameters")
-	}
-
-	var cl codeline
-	var err error
-
-	switch strings.ToLower(ss[0]) {
-	case ".org":
-		cl.instr = dotOrg
-	case ".byte":
-		cl.instr = dotByte
-	default:
-		cl.instr = noCode
-		return cl, errors.New("unknown dot-directive")
-	}
-
-	cl.value, err = decodeVal(ss[1], 8)
-	if err != nil {
-		cl.instr = noCode
-		cl.value = 0
-	}
-
-	return cl, err
-}
-
-func decodeInstr(ln string) (codeline, error) {
-	ss := strings.Split(ln, " ")
-
-	var cl codeline
-	var err error
-
-	switch strings.ToLower(ss[0]) {
-	case "nop":
-		cl.instr = nop
-	case "lda":
-		cl.instr = lda
-	case "add":
-		cl.instr = add
-	case "sub":
-		cl.instr = sub
-	case "sta":
-		cl.instr = sta
-	case "ldi":
-		cl.instr = ldi
-	case "jmp":
-		cl.instr = jmp
-	case "jc":
-		cl.instr = jc
-	case "jz":
-		cl.instr = jz
-	case "out":
-		cl.instr = out
-	case "hlt":
-		cl.instr = hlt
-	default:
-		cl.instr = noCode
-		err = fmt.Errorf("unknown instruction %s", ss[0])
-		return cl, err
-	}
+		labels[s.Name] = byte(*raddr)
 
-	switch cl.instr {
-	case nop, out, hlt:
-		if len(ss) > 1 {
-			cl.instr = noCode
-			err = fmt.Errorf("unexpected parameters after instruction %s", ss[0])
-			return cl, err
+	case *SymbolDef:
+		if _, ok := labels[s.Name]; ok {
+			return errorf(s.Pos(), "duplicate label: %s", s.Name)
 		}
-	case lda, add, sub, sta, ldi, jmp, jc, jz:
-		if len(ss) != 2 {
-			cl.instr = noCode
-			err = fmt.Errorf("expecting 1 parameter after instruction %s, got %v", ss[0], len(ss)-1)
+		v, err := evalExpr(s.Value, labels)
+		if err != nil {
+			return err
 		}
+		labels[s.Name] = v
 
-		if ss[1][0] == '$' || ss[1][0] == '%' || unicode.IsDigit([]rune(ss[1])[0]) {
-			cl.value, err = decodeVal(ss[1], 4)
+	case *Instruction:
+		*raddr++
+		if isWide(s) {
+			*raddr++
+		}
+	case *DotDirective:
+		switch strings.ToLower(s.Name) {
+		case ".org":
+			v, err := evalExpr(s.Args[0], labels)
 			if err != nil {
-				cl.instr = noCode
-				cl.value = 0
-				return cl, err
+				return err
 			}
-		} else {
-			if r := checkSymbol(ss[1]); r != "" {
-				cl.instr = noCode
-				err = fmt.Errorf("illegal character '%s' in value '%s'", r, ss[1])
-				return cl, err
+			*raddr = int(v)
+		case ".byte":
+			for _, arg := range s.Args {
+				if str, ok := arg.(*StringLit); ok {
+					*raddr += len(str.Value)
+				} else {
+					*raddr++
+				}
 			}
-			cl.label = ss[1]
 		}
-
 	}
-
-	return cl, err
+	return nil
 }
 
-func decodeSymbol(ln string) (codeline, error) {
-	var cl codeline
-	var err error
+// byteValues returns the bytes a value of .byte stores, the bytes of a string
+// or the value of any other expression.
+func byteValues(e Expr, labels map[string]byte) ([]byte, error) {
+	if s, ok := e.(*StringLit); ok {
+		return s.Value, nil
+	}
+	v, err := evalExpr(e, labels)
+	if err != nil {
+		return nil, err
+	}
+	return []byte{v}, nil
+}
 
-	switch {
-	case strings.Contains(ln, "="):
-		// is symbol
-		ss := strings.Split(ln, "=")
-		if len(ss) != 2 {
-			cl = codeline{instr: noCode}
-			err = fmt.Errorf("found more than one equal sign in symbol statement")
-			return cl, err
-		}
-		ss[0] = strings.Trim(ss[0], " ")
-		ss[1] = strings.Trim(ss[1], " ")
+func mapLabels(prog *Program) (map[string]byte, error) {
+	var regaddr int
+	labels := make(map[string]byte)
 
-		if r := checkSymbol(ss[0]); r != "" {
-			cl.instr = noCode
-			err = fmt.Errorf("illegal character '%s' in symbol %s", r, ss[1])
-			return cl, err
-		}
-		cl = codeline{instr: symbol}
-		cl.value, err = decodeVal(ss[1], 8)
+	for _, s := range prog.Stmts {
+		err := mapLabel(s, &regaddr, labels)
 		if err != nil {
-			cl.instr = noCode
-			return cl, err
-		}
-		return cl, err
-	case strings.Contains(ln, ":"):
-		// is label
-		if n := strings.Count(ln, ":"); n != 1 {
-			cl = codeline{instr: noCode}
-			err = fmt.Errorf("found more than one colon in label statement")
-			return cl, err
-		}
-		s := strings.Trim(ln, ": ")
-		if r := checkSymbol(s); r != "" {
-			cl = codeline{instr: noCode}
-			err = fmt.Errorf("illegal character '%s' in label %s", r, s)
-			return cl, err
+			return nil, err
 		}
-		cl = codeline{instr: label}
-		cl.label = s
-		return cl, err
-	default:
-		// is illegal
-		cl = codeline{instr: noCode}
-		err = fmt.Errorf("left justified text must be 'symbol=value' or 'label:', leading whitespace missing?")
-		return cl, err
 	}
-
-	return cl, err
+	return labels, nil
 }
 
 func decodeVal(s string, bitSize int) (byte, error) {
@@ -478,18 +444,45 @@ func checkSymbol(s string) string {
 	return ""
 }
 
-// toSingleSpace converts all sequences of whitespace characters within s into
-// a single space
-func toSingleSpace(s string) string {
-	r := strings.Replace(s, "\t", " ", -1)
-	r = strings.Replace(r, "\n", " ", -1)
-	r = strings.Replace(r, "\v", " ", -1)
-	r = strings.Replace(r, "\f", " ", -1)
-	r = strings.Replace(r, "\r", " ", -1)
-	r = strings.Replace(r, "\u0085", " ", -1) // next line-character
-	r = strings.Replace(r, "\u00a0", " ", -1) // no brake space-character
-	for strings.Contains(r, "  ") {
-		r = strings.Replace(r, "  ", " ", -1)
+// ImageOptions controls the layout of the binary image returned by
+// Object.Image.
+type ImageOptions struct {
+	// Fill is the value stored at addresses not used by the program
+	Fill byte
+
+	// Trim limits the image to the range from the lowest to the highest
+	// address used by the program
+	Trim bool
+}
+
+// Image returns the binary laid out according to opts, together with the
+// memory address of its first byte. Without Trim the image covers the entire
+// memory and the address is 0. A program using no memory at all results in an
+// empty trimmed image.
+func (o *Object) Image(opts ImageOptions) ([]byte, int) {
+	first, last := 0, len(o.Bin)-1
+	if opts.Trim {
+		first, last = len(o.Bin), -1
+		for addr, s := range o.Owner {
+			if s != nil {
+				if addr < first {
+					first = addr
+				}
+				last = addr
+			}
+		}
+		if last < 0 {
+			return []byte{}, 0
+		}
+	}
+
+	img := make([]byte, 0, last-first+1)
+	for addr := first; addr <= last; addr++ {
+		if o.Owner[addr] != nil {
+			img = append(img, o.Bin[addr])
+		} else {
+			img = append(img, opts.Fill)
+		}
 	}
-	return r
+	return img, first
 }
@@ -0,0 +1,114 @@
+package assembler
+
+// Node is implemented by all nodes of the syntax tree returned by Parse.
+type Node interface {
+	// Pos returns the position of the first character of the node
+	Pos() Pos
+}
+
+// Stmt is a single statement of a program, one of *Instruction, *DotDirective,
+// *LabelDef or *SymbolDef.
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Expr is an operand expression, one of *Literal, *Ident or *StringLit.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// Program is the syntax tree of an assembly program.
+type Program struct {
+	// File is the name of the source file, empty if unknown
+	File string
+
+	Stmts []Stmt
+}
+
+// Instruction is a machine instruction with an optional operand, e.g.
+// 'ADD adder'.
+type Instruction struct {
+	MnemonicPos Pos
+	// Mnemonic as written in the source
+	Mnemonic string
+	// Opcode in the four most significant bits
+	Opcode byte
+	// Operand is nil for instructions without operand
+	Operand Expr
+}
+
+// DotDirective is a dot directive with its arguments, e.g. '.org 14'.
+type DotDirective struct {
+	NamePos Pos
+	// Name as written in the source, including the leading dot
+	Name string
+	Args []Expr
+}
+
+// LabelDef defines a label at the current memory address, e.g. 'start:'.
+type LabelDef struct {
+	NamePos Pos
+	Name    string
+}
+
+// SymbolDef defines a symbol with a constant value, e.g. 'ten=10'.
+type SymbolDef struct {
+	NamePos Pos
+	Name    string
+	Value   Expr
+}
+
+// Literal is a numeric literal.
+type Literal struct {
+	ValuePos Pos
+	// Text as written in the source, e.g. '$0f'
+	Text  string
+	Value byte
+}
+
+// StringLit is a string literal in the values of .byte, storing a byte per
+// character. Single characters used as operands are *Literal instead.
+type StringLit struct {
+	ValuePos Pos
+	// Text as written in the source, quotes included, e.g. '"Hi\n"'
+	Text  string
+	Value []byte
+}
+
+// Ident is a reference to a label or symbol.
+type Ident struct {
+	NamePos Pos
+	Name    string
+}
+
+// Pos implements the Node-interface
+func (s *Instruction) Pos() Pos { return s.MnemonicPos }
+
+// Pos implements the Node-interface
+func (s *DotDirective) Pos() Pos { return s.NamePos }
+
+// Pos implements the Node-interface
+func (s *LabelDef) Pos() Pos { return s.NamePos }
+
+// Pos implements the Node-interface
+func (s *SymbolDef) Pos() Pos { return s.NamePos }
+
+// Pos implements the Node-interface
+func (e *Literal) Pos() Pos { return e.ValuePos }
+
+// Pos implements the Node-interface
+func (e *Ident) Pos() Pos { return e.NamePos }
+
+// Pos implements the Node-interface
+func (e *StringLit) Pos() Pos { return e.ValuePos }
+
+func (*Instruction) stmtNode()  {}
+func (*DotDirective) stmtNode() {}
+func (*LabelDef) stmtNode()     {}
+func (*SymbolDef) stmtNode()    {}
+
+func (*Literal) exprNode()   {}
+func (*Ident) exprNode()     {}
+func (*StringLit) exprNode() {}
@@ -0,0 +1,138 @@
+package assembler
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DisasmOptions controls the output of Disassemble.
+type DisasmOptions struct {
+	// Origin is the memory address of the first byte of the binary
+	Origin int
+
+	// Symbols holds the values of labels and symbols to use in place of
+	// numeric addresses, Labels the names of those that are labels. Both may
+	// be nil.
+	Symbols map[string]byte
+	Labels  []string
+
+	// Level is the language level of the binary, selecting the size of the
+	// memory it must fit in. Unless it is LevelDefault the source starts with
+	// a .lang directive selecting it. LevelLarge is refused like by
+	// ParseLevel.
+	Level Level
+}
+
+// Disassemble writes bin to w as annotated assembly source. Every byte is
+// disassembled as an instruction unless its opcode is unknown, in which case it
+// is written as a .byte directive. Instructions taking their operand from the
+// following byte take that byte along. Each line is annotated with the address
+// and the value of the byte, so that data bytes can be told apart by the
+// reader.
+func Disassemble(w io.Writer, bin []byte, opts DisasmOptions) error {
+	if opts.Level == LevelLarge {
+		return fmt.Errorf("language level %s is not supported", opts.Level)
+	}
+	size := opts.Level.MemorySize()
+	if opts.Origin < 0 || opts.Origin+len(bin) > size {
+		return fmt.Errorf("program of %v bytes at origin %v exceeds registry size of %v bytes", len(bin), opts.Origin, size)
+	}
+
+	var b strings.Builder
+	if opts.Level != LevelDefault {
+		fmt.Fprintf(&b, " .lang %s\n\n", opts.Level)
+	}
+
+	// symbols first, then labels at the address they name
+	isLabel := make(map[string]bool)
+	for _, name := range opts.Labels {
+		isLabel[name] = true
+	}
+	labelsAt := make(map[int][]string)
+	for _, name := range sortedNames(opts.Symbols) {
+		v := opts.Symbols[name]
+		if isLabel[name] {
+			labelsAt[int(v)] = append(labelsAt[int(v)], name)
+		} else {
+			fmt.Fprintf(&b, "%s = $%02x\n", name, v)
+		}
+	}
+	if len(opts.Symbols) > len(opts.Labels) {
+		b.WriteByte('\n')
+	}
+
+	if opts.Origin != 0 {
+		fmt.Fprintf(&b, " .org %d\n", opts.Origin)
+	}
+	for i := 0; i < len(bin); i++ {
+		v, addr := bin[i], opts.Origin+i
+		for _, name := range labelsAt[addr] {
+			fmt.Fprintf(&b, "%s:\n", name)
+		}
+		name, _, _ := Decode(v)
+		if def := instructions[strings.ToLower(name)]; def.wide && v&0x0f == 0 && i+1 < len(bin) && len(labelsAt[addr+1]) == 0 {
+			i++
+			fmt.Fprintf(&b, " %s $%02x ; $%x: $%02x %04b %04b %3d, $%02x\n", strings.ToLower(name), bin[i], addr, v, v>>4, v&0x0f, v, bin[i])
+			continue
+		}
+		fmt.Fprintf(&b, " %s ; $%x: $%02x %04b %04b %3d\n", disasmByte(v, opts), addr, v, v>>4, v&0x0f, v)
+	}
+
+	src, err := Format(b.String())
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, src)
+	return err
+}
+
+// disasmByte returns the instruction or .byte directive for v, with address
+// operands replaced by a label or symbol of the same value if there is one.
+func disasmByte(v byte, opts DisasmOptions) string {
+	name, arg, ok := Decode(v)
+	if !ok {
+		return fmt.Sprintf(".byte $%02x", v)
+	}
+	name = strings.ToLower(name)
+	def := instructions[name]
+	if def.wide {
+		// the operand byte is missing
+		return fmt.Sprintf(".byte $%02x", v)
+	}
+	if !def.operand {
+		if arg != 0 {
+			// the operand bits are ignored by the cpu, keep them anyway
+			return fmt.Sprintf(".byte $%02x", v)
+		}
+		return name
+	}
+
+	operand := fmt.Sprintf("%d", arg)
+	if def.code != ldi {
+		if sym, ok := symbolFor(arg, opts); ok {
+			operand = sym
+		}
+	}
+	return name + " " + operand
+}
+
+// symbolFor returns the first label, or otherwise the first symbol, with value
+// v in alphabetical order.
+func symbolFor(v byte, opts DisasmOptions) (string, bool) {
+	var sym string
+	for _, name := range sortedNames(opts.Symbols) {
+		if opts.Symbols[name] != v {
+			continue
+		}
+		for _, l := range opts.Labels {
+			if l == name {
+				return name, true
+			}
+		}
+		if sym == "" {
+			sym = name
+		}
+	}
+	return sym, sym != ""
+}
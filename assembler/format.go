@@ -0,0 +1,138 @@
+package assembler
+
+import (
+	"strconv"
+	"strings"
+)
+
+// indent is the indentation of instructions and dot directives in formatted
+// source code.
+const indent = "  "
+
+// Format returns src rewritten in the canonical style: instructions and
+// directives indented by two spaces, operands aligned, trailing comments
+// aligned, mnemonics and directives in lower case, numbers without redundant
+// leading zeros and hexadecimal digits in lower case, and at most one
+// consecutive blank line. Format returns an error if src does not parse.
+func Format(src string) (string, error) {
+	if _, err := Parse(src); err != nil {
+		return "", err
+	}
+
+	type fmtLine struct {
+		code    string
+		comment string
+	}
+
+	var lns []fmtLine
+	width := 0 // widest code part of lines with trailing comments
+	for n, ln := range splitLines(src) {
+		var fl fmtLine
+		toks := tokenizeLine(ln.text, n+1, ln.offset)
+		if len(toks) > 0 && toks[len(toks)-1].Kind == Comment {
+			fl.comment = strings.TrimRightFunc(toks[len(toks)-1].Text, isSpace)
+			toks = toks[:len(toks)-1]
+		}
+
+		switch {
+		case len(toks) == 0 && fl.comment != "" && len(ln.text) > 0 && isSpace(firstRune(ln.text)):
+			// indented comment line
+			fl.code = indent
+		case len(toks) == 0:
+		case toks[0].Kind == Label:
+			fl.code = toks[0].Text + ":"
+		case toks[0].Kind == Mnemonic || toks[0].Kind == Directive:
+			fl.code = indent + strings.ToLower(toks[0].Text)
+			if len(toks) > 1 {
+				var ops []string
+				for _, t := range toks[1:] {
+					if t.Kind != Comma {
+						ops = append(ops, formatOperand(t))
+					}
+				}
+				fl.code = indent + padRight(strings.ToLower(toks[0].Text), 4) + " " + strings.Join(ops, ", ")
+			}
+		default:
+			// symbol definition
+			fl.code = toks[0].Text + " = " + formatOperand(toks[2])
+		}
+
+		if fl.code != "" && fl.comment != "" && len(fl.code) > width {
+			width = len(fl.code)
+		}
+		lns = append(lns, fl)
+	}
+
+	var b strings.Builder
+	blank := true // suppress blank lines at the start
+	for _, fl := range lns {
+		switch {
+		case fl.code == "" && fl.comment == "":
+			if blank {
+				continue
+			}
+			blank = true
+		case fl.code == "" || fl.code == indent:
+			b.WriteString(fl.code + fl.comment)
+			blank = false
+		case fl.comment != "":
+			b.WriteString(padRight(fl.code, width) + " " + fl.comment)
+			blank = false
+		default:
+			b.WriteString(fl.code)
+			blank = false
+		}
+		b.WriteByte('\n')
+	}
+
+	return strings.TrimRight(b.String(), "\n") + "\n", nil
+}
+
+// formatOperand returns the canonical form of an operand token.
+func formatOperand(t Token) string {
+	if t.Kind != Number {
+		return t.Text
+	}
+
+	var prefix string
+	s := t.Text
+	base := 10
+	switch s[0] {
+	case '$':
+		prefix, s, base = "$", s[1:], 16
+	case '%':
+		prefix, s, base = "%", s[1:], 2
+	}
+
+	v, err := strconv.ParseUint(s, base, 8)
+	if err != nil {
+		return t.Text
+	}
+
+	switch base {
+	case 16:
+		return prefix + leftPad(strconv.FormatUint(v, base), len(s), 2)
+	case 2:
+		return prefix + leftPad(strconv.FormatUint(v, base), len(s), 8)
+	}
+	return strconv.FormatUint(v, base)
+}
+
+// leftPad pads s with zeros to the width of the original digits n, but not
+// wider than max.
+func leftPad(s string, n, max int) string {
+	if n > max {
+		n = max
+	}
+	for len(s) < n {
+		s = "0" + s
+	}
+	return s
+}
+
+func padRight(s string, n int) string {
+	for len(s) < n {
+		s += " "
+	}
+	return s
+}
@@ -0,0 +1,99 @@
+package assembler
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteIntelHex writes bin to w in the Intel HEX format, with the first byte
+// of bin at address base.
+func WriteIntelHex(w io.Writer, bin []byte, base int) error {
+	bw := bufio.NewWriter(w)
+	for i := 0; i < len(bin); i += 16 {
+		n := len(bin) - i
+		if n > 16 {
+			n = 16
+		}
+		writeHexRecord(bw, base+i, 0x00, bin[i:i+n])
+	}
+	writeHexRecord(bw, 0, 0x01, nil)
+	return bw.Flush()
+}
+
+func writeHexRecord(w io.Writer, addr int, typ byte, data []byte) {
+	rec := []byte{byte(len(data)), byte(addr >> 8), byte(addr), typ}
+	rec = append(rec, data...)
+	var sum byte
+	for _, b := range rec {
+		sum += b
+	}
+	rec = append(rec, -sum)
+	fmt.Fprintf(w, ":%s\n", strings.ToUpper(hex.EncodeToString(rec)))
+}
+
+// ReadIntelHex reads a program in the Intel HEX format from r. It returns the
+// bytes from the lowest to the highest address in the file and the address of
+// the first byte. Addresses in between not covered by the file are zero.
+func ReadIntelHex(r io.Reader) ([]byte, int, error) {
+	mem := make(map[int]byte)
+	first, last := -1, -1
+
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		ln := strings.TrimSpace(sc.Text())
+		if ln == "" {
+			continue
+		}
+		if ln[0] != ':' {
+			return nil, 0, fmt.Errorf("line %d: missing start code", n)
+		}
+		rec, err := hex.DecodeString(ln[1:])
+		if err != nil || len(rec) < 5 || len(rec) != int(rec[0])+5 {
+			return nil, 0, fmt.Errorf("line %d: malformed record", n)
+		}
+		var sum byte
+		for _, b := range rec {
+			sum += b
+		}
+		if sum != 0 {
+			return nil, 0, fmt.Errorf("line %d: checksum mismatch", n)
+		}
+
+		addr := int(rec[1])<<8 | int(rec[2])
+		switch rec[3] {
+		case 0x00:
+			for i, b := range rec[4 : len(rec)-1] {
+				mem[addr+i] = b
+				if first < 0 || addr+i < first {
+					first = addr + i
+				}
+				if addr+i > last {
+					last = addr + i
+				}
+			}
+		case 0x01:
+			if first < 0 {
+				return []byte{}, 0, nil
+			}
+			return hexImage(mem, first, last), first, nil
+		default:
+			return nil, 0, fmt.Errorf("line %d: unsupported record type %02x", n, rec[3])
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, 0, err
+	}
+	return nil, 0, errors.New("missing end of file record")
+}
+
+func hexImage(mem map[int]byte, first, last int) []byte {
+	bin := make([]byte, last-first+1)
+	for addr, b := range mem {
+		bin[addr-first] = b
+	}
+	return bin
+}
@@ -0,0 +1,109 @@
+package assembler
+
+import "strings"
+
+// SpanClass is the semantic class of a span of source code, as used for
+// syntax highlighting.
+type SpanClass int
+
+// The span classes returned by Classify.
+const (
+	ClassInstruction SpanClass = iota
+	ClassDirective
+	ClassNumber
+	ClassString
+	ClassLabelDef
+	ClassLabelRef
+	ClassSymbolDef
+	ClassSymbolRef
+	ClassComment
+	ClassPunctuation
+	ClassInvalid
+)
+
+var spanClassNames = [...]string{
+	ClassInstruction: "instruction",
+	ClassDirective:   "directive",
+	ClassNumber:      "number",
+	ClassString:      "string",
+	ClassLabelDef:    "label-def",
+	ClassLabelRef:    "label-ref",
+	ClassSymbolDef:   "symbol-def",
+	ClassSymbolRef:   "symbol-ref",
+	ClassComment:     "comment",
+	ClassPunctuation: "punctuation",
+	ClassInvalid:     "invalid",
+}
+
+// Implements the Stringer-interface
+func (c SpanClass) String() string {
+	if c < 0 || int(c) >= len(spanClassNames) {
+		return "invalid"
+	}
+	return spanClassNames[c]
+}
+
+// Span is a classified range of source code.
+type Span struct {
+	Class SpanClass
+	Token Token
+}
+
+// Classify returns the semantic class of every token in src. References to
+// labels and symbols are told apart by looking up their definitions, unknown
+// instructions and directives and references to undefined names are classified
+// as invalid. Like Tokenize, Classify never fails.
+func Classify(src string) []Span {
+	toks := Tokenize(src)
+
+	// collect definitions first, labels may be referenced before they are
+	// defined
+	defs := make(map[string]SpanClass)
+	for i, t := range toks {
+		switch {
+		case t.Kind == Label:
+			defs[t.Text] = ClassLabelRef
+		case isSymbolDef(toks, i):
+			defs[t.Text] = ClassSymbolRef
+		}
+	}
+
+	spans := make([]Span, len(toks))
+	for i, t := range toks {
+		c := ClassInvalid
+		switch t.Kind {
+		case Mnemonic:
+			if _, ok := instructions[strings.ToLower(t.Text)]; ok {
+				c = ClassInstruction
+			}
+		case Directive:
+			if _, ok := directives[strings.ToLower(t.Text)]; ok {
+				c = ClassDirective
+			}
+		case Number:
+			c = ClassNumber
+		case String:
+			c = ClassString
+		case Label:
+			c = ClassLabelDef
+		case Symbol:
+			if isSymbolDef(toks, i) {
+				c = ClassSymbolDef
+			} else if ref, ok := defs[t.Text]; ok {
+				c = ref
+			}
+		case Comment:
+			c = ClassComment
+		case Equals, Colon, Comma:
+			c = ClassPunctuation
+		}
+		spans[i] = Span{Class: c, Token: t}
+	}
+	return spans
+}
+
+// isSymbolDef reports whether toks[i] is the name in a symbol definition.
+func isSymbolDef(toks []Token, i int) bool {
+	return toks[i].Kind == Symbol && toks[i].Pos.Col == 1 &&
+		i+1 < len(toks) && toks[i+1].Kind == Equals && toks[i+1].Pos.Line == toks[i].Pos.Line
+}
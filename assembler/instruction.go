@@ -0,0 +1,114 @@
+package assembler
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// AssembleInstruction assembles a single instruction like 'ADD 14' or
+// 'JMP loop' and returns its byte. Operands naming a label or symbol are
+// looked up in symbols, which may be nil.
+func AssembleInstruction(s string, symbols map[string]byte) (byte, error) {
+	// indent the instruction like it would be in a source file, and move the
+	// token positions back to refer to s
+	toks := tokenizeLine(" "+s, 1, 0)
+	for i := range toks {
+		toks[i].Pos.Offset--
+		toks[i].Pos.Col--
+	}
+
+	st, err := parseLine(toks)
+	if err != nil {
+		return 0, err
+	}
+	in, ok := st.(*Instruction)
+	if !ok {
+		return 0, errors.New("expecting an instruction")
+	}
+	if isWide(in) {
+		return 0, errorf(in.Pos(), "instruction %s takes two bytes", in.Mnemonic)
+	}
+
+	if in.Operand == nil {
+		return in.Opcode, nil
+	}
+	v, err := evalExpr(in.Operand, symbols)
+	if err != nil {
+		return 0, err
+	}
+	if v > 0x0f {
+		return 0, errorf(in.Operand.Pos(), "symbol %s holds value greater than 15 while used as parameter in instruction.", in.Operand.(*Ident).Name)
+	}
+	return in.Opcode | v, nil
+}
+
+// MnemonicOf returns the upper case mnemonic of the instruction in the four most
+// significant bits of b. The boolean is false if the opcode is not a known
+// instruction.
+func MnemonicOf(b byte) (string, bool) {
+	for name, def := range instructions {
+		if def.code == b&0xf0 {
+			return strings.ToUpper(name), true
+		}
+	}
+	return "", false
+}
+
+// Decode splits the byte b as stored in memory or in the instruction register
+// into the upper case mnemonic of its opcode and its operand. isInstruction is
+// false if the opcode is not a known instruction, in which case mnemonic is
+// empty. The operand is the four least significant bits of b, also for
+// instructions taking no operand.
+func Decode(b byte) (mnemonic string, operand byte, isInstruction bool) {
+	mnemonic, isInstruction = MnemonicOf(b)
+	return mnemonic, b & 0x0f, isInstruction
+}
+
+// Opcode returns the opcode of mnemonic in the four most significant bits,
+// and whether the instruction takes an operand in its four least significant
+// bits. The last boolean is false if mnemonic is not a known instruction.
+// Mnemonics are not case sensitive.
+func Opcode(mnemonic string) (code byte, operand bool, ok bool) {
+	def, ok := instructions[strings.ToLower(mnemonic)]
+	return def.code, def.operand && !def.wide, ok
+}
+
+// OperandByte tells whether the instruction mnemonic takes its operand from
+// the byte following it, like LDX. Such instructions take two bytes of memory.
+func OperandByte(mnemonic string) bool {
+	return instructions[strings.ToLower(mnemonic)].wide
+}
+
+// Mnemonics returns the upper case mnemonics of all instructions sorted by
+// opcode.
+func Mnemonics() []string {
+	var names []string
+	for op := 0; op < 0x100; op += 0x10 {
+		if name, ok := MnemonicOf(byte(op)); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Directives returns the names of all dot directives in lower case, sorted.
+func Directives() []string {
+	names := make([]string, 0, len(directives))
+	for name := range directives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Describe returns a short description of an instruction or dot directive,
+// e.g. "Halt the execution" for 'HLT'. Returns an empty string if name is
+// neither.
+func Describe(name string) string {
+	name = strings.ToLower(name)
+	if def, ok := instructions[name]; ok {
+		return def.desc
+	}
+	return directives[name]
+}
@@ -0,0 +1,76 @@
+package assembler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ISA is an instruction set, the opcodes a machine implements, with bit n set
+// if opcode n is implemented. The opcode is the upper nibble of an
+// instruction. A machine executes the opcodes it does not implement like NOP,
+// so that a program using them misbehaves silently; CheckISA finds them
+// before the program runs.
+type ISA uint16
+
+// LevelISA returns the instruction set of the language level l, the opcodes
+// of the instructions available at l. LevelDefault selects LevelExtended.
+func LevelISA(l Level) ISA {
+	if l == LevelDefault {
+		l = LevelExtended
+	}
+	var isa ISA
+	for _, def := range instructions {
+		if def.level <= l {
+			isa |= 1 << (def.code >> 4)
+		}
+	}
+	return isa
+}
+
+// Has returns true if the opcode op, 0 to 15, is in the instruction set.
+func (isa ISA) Has(op byte) bool {
+	return isa&(1<<(op&0x0f)) != 0
+}
+
+// Implements the Stringer-interface. Lists the mnemonics of the opcodes in the
+// instruction set in the order of the opcodes, opcodes without mnemonic as
+// $n.
+func (isa ISA) String() string {
+	var names []string
+	for op := byte(0); op < 0x10; op++ {
+		if !isa.Has(op) {
+			continue
+		}
+		if name, ok := MnemonicOf(op << 4); ok {
+			names = append(names, name)
+		} else {
+			names = append(names, fmt.Sprintf("$%x", op))
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+// CheckISA returns an *Error for each instruction of the program using an
+// opcode not in isa, in the order of their addresses, e.g. for a program
+// assembled for the extended instruction set but run with the microcode of
+// Ben's build.
+func (o *Object) CheckISA(isa ISA) []error {
+	var errs []error
+	var last Stmt
+	for addr, s := range o.Owner {
+		in, ok := s.(*Instruction)
+		if !ok || s == last {
+			continue
+		}
+		last = s
+		code, _, _ := Opcode(in.Mnemonic)
+		if op := code >> 4; !isa.Has(op) {
+			err := errorf(in.Pos(), "instruction %s uses opcode $%x, which the machine does not implement", strings.ToUpper(in.Mnemonic), op)
+			if loc, ok := o.SourceMap.Lookup(addr); ok {
+				err.File = loc.File
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
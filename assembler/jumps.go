@@ -0,0 +1,67 @@
+package assembler
+
+import "strings"
+
+// JumpOK in the comment of a jump instruction suppresses the warning of
+// CheckJumps about its target, e.g. 'jmp table ; jump-ok'.
+const JumpOK = "jump-ok"
+
+// jumps holds the lower case mnemonics of the jump instructions.
+var jumps = map[string]bool{"jmp": true, "jc": true, "jz": true}
+
+// CheckJumps returns a warning for every jump instruction of the program whose
+// target does not hold the start of an instruction: data stored by .byte, an
+// address left unused by the program, or the operand byte of an instruction
+// taking two bytes. Jumping there is almost always a bug. The warnings are
+// *Error values. src is the source code of the program, jumps with JumpOK in
+// their comment are not reported. An empty src reports all jumps.
+func (o *Object) CheckJumps(src string) []error {
+	lines := splitLines(src)
+	var errs []error
+	var last Stmt
+	for addr, s := range o.Owner {
+		in, ok := s.(*Instruction)
+		if !ok || s == last || !jumps[strings.ToLower(in.Mnemonic)] {
+			continue
+		}
+		last = s
+		if n := in.Pos().Line; src != "" && n <= len(lines) && jumpOK(lines[n-1].text, n) {
+			continue
+		}
+
+		target := int(o.Bin[addr] & 0x0f)
+		pos := in.Pos()
+		if in.Operand != nil {
+			pos = in.Operand.Pos()
+		}
+		var err *Error
+		switch t := o.Owner[target].(type) {
+		case nil:
+			err = errorf(pos, "jump to address %v, which the program leaves unused", target)
+		case *DotDirective:
+			err = errorf(pos, "jump into data at address %v, stored on line %d", target, t.Pos().Line)
+		case *Instruction:
+			if target > 0 && o.Owner[target-1] == t {
+				err = errorf(pos, "jump to address %v, the operand of %s on line %d", target, strings.ToUpper(t.Mnemonic), t.Pos().Line)
+			}
+		}
+		if err != nil {
+			if loc, ok := o.SourceMap.Lookup(addr); ok {
+				err.File = loc.File
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// jumpOK tells whether the comment of the source line ln, line number n,
+// holds JumpOK.
+func jumpOK(ln string, n int) bool {
+	for _, t := range tokenizeLine(ln, n, 0) {
+		if t.Kind == Comment && strings.Contains(strings.ToLower(t.Text), JumpOK) {
+			return true
+		}
+	}
+	return false
+}
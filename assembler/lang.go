@@ -0,0 +1,125 @@
+package assembler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Level is a language level, selecting the instructions and the memory size
+// available to a program. A program selects its level with the .lang
+// directive, e.g. '.lang classic', so that it fails to assemble with a clear
+// error instead of being misassembled for a machine lacking a feature it
+// needs.
+type Level int
+
+// The language levels, each including the features of the levels before it.
+const (
+	// LevelDefault selects the level of Options.Level, or LevelExtended
+	LevelDefault Level = iota
+
+	// LevelClassic is the instruction set of Ben's videos and the 16 bytes
+	// of memory of his build
+	LevelClassic
+
+	// LevelExtended adds the instructions TAB, TBA, SWP, LDX and ADX of the
+	// extended instruction set
+	LevelExtended
+
+	// LevelLarge targets a memory of 256 bytes. No machine runs it yet, so
+	// it is not offered by the tools.
+	LevelLarge
+)
+
+var levelNames = [...]string{
+	LevelDefault:  "default",
+	LevelClassic:  "classic",
+	LevelExtended: "extended",
+	LevelLarge:    "large",
+}
+
+// Implements the Stringer-interface
+func (l Level) String() string {
+	if l < 0 || int(l) >= len(levelNames) {
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel returns the language level called name, "classic" or
+// "extended". Names are not case sensitive. LevelLarge is refused until a
+// machine runs it.
+func ParseLevel(name string) (Level, error) {
+	for l, n := range levelNames {
+		if l != int(LevelDefault) && l != int(LevelLarge) && strings.EqualFold(n, name) {
+			return Level(l), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown language level %s, expecting classic or extended", name)
+}
+
+// MemorySize returns the number of bytes of memory a program of level l may
+// use.
+func (l Level) MemorySize() int {
+	if l >= LevelLarge {
+		return 0x100
+	}
+	return 0x10
+}
+
+// Options configures the assembly of a program.
+type Options struct {
+	// Level is the language level of programs without a .lang directive,
+	// LevelExtended if LevelDefault
+	Level Level
+}
+
+// AssembleObjectOptions works like AssembleObject, assembling prog according
+// to opts.
+func AssembleObjectOptions(prog *Program, opts Options) (*Object, error) {
+	level, err := programLevel(prog, opts.Level)
+	if err != nil {
+		return nil, withFile(err, prog.File)
+	}
+	return assembleObject(prog, level)
+}
+
+// programLevel returns the language level selected by the .lang directive of
+// prog, or def if there is none.
+func programLevel(prog *Program, def Level) (Level, error) {
+	if def == LevelDefault {
+		def = LevelExtended
+	}
+	var lang *DotDirective
+	code := false
+	for _, s := range prog.Stmts {
+		switch s := s.(type) {
+		case *Instruction:
+			code = true
+		case *DotDirective:
+			if !strings.EqualFold(s.Name, ".lang") {
+				code = true
+				continue
+			}
+			if lang != nil {
+				return 0, errorf(s.Pos(), "duplicate .lang directive, the language level is selected at %v", lang.Pos())
+			}
+			if code {
+				return 0, errorf(s.Pos(), ".lang directive must precede the code")
+			}
+			lang = s
+		}
+	}
+	if lang == nil {
+		return def, nil
+	}
+	return ParseLevel(lang.Args[0].(*Ident).Name)
+}
+
+// checkLevel returns an error if the instruction s is not available at level.
+func checkLevel(s *Instruction, level Level) error {
+	def := instructions[strings.ToLower(s.Mnemonic)]
+	if def.level > level {
+		return errorf(s.Pos(), "instruction %s needs language level %s, the program is %s, see .lang", s.Mnemonic, def.level, level)
+	}
+	return nil
+}
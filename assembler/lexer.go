@@ -0,0 +1,199 @@
+package assembler
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenKind identifies the type of a token produced by Tokenize.
+type TokenKind int
+
+// The token kinds produced by Tokenize.
+const (
+	// Illegal is text that can not start any valid token
+	Illegal TokenKind = iota
+
+	// Mnemonic is the first word of an indented line not starting with '.'
+	Mnemonic
+
+	// Directive is the first word of an indented line starting with '.'
+	Directive
+
+	// Symbol is a name, either defined by 'symbol=value' or referenced as an
+	// operand
+	Symbol
+
+	// Number is a decimal, $hexadecimal or %binary value
+	Number
+
+	// Label is a label name defined by 'label:'. The colon is a separate token.
+	Label
+
+	// Comment is the text from a semicolon to the end of the line, semicolon
+	// included
+	Comment
+
+	// Equals is the '=' in a symbol definition
+	Equals
+
+	// Colon is the ':' ending a label definition
+	Colon
+
+	// String is a character or string literal in single or double quotes,
+	// e.g. 'H' or "Hi\n", see Unquote
+	String
+
+	// Comma is the ',' separating the values of a list
+	Comma
+)
+
+var tokenKindNames = [...]string{
+	Illegal:   "illegal",
+	Mnemonic:  "mnemonic",
+	Directive: "directive",
+	Symbol:    "symbol",
+	Number:    "number",
+	Label:     "label",
+	Comment:   "comment",
+	Equals:    "equals",
+	Colon:     "colon",
+	String:    "string",
+	Comma:     "comma",
+}
+
+// Implements the Stringer-interface
+func (k TokenKind) String() string {
+	if k < 0 || int(k) >= len(tokenKindNames) {
+		return fmt.Sprintf("TokenKind(%d)", int(k))
+	}
+	return tokenKindNames[k]
+}
+
+// Pos is a position in the source code. Line and Col are 1-based, Col and
+// Offset are counted in bytes.
+type Pos struct {
+	Offset int
+	Line   int
+	Col    int
+}
+
+// Implements the Stringer-interface
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+// Token is a single lexical element of the source code.
+type Token struct {
+	Kind TokenKind
+	Text string
+	Pos  Pos
+}
+
+// End returns the position directly after the last byte of the token.
+func (t Token) End() Pos {
+	return Pos{Offset: t.Pos.Offset + len(t.Text), Line: t.Pos.Line, Col: t.Pos.Col + len(t.Text)}
+}
+
+// Implements the Stringer-interface
+func (t Token) String() string {
+	return fmt.Sprintf("%s %s %q", t.Pos, t.Kind, t.Text)
+}
+
+// Tokenize splits src into tokens. Whitespace is not returned. Tokenize never
+// fails, text it does not understand is returned as Illegal tokens so that
+// tools like editors may work with incomplete or faulty code.
+func Tokenize(src string) []Token {
+	var toks []Token
+
+	for n, ln := range splitLines(src) {
+		toks = append(toks, tokenizeLine(ln.text, n+1, ln.offset)...)
+	}
+	return toks
+}
+
+// tokenizeLine splits a single line of source code into tokens.
+func tokenizeLine(ln string, line, offset int) []Token {
+	var toks []Token
+
+	indented := len(ln) > 0 && isSpace(firstRune(ln))
+	first := true // next word is the first word on the line
+
+	i := 0
+	for i < len(ln) {
+		r, w := utf8.DecodeRuneInString(ln[i:])
+		if isSpace(r) {
+			i += w
+			continue
+		}
+
+		pos := Pos{Offset: offset + i, Line: line, Col: i + 1}
+		tok := Token{Pos: pos}
+
+		switch {
+		case r == ';':
+			tok.Kind, tok.Text = Comment, ln[i:]
+		case r == '=':
+			tok.Kind, tok.Text = Equals, "="
+		case r == ':':
+			tok.Kind, tok.Text = Colon, ":"
+		case r == ',':
+			tok.Kind, tok.Text = Comma, ","
+		case (r == '\'' || r == '"') && !(first && indented):
+			n, _ := quotedLen(ln[i:])
+			tok.Kind, tok.Text = String, ln[i:i+n]
+			if _, err := Unquote(tok.Text); err != nil {
+				tok.Kind = Illegal
+			}
+			first = false
+		default:
+			tok.Text = ln[i : i+wordLen(ln[i:])]
+			switch {
+			case first && indented && r == '.':
+				tok.Kind = Directive
+			case first && indented:
+				tok.Kind = Mnemonic
+			case r == '$' || r == '%' || unicode.IsDigit(r):
+				tok.Kind = Number
+			case first && nextNonSpace(ln[i+len(tok.Text):]) == ':':
+				tok.Kind = Label
+			case checkSymbol(tok.Text) == "":
+				tok.Kind = Symbol
+			default:
+				tok.Kind = Illegal
+			}
+			first = false
+		}
+
+		toks = append(toks, tok)
+		i += len(tok.Text)
+	}
+	return toks
+}
+
+// wordLen returns the length in bytes of the word at the start of s. A word
+// ends at whitespace or at any of the characters ';', '=', ':' and ','.
+func wordLen(s string) int {
+	for i, r := range s {
+		if isSpace(r) || r == ';' || r == '=' || r == ':' || r == ',' {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// nextNonSpace returns the first non whitespace character in s, or 0 if there
+// is none.
+func nextNonSpace(s string) rune {
+	for _, r := range s {
+		if !isSpace(r) {
+			return r
+		}
+	}
+	return 0
+}
+
+func firstRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
@@ -0,0 +1,124 @@
+package assembler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WriteListing writes a classic assembly listing of the object assembled from
+// src to w. Each source line is printed next to the address and the byte it
+// produced, in hexadecimal and in binary as set on the DIP switches. The
+// symbol table is printed at the end. Lines producing several bytes are
+// followed by a line for each further byte.
+func WriteListing(w io.Writer, src string, obj *Object) error {
+	bw := bufio.NewWriter(w)
+
+	// the addresses owned by each line producing bytes
+	lineAddrs := make(map[int][]int)
+	for addr, s := range obj.Owner {
+		if s != nil {
+			lineAddrs[s.Pos().Line] = append(lineAddrs[s.Pos().Line], addr)
+		}
+	}
+
+	fmt.Fprintf(bw, "%-7s  %-13s  %4s  %s\n", "addr", "data", "line", "source")
+	for n, sl := range splitLines(src) {
+		ln := sl.text
+		addrs := lineAddrs[n+1]
+		if len(addrs) == 0 {
+			fmt.Fprintf(bw, "%-7s  %-13s  %4d  %s\n", "", "", n+1, ln)
+			continue
+		}
+		for i, addr := range addrs {
+			b := obj.Bin[addr]
+			if i == 0 {
+				fmt.Fprintf(bw, "$%x %04b  $%02x %04b %04b  %4d  %s\n", addr, addr, b, b>>4, b&0x0f, n+1, ln)
+			} else {
+				fmt.Fprintf(bw, "$%x %04b  $%02x %04b %04b\n", addr, addr, b, b>>4, b&0x0f)
+			}
+		}
+	}
+
+	fmt.Fprintf(bw, "\nsymbols:\n")
+	writeSymbols(bw, obj)
+
+	return bw.Flush()
+}
+
+// WriteSymbols writes the symbol table of obj to w, one symbol per line sorted
+// by value and name.
+func WriteSymbols(w io.Writer, obj *Object) error {
+	bw := bufio.NewWriter(w)
+	writeSymbols(bw, obj)
+	return bw.Flush()
+}
+
+func writeSymbols(w io.Writer, obj *Object) {
+	isLabel := make(map[string]bool)
+	for _, name := range obj.Labels {
+		isLabel[name] = true
+	}
+
+	names := sortedNames(obj.Symbols)
+	width := 0
+	for _, name := range names {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+
+	for _, name := range names {
+		kind := "symbol"
+		if isLabel[name] {
+			kind = "label"
+		}
+		v := obj.Symbols[name]
+		fmt.Fprintf(w, "%-*s $%02x %3d %08b %s\n", width, name, v, v, v, kind)
+	}
+}
+
+// sortedNames returns the names in symbols sorted by value and name.
+func sortedNames(symbols map[string]byte) []string {
+	names := make([]string, 0, len(symbols))
+	for name := range symbols {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		vi, vj := symbols[names[i]], symbols[names[j]]
+		if vi != vj {
+			return vi < vj
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// ReadSymbols reads a symbol table in the format written by WriteSymbols. It
+// returns the values of all labels and symbols, and the names of the labels.
+func ReadSymbols(r io.Reader) (map[string]byte, []string, error) {
+	symbols := make(map[string]byte)
+	var labels []string
+
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		f := strings.Fields(sc.Text())
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) < 2 {
+			return nil, nil, fmt.Errorf("line %d: missing value of symbol %s", n, f[0])
+		}
+		v, err := decodeVal(f[1], 8)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: invalid value %s", n, f[1])
+		}
+		symbols[f[0]] = v
+		if len(f) > 4 && f[4] == "label" {
+			labels = append(labels, f[0])
+		}
+	}
+	return symbols, labels, sc.Err()
+}
@@ -0,0 +1,182 @@
+package assembler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MemoryMap is the layout of a program in memory: the statements placing a
+// byte at each address and the labels pointing to it. Unlike AssembleObject,
+// which fails at the first address claimed twice, it records every claim, so
+// that all conflicts and the free addresses can be reported at once.
+type MemoryMap struct {
+	// File is the name of the source file, empty if unknown
+	File string
+
+	// Owners holds the statements placing a byte at each address, indexed by
+	// address. Addresses with no owner are free, addresses with several
+	// owners are in conflict.
+	Owners [][]Stmt
+
+	// Labels holds the labels pointing to each address, indexed by address
+	Labels [][]string
+
+	// Overflow holds the statements placing bytes beyond the end of the
+	// memory
+	Overflow []Stmt
+}
+
+// NewMemoryMap lays out prog in the memory of its language level, selected as
+// by AssembleObjectOptions. It fails on errors keeping the addresses from
+// being known, e.g. an unknown symbol in a .org directive, but not on
+// conflicts, see Conflicts.
+func NewMemoryMap(prog *Program, opts Options) (*MemoryMap, error) {
+	level, err := programLevel(prog, opts.Level)
+	if err != nil {
+		return nil, withFile(err, prog.File)
+	}
+	labels, err := mapLabels(prog)
+	if err != nil {
+		return nil, withFile(err, prog.File)
+	}
+
+	size := level.MemorySize()
+	m := &MemoryMap{File: prog.File, Owners: make([][]Stmt, size), Labels: make([][]string, size)}
+	var raddr int
+	for _, s := range prog.Stmts {
+		start := raddr
+		switch s := s.(type) {
+		case *LabelDef:
+			if raddr < size {
+				m.Labels[raddr] = append(m.Labels[raddr], s.Name)
+			}
+			continue
+		case *DotDirective:
+			if strings.EqualFold(s.Name, ".org") {
+				if err := mapLabel(s, &raddr, labels); err != nil {
+					return nil, withFile(err, prog.File)
+				}
+				continue
+			}
+		case *Instruction:
+		default:
+			continue
+		}
+		if err := mapLabel(s, &raddr, labels); err != nil {
+			return nil, withFile(err, prog.File)
+		}
+		for addr := start; addr < raddr && addr < size; addr++ {
+			m.Owners[addr] = append(m.Owners[addr], s)
+		}
+		if raddr > size {
+			m.Overflow = append(m.Overflow, s)
+		}
+	}
+	return m, nil
+}
+
+// Used returns the number of addresses owned by the program.
+func (m *MemoryMap) Used() int {
+	n := 0
+	for _, owners := range m.Owners {
+		if len(owners) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// Free returns the number of addresses left unused by the program.
+func (m *MemoryMap) Free() int {
+	return len(m.Owners) - m.Used()
+}
+
+// Conflicts returns an *Error for every address claimed by more than one
+// statement, at the position of each later claim, and for every statement
+// placing bytes beyond the end of the memory, in the order of the addresses.
+// An empty result means the program fits.
+func (m *MemoryMap) Conflicts() []error {
+	var errs []error
+	for addr, owners := range m.Owners {
+		if len(owners) < 2 {
+			continue
+		}
+		for _, s := range owners[1:] {
+			e := errorf(s.Pos(), "registry address conflict at address %v, claimed by line %d as well", addr, owners[0].Pos().Line)
+			e.File = m.File
+			errs = append(errs, e)
+		}
+	}
+	for _, s := range m.Overflow {
+		e := errorf(s.Pos(), "program exceeds registry size of %d bytes", len(m.Owners))
+		e.File = m.File
+		errs = append(errs, e)
+	}
+	return errs
+}
+
+// WriteMemoryMap writes m as a report to w, a line per address with the
+// labels pointing to it and the source lines in src owning it, runs of free
+// addresses collapsed into a line each. Conflicting addresses are marked and
+// followed by a line for each further owner. A summary of the used, free and
+// conflicting addresses ends the report.
+func WriteMemoryMap(w io.Writer, src string, m *MemoryMap) error {
+	bw := bufio.NewWriter(w)
+	lines := splitLines(src)
+	text := func(s Stmt) string {
+		n := s.Pos().Line
+		if n < 1 || n > len(lines) {
+			return ""
+		}
+		return strings.TrimSpace(lines[n-1].text)
+	}
+
+	fmt.Fprintf(bw, "%-9s  %-12s  %4s  %s\n", "addr", "label", "line", "source")
+	conflicts := 0
+	for addr := 0; addr < len(m.Owners); addr++ {
+		label := strings.Join(m.Labels[addr], ",")
+		owners := m.Owners[addr]
+		if len(owners) == 0 && label == "" {
+			end := addr
+			for end+1 < len(m.Owners) && len(m.Owners[end+1]) == 0 && len(m.Labels[end+1]) == 0 {
+				end++
+			}
+			span := fmt.Sprintf("$%x", addr)
+			if end > addr {
+				span = fmt.Sprintf("$%x-$%x", addr, end)
+			}
+			n := fmt.Sprintf("%d bytes", end-addr+1)
+			if end == addr {
+				n = "1 byte"
+			}
+			fmt.Fprintf(bw, "%-9s  %-12s  %4s  free, %s\n", span, "", "", n)
+			addr = end
+			continue
+		}
+		if len(owners) == 0 {
+			fmt.Fprintf(bw, "%-9s  %-12s  %4s  free\n", fmt.Sprintf("$%x", addr), label, "")
+			continue
+		}
+		mark := ""
+		if len(owners) > 1 {
+			mark = "  <- conflict"
+			conflicts++
+		}
+		fmt.Fprintf(bw, "%-9s  %-12s  %4d  %s%s\n", fmt.Sprintf("$%x", addr), label, owners[0].Pos().Line, text(owners[0]), mark)
+		for _, s := range owners[1:] {
+			fmt.Fprintf(bw, "%-9s  %-12s  %4d  %s\n", "", "", s.Pos().Line, text(s))
+		}
+	}
+	for _, s := range m.Overflow {
+		fmt.Fprintf(bw, "%-9s  %-12s  %4d  %s  <- beyond memory\n", "overflow", "", s.Pos().Line, text(s))
+	}
+
+	fmt.Fprintf(bw, "\n%d of %d bytes used, %d free", m.Used(), len(m.Owners), m.Free())
+	if conflicts > 0 {
+		fmt.Fprintf(bw, ", %d in conflict", conflicts)
+	}
+	fmt.Fprintln(bw)
+	return bw.Flush()
+}
@@ -0,0 +1,77 @@
+package assembler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// WriteC writes bin to w as a C/Arduino byte array stored in program memory,
+// ready to be included in a sketch that programs the RAM of the real build.
+// name is the name of the array and must be a valid C identifier.
+func WriteC(w io.Writer, bin []byte, name string) error {
+	if !isCIdent(name) {
+		return fmt.Errorf("invalid C identifier: %q", name)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "#include <avr/pgmspace.h>\n\n")
+	fmt.Fprintf(bw, "const uint8_t %s[%d] PROGMEM = {\n", name, len(bin))
+	for addr, b := range bin {
+		fmt.Fprintf(bw, "  0x%02x, // %04b: %04b %04b\n", b, addr, b>>4, b&0x0f)
+	}
+	fmt.Fprintf(bw, "};\n")
+	return bw.Flush()
+}
+
+func isCIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_', 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case '0' <= r && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// WriteLogisim writes bin to w as a Logisim 'v2.0 raw' memory image, which can
+// be loaded into the RAM and ROM components of Logisim and Digital. Runs of
+// equal bytes are written in the run length notation 'count*value'.
+func WriteLogisim(w io.Writer, bin []byte) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "v2.0 raw\n")
+
+	n := 0 // values on the current line
+	for i := 0; i < len(bin); {
+		run := 1
+		for i+run < len(bin) && bin[i+run] == bin[i] {
+			run++
+		}
+
+		if n > 0 {
+			bw.WriteByte(' ')
+		}
+		if run > 3 {
+			fmt.Fprintf(bw, "%d*%x", run, bin[i])
+			i += run
+		} else {
+			fmt.Fprintf(bw, "%x", bin[i])
+			i++
+		}
+
+		n++
+		if n == 8 {
+			bw.WriteByte('\n')
+			n = 0
+		}
+	}
+	if n > 0 {
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
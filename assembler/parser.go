@@ -0,0 +1,250 @@
+package assembler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Error is an error located at a specific position in the source code.
+type Error struct {
+	File string
+	Pos  Pos
+	Msg  string
+}
+
+// Implements the error-interface
+func (e *Error) Error() string {
+	if e.File != "" {
+		return fmt.Sprintf("%s:%v: %s", e.File, e.Pos, e.Msg)
+	}
+	return fmt.Sprintf("%v: %s", e.Pos, e.Msg)
+}
+
+func errorf(pos Pos, format string, a ...interface{}) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, a...)}
+}
+
+// withFile records filename in err if err is an *Error.
+func withFile(err error, filename string) error {
+	if e, ok := err.(*Error); ok {
+		e.File = filename
+	}
+	return err
+}
+
+// Parse parses the assembly code passed as src and returns its syntax tree.
+// Errors are returned as *Error and the returned program is nil.
+func Parse(src string) (*Program, error) {
+	return ParseFile("", src)
+}
+
+// ParseFile works like Parse, but records filename as the name of the source
+// file in the returned program and in errors.
+func ParseFile(filename, src string) (*Program, error) {
+	prog := &Program{File: filename}
+
+	for n, ln := range splitLines(src) {
+		s, err := parseLine(tokenizeLine(ln.text, n+1, ln.offset))
+		if err != nil {
+			return nil, withFile(err, filename)
+		}
+		if s != nil {
+			prog.Stmts = append(prog.Stmts, s)
+		}
+	}
+	return prog, nil
+}
+
+// parseLine parses the tokens of a single line. Returns a nil statement if
+// the line holds no code.
+func parseLine(toks []Token) (Stmt, error) {
+	ts := toks[:0:0]
+	for _, t := range toks {
+		if t.Kind != Comment {
+			ts = append(ts, t)
+		}
+	}
+	if len(ts) == 0 {
+		return nil, nil
+	}
+
+	switch ts[0].Kind {
+	case Mnemonic:
+		return parseInstruction(ts)
+	case Directive:
+		return parseDirective(ts)
+	case Label:
+		return parseLabelDef(ts)
+	case Symbol, Illegal:
+		if len(ts) > 1 && ts[1].Kind == Equals {
+			return parseSymbolDef(ts)
+		}
+	}
+	return nil, errorf(ts[0].Pos, "left justified text must be 'symbol=value' or 'label:', leading whitespace missing?")
+}
+
+func parseInstruction(ts []Token) (Stmt, error) {
+	def, ok := instructions[strings.ToLower(ts[0].Text)]
+	if !ok {
+		return nil, errorf(ts[0].Pos, "unknown instruction %s", ts[0].Text)
+	}
+
+	s := &Instruction{MnemonicPos: ts[0].Pos, Mnemonic: ts[0].Text, Opcode: def.code}
+
+	if !def.operand {
+		if len(ts) > 1 {
+			return nil, errorf(ts[1].Pos, "unexpected parameters after instruction %s", ts[0].Text)
+		}
+		return s, nil
+	}
+
+	if len(ts) != 2 {
+		return nil, errorf(ts[0].Pos, "expecting 1 parameter after instruction %s, got %v", ts[0].Text, len(ts)-1)
+	}
+	bitSize := 4
+	if def.wide {
+		bitSize = 8
+	}
+	var err error
+	s.Operand, err = parseExpr(ts[1], bitSize)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func parseDirective(ts []Token) (Stmt, error) {
+	if _, ok := directives[strings.ToLower(ts[0].Text)]; !ok {
+		return nil, errorf(ts[0].Pos, "unknown dot-directive %s", ts[0].Text)
+	}
+
+	if strings.EqualFold(ts[0].Text, ".byte") {
+		return parseByteList(ts)
+	}
+	if len(ts) != 2 {
+		return nil, errorf(ts[0].Pos, "incorrect number of parameters")
+	}
+	if strings.EqualFold(ts[0].Text, ".lang") {
+		if _, err := ParseLevel(ts[1].Text); err != nil {
+			return nil, errorf(ts[1].Pos, "%s", err)
+		}
+		return &DotDirective{NamePos: ts[0].Pos, Name: ts[0].Text, Args: []Expr{&Ident{NamePos: ts[1].Pos, Name: ts[1].Text}}}, nil
+	}
+	if ts[1].Kind != Number {
+		return nil, errorf(ts[1].Pos, "expecting numeric parameter, got '%s'", ts[1].Text)
+	}
+	arg, err := parseExpr(ts[1], 8)
+	if err != nil {
+		return nil, err
+	}
+	return &DotDirective{NamePos: ts[0].Pos, Name: ts[0].Text, Args: []Expr{arg}}, nil
+}
+
+// parseByteList parses the values of a .byte directive, numbers, characters
+// and strings separated by commas, e.g. '.byte "Hi", '!', 0'.
+func parseByteList(ts []Token) (Stmt, error) {
+	s := &DotDirective{NamePos: ts[0].Pos, Name: ts[0].Text}
+	for i := 1; i < len(ts); i += 2 {
+		t := ts[i]
+		switch t.Kind {
+		case Number:
+			arg, err := parseExpr(t, 8)
+			if err != nil {
+				return nil, err
+			}
+			s.Args = append(s.Args, arg)
+		case String:
+			b, _ := Unquote(t.Text)
+			s.Args = append(s.Args, &StringLit{ValuePos: t.Pos, Text: t.Text, Value: b})
+		case Illegal:
+			if t.Text[0] == '\'' || t.Text[0] == '"' {
+				_, err := Unquote(t.Text)
+				return nil, errorf(t.Pos, "invalid string %s: %s", t.Text, err)
+			}
+			fallthrough
+		default:
+			return nil, errorf(t.Pos, "expecting numeric or string parameter, got '%s'", t.Text)
+		}
+		if i+1 < len(ts) && ts[i+1].Kind != Comma {
+			return nil, errorf(ts[i+1].Pos, "expecting ',' between parameters, got '%s'", ts[i+1].Text)
+		}
+		if i+1 == len(ts)-1 {
+			return nil, errorf(ts[i+1].Pos, "expecting a parameter after ','")
+		}
+	}
+	if len(s.Args) == 0 {
+		return nil, errorf(ts[0].Pos, "incorrect number of parameters")
+	}
+	return s, nil
+}
+
+func parseLabelDef(ts []Token) (Stmt, error) {
+	if r := checkSymbol(ts[0].Text); r != "" {
+		return nil, errorf(ts[0].Pos, "illegal character '%s' in label %s", r, ts[0].Text)
+	}
+	for _, t := range ts[2:] {
+		if t.Kind == Colon {
+			return nil, errorf(t.Pos, "found more than one colon in label statement")
+		}
+	}
+	if len(ts) > 2 {
+		return nil, errorf(ts[2].Pos, "unexpected '%s' after label %s", ts[2].Text, ts[0].Text)
+	}
+	return &LabelDef{NamePos: ts[0].Pos, Name: ts[0].Text}, nil
+}
+
+func parseSymbolDef(ts []Token) (Stmt, error) {
+	if r := checkSymbol(ts[0].Text); r != "" {
+		return nil, errorf(ts[0].Pos, "illegal character '%s' in symbol %s", r, ts[0].Text)
+	}
+	for _, t := range ts[2:] {
+		if t.Kind == Equals {
+			return nil, errorf(t.Pos, "found more than one equal sign in symbol statement")
+		}
+	}
+	if len(ts) != 3 || (ts[2].Kind != Number && ts[2].Kind != String) {
+		return nil, errorf(ts[1].Pos, "expecting a single numeric value after '=' in symbol %s", ts[0].Text)
+	}
+	v, err := parseExpr(ts[2], 8)
+	if err != nil {
+		return nil, err
+	}
+	return &SymbolDef{NamePos: ts[0].Pos, Name: ts[0].Text, Value: v}, nil
+}
+
+// parseExpr parses an operand expression. Numbers must fit in bitSize bits.
+func parseExpr(t Token, bitSize int) (Expr, error) {
+	switch t.Kind {
+	case Number:
+		v, err := decodeVal(t.Text, bitSize)
+		if err != nil {
+			var ne *strconv.NumError
+			if errors.As(err, &ne) {
+				err = ne.Err
+			}
+			return nil, errorf(t.Pos, "invalid value '%s': %s", t.Text, err)
+		}
+		return &Literal{ValuePos: t.Pos, Text: t.Text, Value: v}, nil
+	case String:
+		b, _ := Unquote(t.Text)
+		if len(b) != 1 {
+			return nil, errorf(t.Pos, "expecting a single character, got %s", t.Text)
+		}
+		if bitSize < 8 && b[0] >= 1<<uint(bitSize) {
+			return nil, errorf(t.Pos, "invalid value %s: value out of range", t.Text)
+		}
+		return &Literal{ValuePos: t.Pos, Text: t.Text, Value: b[0]}, nil
+	case Symbol:
+		return &Ident{NamePos: t.Pos, Name: t.Text}, nil
+	case Illegal:
+		if t.Text[0] == '\'' || t.Text[0] == '"' {
+			_, err := Unquote(t.Text)
+			return nil, errorf(t.Pos, "invalid string %s: %s", t.Text, err)
+		}
+		r := checkSymbol(t.Text)
+		return nil, errorf(t.Pos, "illegal character '%s' in value '%s'", r, t.Text)
+	}
+	return nil, errorf(t.Pos, "unexpected '%s'", t.Text)
+}
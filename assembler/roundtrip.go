@@ -0,0 +1,61 @@
+package assembler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RoundTripError reports the first address where a program does not survive
+// being disassembled and assembled again.
+type RoundTripError struct {
+	Addr int
+
+	// Want is the byte of the original binary, Got the byte after the round
+	// trip
+	Want, Got byte
+
+	// Source is the disassembled source
+	Source string
+}
+
+// Implements the error-interface
+func (e *RoundTripError) Error() string {
+	return fmt.Sprintf("round trip mismatch at address $%x: want $%02x, got $%02x", e.Addr, e.Want, e.Got)
+}
+
+// VerifyRoundTrip assembles src, disassembles the result using the symbol
+// table of the program and assembles the disassembled source again. Returns an
+// error if any step fails, or a *RoundTripError if the two binaries differ.
+func VerifyRoundTrip(src string) error {
+	prog, err := Parse(src)
+	if err != nil {
+		return err
+	}
+	obj, err := AssembleObject(prog)
+	if err != nil {
+		return err
+	}
+	return VerifyBinaryRoundTrip(obj.Bin, DisasmOptions{Symbols: obj.Symbols, Labels: obj.Labels, Level: obj.Level})
+}
+
+// VerifyBinaryRoundTrip disassembles bin with opts and assembles the result
+// again. Returns an error if any step fails, or a *RoundTripError if the
+// binaries differ.
+func VerifyBinaryRoundTrip(bin []byte, opts DisasmOptions) error {
+	var b strings.Builder
+	if err := Disassemble(&b, bin, opts); err != nil {
+		return fmt.Errorf("disassembling: %s", err)
+	}
+
+	res, err := Assemble(b.String())
+	if err != nil {
+		return fmt.Errorf("assembling disassembled source: %s", err)
+	}
+
+	for i, want := range bin {
+		if got := res[opts.Origin+i]; got != want {
+			return &RoundTripError{Addr: opts.Origin + i, Want: want, Got: got, Source: b.String()}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package assembler
+
+import (
+	"strings"
+	"unicode"
+)
+
+// bom is the UTF-8 encoded byte order mark some editors put at the start of
+// files.
+const bom = "\ufeff"
+
+// srcLine is a single line of source code without its line terminator.
+type srcLine struct {
+	text   string
+	offset int // byte offset of the line in the source
+}
+
+// splitLines splits src into lines. Lines may be terminated by "\n", "\r\n" or
+// a lone "\r". A byte order mark at the start of src is skipped, but is still
+// counted in the offsets so positions refer to the unmodified source.
+func splitLines(src string) []srcLine {
+	var lns []srcLine
+
+	start := 0
+	if strings.HasPrefix(src, bom) {
+		start = len(bom)
+	}
+	for i := start; i < len(src); i++ {
+		switch src[i] {
+		case '\n':
+			lns = append(lns, srcLine{src[start:i], start})
+			start = i + 1
+		case '\r':
+			lns = append(lns, srcLine{src[start:i], start})
+			if i+1 < len(src) && src[i+1] == '\n' {
+				i++
+			}
+			start = i + 1
+		}
+	}
+	return append(lns, srcLine{src[start:], start})
+}
+
+// Normalize returns src with the byte order mark removed, all line endings
+// converted to "\n" and all other whitespace characters converted to a single
+// space each. Normalizing does not change the meaning of a program, but
+// positions in the normalized source may differ from the original.
+func Normalize(src string) string {
+	lns := splitLines(src)
+
+	var b strings.Builder
+	b.Grow(len(src))
+	for i, ln := range lns {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		for _, r := range ln.text {
+			if isSpace(r) {
+				r = ' '
+			}
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isSpace reports whether r separates tokens. In addition to the unicode
+// white space characters the invisible zero width space and byte order mark
+// are treated as white space.
+func isSpace(r rune) bool {
+	return unicode.IsSpace(r) || r == '\u200b' || r == '\ufeff'
+}
@@ -0,0 +1,68 @@
+package assembler
+
+import "testing"
+
+func TestTokenizeLineEndings(t *testing.T) {
+	type tok struct {
+		kind      TokenKind
+		text      string
+		line, col int
+	}
+	want := []tok{
+		{Label, "loop", 1, 1},
+		{Colon, ":", 1, 5},
+		{Mnemonic, "ldi", 2, 2},
+		{Number, "1", 2, 6},
+		{Comment, "; one", 2, 8},
+		{Mnemonic, "out", 4, 2},
+	}
+
+	tests := []struct {
+		name    string
+		src     string
+		offsets []int
+	}{
+		{"lf", "loop:\n ldi 1 ; one\n\n out\n", []int{0, 4, 7, 11, 13, 21}},
+		{"crlf", "loop:\r\n ldi 1 ; one\r\n\r\n out\r\n", []int{0, 4, 8, 12, 14, 24}},
+		{"cr", "loop:\r ldi 1 ; one\r\r out\r", []int{0, 4, 7, 11, 13, 21}},
+		{"mixed", "loop:\r\n ldi 1 ; one\n\r out", []int{0, 4, 8, 12, 14, 22}},
+		{"bom", "\ufeffloop:\r\n ldi 1 ; one\n\r out\r\n", []int{3, 7, 11, 15, 17, 25}},
+	}
+	for _, tt := range tests {
+		toks := Tokenize(tt.src)
+		if len(toks) != len(want) {
+			t.Errorf("%s: %d tokens %v, want %d", tt.name, len(toks), toks, len(want))
+			continue
+		}
+		for i, w := range want {
+			got := toks[i]
+			if got.Kind != w.kind || got.Text != w.text || got.Pos.Line != w.line || got.Pos.Col != w.col || got.Pos.Offset != tt.offsets[i] {
+				t.Errorf("%s: token %d is %s %q at %v offset %d, want %s %q at %d:%d offset %d",
+					tt.name, i, got.Kind, got.Text, got.Pos, got.Pos.Offset, w.kind, w.text, w.line, w.col, tt.offsets[i])
+			}
+		}
+	}
+}
+
+func TestParseErrorLineEndings(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		line int
+	}{
+		{"lf", " ldi 1\n\n bogus 2\n", 3},
+		{"crlf", " ldi 1\r\n\r\n bogus 2\r\n", 3},
+		{"mixed", " ldi 1\r\n\n\r bogus 2", 4},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.src)
+		e, ok := err.(*Error)
+		if !ok {
+			t.Errorf("%s: error %v, want *Error", tt.name, err)
+			continue
+		}
+		if e.Pos.Line != tt.line || e.Pos.Col != 2 {
+			t.Errorf("%s: error at %v, want %d:2", tt.name, e.Pos, tt.line)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package assembler
+
+import (
+	"fmt"
+	"io"
+)
+
+// SourceLoc relates a memory address to the source code that produced it.
+type SourceLoc struct {
+	// File is the name of the source file, empty if unknown
+	File string
+
+	// Line is the 1-based line number of the statement, 0 if the address was
+	// left unused by the program
+	Line int
+
+	// Label is the closest label at or before the address, empty if there is
+	// none. Offset is the distance from that label to the address.
+	Label  string
+	Offset int
+}
+
+// Valid reports whether the address was produced by the source code.
+func (l SourceLoc) Valid() bool {
+	return l.Line > 0
+}
+
+// Context returns the address relative to the closest preceding label, e.g.
+// 'loop+1'. Returns an empty string if there is no preceding label.
+func (l SourceLoc) Context() string {
+	switch {
+	case l.Label == "":
+		return ""
+	case l.Offset == 0:
+		return l.Label
+	}
+	return fmt.Sprintf("%s+%d", l.Label, l.Offset)
+}
+
+// Implements the Stringer-interface
+func (l SourceLoc) String() string {
+	if l.File == "" {
+		return fmt.Sprintf("line %d", l.Line)
+	}
+	return fmt.Sprintf("%s:%d", l.File, l.Line)
+}
+
+// SourceMap holds the source location of each memory address, indexed by
+// address.
+type SourceMap []SourceLoc
+
+// Lookup returns the source location of addr. The boolean is false if addr is
+// out of range or was not produced by the source code.
+func (m SourceMap) Lookup(addr int) (SourceLoc, bool) {
+	if addr < 0 || addr >= len(m) {
+		return SourceLoc{}, false
+	}
+	return m[addr], m[addr].Valid()
+}
+
+// WriteTo writes the source map as text to w, one line per used address in the
+// format 'address file:line context'. Implements the WriterTo-interface.
+func (m SourceMap) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	for addr, l := range m {
+		if !l.Valid() {
+			continue
+		}
+		i, err := fmt.Fprintf(w, "$%02x %s %s\n", addr, l, l.Context())
+		n += int64(i)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// newSourceMap builds the source map of an assembled object.
+func newSourceMap(file string, obj *Object) SourceMap {
+	m := make(SourceMap, len(obj.Owner))
+	for addr, s := range obj.Owner {
+		m[addr].File = file
+		if s != nil {
+			m[addr].Line = s.Pos().Line
+		}
+
+		// the closest label is the one with the highest address not above addr,
+		// the first defined wins if several labels share an address
+		best := -1
+		for _, name := range obj.Labels {
+			la := int(obj.Symbols[name])
+			if la <= addr && la > best {
+				best = la
+				m[addr].Label = name
+				m[addr].Offset = addr - la
+			}
+		}
+	}
+	return m
+}
+
+// StmtText returns the statement that produced the byte at addr as written in
+// the source code, e.g. 'ADD adder' or '.byte 1', or an empty string if the
+// address was left unused by the program.
+func (o *Object) StmtText(addr int) string {
+	if addr < 0 || addr >= len(o.Owner) {
+		return ""
+	}
+	switch s := o.Owner[addr].(type) {
+	case *Instruction:
+		if s.Operand == nil {
+			return s.Mnemonic
+		}
+		return s.Mnemonic + " " + exprText(s.Operand)
+	case *DotDirective:
+		t := s.Name
+		for i, a := range s.Args {
+			if i > 0 {
+				t += ","
+			}
+			t += " " + exprText(a)
+		}
+		return t
+	}
+	return ""
+}
+
+func exprText(e Expr) string {
+	switch e := e.(type) {
+	case *Literal:
+		return e.Text
+	case *Ident:
+		return e.Name
+	case *StringLit:
+		return e.Text
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+package assembler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// quotedLen returns the length in bytes of the quoted literal at the start of
+// s, up to and including the closing quote. ok is false if the literal is not
+// terminated, in which case n is the length of s.
+func quotedLen(s string) (n int, ok bool) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case s[0]:
+			return i + 1, true
+		}
+	}
+	return len(s), false
+}
+
+// Unquote returns the bytes of a character or string literal in single or
+// double quotes as written in the source, e.g. 'H' or "Hi\n". Characters
+// outside ASCII are stored in UTF-8. The escape sequences are:
+//
+//	\n  line feed       \r  carriage return   \t  tab
+//	\0  zero            \\  backslash         \xNN  the hexadecimal byte NN
+//	\'  single quote    \"  double quote
+func Unquote(s string) ([]byte, error) {
+	if s == "" || (s[0] != '\'' && s[0] != '"') {
+		return nil, errors.New("expecting a quoted string")
+	}
+	if n, ok := quotedLen(s); !ok || n != len(s) {
+		return nil, errors.New("unterminated string")
+	}
+	var b []byte
+	for i := 1; i < len(s)-1; {
+		if s[i] != '\\' {
+			_, w := utf8.DecodeRuneInString(s[i:])
+			b = append(b, s[i:i+w]...)
+			i += w
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case '0':
+			b = append(b, 0)
+		case '\\', '\'', '"':
+			b = append(b, c)
+		case 'x':
+			if i+3 > len(s)-1 {
+				return nil, errors.New(`escape sequence \x needs two hexadecimal digits`)
+			}
+			v, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf(`invalid escape sequence \x%s`, s[i+1:i+3])
+			}
+			b = append(b, byte(v))
+			i += 2
+		default:
+			r, _ := utf8.DecodeRuneInString(s[i:])
+			return nil, fmt.Errorf(`unknown escape sequence \%c`, r)
+		}
+		i++
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty string")
+	}
+	return b, nil
+}
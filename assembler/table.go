@@ -0,0 +1,80 @@
+package assembler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteTable writes bin to w in the address/data table notation used in Ben's
+// videos, one line per byte with the address and the data in binary, e.g.
+// '0000: 0001 1110'. The first byte of bin is at address base.
+func WriteTable(w io.Writer, bin []byte, base int) error {
+	bw := bufio.NewWriter(w)
+	for i, b := range bin {
+		fmt.Fprintf(bw, "%04b: %04b %04b\n", (base+i)&0x0f, b>>4, b&0x0f)
+	}
+	return bw.Flush()
+}
+
+// ReadTable reads a program in the address/data table notation from r and
+// returns it as a 16 byte memory image. Addresses not listed are zero.
+//
+// Each line holds a binary address of up to four digits, a colon and eight
+// binary digits of data, which may be split in groups by spaces, e.g.
+// '0000: 0001 1110'. Text following the data, like the mnemonic in
+// '0000: 0001 1110  LDA 14', is ignored, as are comments starting with ';',
+// '#' or '//' and blank lines.
+func ReadTable(r io.Reader) ([]byte, error) {
+	bin := make([]byte, 16)
+	var seen [16]bool
+
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		ln := sc.Text()
+		if i := strings.IndexAny(ln, ";#"); i >= 0 {
+			ln = ln[:i]
+		}
+		if i := strings.Index(ln, "//"); i >= 0 {
+			ln = ln[:i]
+		}
+		ln = strings.TrimSpace(ln)
+		if ln == "" {
+			continue
+		}
+
+		i := strings.IndexByte(ln, ':')
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: missing ':' between address and data", n)
+		}
+		addrText := strings.Join(strings.Fields(ln[:i]), "")
+		addr, err := strconv.ParseUint(addrText, 2, 4)
+		if err != nil || len(addrText) > 4 {
+			return nil, fmt.Errorf("line %d: invalid address '%s', expecting up to 4 binary digits", n, addrText)
+		}
+
+		var data string
+		for _, f := range strings.Fields(ln[i+1:]) {
+			if len(data) >= 8 || strings.Trim(f, "01") != "" {
+				break
+			}
+			data += f
+		}
+		if len(data) != 8 {
+			return nil, fmt.Errorf("line %d: expecting 8 binary digits of data", n)
+		}
+		v, _ := strconv.ParseUint(data, 2, 8)
+
+		if seen[addr] {
+			return nil, fmt.Errorf("line %d: address %04b listed twice", n, addr)
+		}
+		seen[addr] = true
+		bin[addr] = byte(v)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return bin, nil
+}
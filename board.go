@@ -0,0 +1,83 @@
+package eatersim
+
+// Board is implemented by the boards of the breadboard cpu, so that machines
+// built from them, and peripherals added to them, can be executed, reset and
+// shown uniformly, see Circuit.
+type Board interface {
+	// Exec executes the logic of the board once
+	Exec()
+
+	// Reset puts the board into its state after a reset at once, whether or
+	// not a CLR signal is connected to it. The clock is low after a reset,
+	// the content of memories is kept.
+	Reset()
+
+	// String renders the state of the board as text
+	String() string
+}
+
+// Boards returns the boards of the cpu in the order Exec executes them. The
+// display, which runs on its own refresh clock, comes last.
+func (c *BBCpu) Boards() []Board {
+	return []Board{c.CLK, c.Ring, c.CL, c.Areg, c.Breg, c.Oreg, c.ALU, c.MAR, c.RAM, c.PC, c.IR, c.Display}
+}
+
+// Reset stops the clock in its low phase.
+func (c *Clk) Reset() {
+	c.CLK = false
+}
+
+// Reset clears the register.
+func (r *Reg) Reset() {
+	r.BUF = 0
+	r.clkprev = false
+}
+
+// Reset clears the instruction register.
+func (r *Ireg) Reset() {
+	r.BUF = 0
+	r.clkprev = false
+}
+
+// Reset clears the memory address register.
+func (r *Reg4) Reset() {
+	r.BUF = 0
+	r.clkprev = false
+}
+
+// Reset keeps the content of the memory and the switches.
+func (m *Mem) Reset() {
+	m.clkprev = false
+}
+
+// Reset clears the carry and zero flags.
+func (a *Alu) Reset() {
+	a.CF, a.ZF = false, false
+	a.clkprev = false
+}
+
+// Reset sets the counter to the reset vector, and drives it onto the bus if
+// CO is active, as for the first fetch step set up by Ctrl.Reset, so that it
+// is on the bus before the next rising clock edge.
+func (c *Ctr) Reset() {
+	c.CNT = c.Vector & 0x0f
+	c.clkprev = false
+	if ptbool(c.CO) && c.BUS != nil {
+		*c.BUS = c.CNT
+	}
+}
+
+// Reset sets the step counter to T0.
+func (r *Ring) Reset() {
+	r.Cnt = 0
+	r.clkprev = false
+	for i := range r.T {
+		r.T[i] = i == 0
+	}
+}
+
+// Reset clears the digit select counter and blanks the digits.
+func (d *Display) Reset() {
+	d.Digit, d.Segments = 0, 0
+	d.Lit = [4]byte{}
+}
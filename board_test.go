@@ -0,0 +1,55 @@
+package eatersim
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBoardReset(t *testing.T) {
+	// LDI 7, SUB 15, OUT, HLT with 7 at 15, setting the zero flag
+	prog := make([]byte, 0x10)
+	copy(prog, []byte{0x57, 0x3f, 0xe0, 0xf0})
+	prog[0xf] = 7
+
+	cpu := NewBBCpu()
+	copy(cpu.RAM.MEM[:], prog)
+	for i := 0; i < 100 && !cpu.Halted(); i++ {
+		cpu.Exec()
+	}
+	if !cpu.Halted() || !cpu.ALU.ZF {
+		t.Fatalf("program halted %v with ZF %v, want halted with ZF set", cpu.Halted(), cpu.ALU.ZF)
+	}
+	cpu.Display.Exec()
+	cpu.PC.Vector = 2
+
+	for _, b := range cpu.Boards() {
+		b.Reset()
+	}
+
+	if cpu.CLK.CLK {
+		t.Error("clk: clock high, want low")
+	}
+	for name, buf := range map[string]byte{"areg": cpu.Areg.BUF, "breg": cpu.Breg.BUF, "oreg": cpu.Oreg.BUF, "mar": cpu.MAR.BUF, "ir": cpu.IR.BUF} {
+		if buf != 0 {
+			t.Errorf("%s: buffer %08b, want 0", name, buf)
+		}
+	}
+	if !bytes.Equal(cpu.RAM.MEM[:], prog) {
+		t.Errorf("ram: memory % x, want % x", cpu.RAM.MEM, prog)
+	}
+	if cpu.ALU.CF || cpu.ALU.ZF {
+		t.Errorf("alu: CF %v, ZF %v, want both clear", cpu.ALU.CF, cpu.ALU.ZF)
+	}
+	if cpu.PC.CNT != 2 || cpu.BUS != 2 {
+		t.Errorf("pc: counter %d on bus %d, want the vector 2 on both", cpu.PC.CNT, cpu.BUS)
+	}
+	if cpu.Ring.Cnt != 0 || !cpu.Ring.T[0] {
+		t.Errorf("ring: step %d, T %v, want T0", cpu.Ring.Cnt, cpu.Ring.T)
+	}
+	if cpu.CL.CLR || cpu.CL.HLT || cpu.CL.Cnt != 0 || cpu.CL.Word() != CO|MI {
+		t.Errorf("ctrl: CLR %v, HLT %v, %s, want CLR and HLT inactive, T0: CO|MI", cpu.CL.CLR, cpu.CL.HLT, cpu.CL.MicroOp())
+	}
+	if cpu.Display.Digit != 0 || cpu.Display.Segments != 0 || cpu.Display.Lit != [4]byte{} {
+		t.Errorf("display: digit %d, segments %08b, lit % x, want all clear", cpu.Display.Digit, cpu.Display.Segments, cpu.Display.Lit)
+	}
+}
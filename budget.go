@@ -0,0 +1,42 @@
+package eatersim
+
+import "errors"
+
+// ErrBudget is returned by the budgeted variants of Instruction and Run if the
+// cpu did not get done within its budget of half steps.
+var ErrBudget = errors.New("half step budget exceeded")
+
+// InstructionBudget executes the current instruction like Instruction, but
+// executes at most budget half steps. Returns ErrBudget if the instruction
+// did not complete within the budget, leaving the cpu in the middle of it.
+// Servers use it to bound the time spent on a request, e.g. while the control
+// logic is disengaged and instructions never complete.
+func (c *BBCpu) InstructionBudget(budget int) error {
+	if c.Halted() {
+		return nil
+	}
+	for n := 0; n < budget; n++ {
+		c.Exec()
+		if c.AtBoundary() || c.Halted() {
+			return nil
+		}
+	}
+	return ErrBudget
+}
+
+// RunBudget executes the cpu until it halts like Run, but executes at most
+// budget half steps. Returns why the cpu stopped, and ErrBudget if it did not
+// halt within the budget.
+func (c *BBCpu) RunBudget(budget int) (StopReason, error) {
+	c.warnStale()
+	c.setRunning(true)
+	defer c.setRunning(false)
+	for n := 0; !c.Halted(); n++ {
+		if n >= budget {
+			return StopCycleLimit, ErrBudget
+		}
+		c.checkPause()
+		c.Exec()
+	}
+	return c.haltReason(), nil
+}
@@ -0,0 +1,210 @@
+package eatersim
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Observation is what a logic analyzer sees of a clock cycle: the values on
+// the bus and the control lines before the rising clock edge, when the boards
+// latch them.
+type Observation struct {
+	// Time of the rising clock edge as given by the capture, in seconds
+	Time float64
+
+	BUS     byte
+	Control ControlWord
+}
+
+// Capture is a logic analyzer capture of a physical build, reduced to one
+// observation per clock cycle. Only some of the bus and control lines are
+// usually probed, BusMask and ControlMask tell which.
+type Capture struct {
+	BusMask     byte
+	ControlMask ControlWord
+	Cycles      []Observation
+}
+
+// ReadCapture reads a logic analyzer capture exported as CSV, with a header row
+// naming the channels, a first column holding the time in seconds and one
+// column per channel holding 0 or 1, like the exports of most logic analyzer
+// software.
+//
+// channels maps channel names in the header to signals: "CLK" for the clock,
+// "D0" to "D7" for the bus lines and the names of the control signals, e.g.
+// "AO". A signal prefixed with '~' is active low, as several control lines in
+// the breadboard build are. Channels missing in channels are mapped by their
+// own name if it is a signal name, and ignored otherwise. The clock channel is
+// required.
+func ReadCapture(r io.Reader, channels map[string]string) (*Capture, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %v", err)
+	}
+
+	type column struct {
+		bus      byte
+		ctrl     ControlWord
+		clk, inv bool
+	}
+	cols := make([]column, len(header))
+	c := new(Capture)
+	clkCol := -1
+	for i, name := range header[1:] {
+		name = strings.TrimSpace(name)
+		sig, ok := channels[name]
+		if !ok {
+			sig = name
+		}
+		col := column{inv: strings.HasPrefix(sig, "~")}
+		sig = strings.ToUpper(strings.TrimPrefix(sig, "~"))
+		switch {
+		case sig == "CLK":
+			col.clk = true
+			clkCol = i + 1
+		case len(sig) == 2 && sig[0] == 'D' && sig[1] >= '0' && sig[1] <= '7':
+			col.bus = 1 << (sig[1] - '0')
+			c.BusMask |= col.bus
+		default:
+			col.ctrl = controlByName(sig)
+			if col.ctrl == 0 && ok {
+				return nil, fmt.Errorf("channel %s: unknown signal %s", name, sig)
+			}
+			c.ControlMask |= col.ctrl
+		}
+		cols[i+1] = col
+	}
+	if clkCol < 0 {
+		return nil, errors.New("no clock channel")
+	}
+
+	var prev Observation
+	prevCLK, first := false, true
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) != len(cols) {
+			return nil, fmt.Errorf("line %d: expecting %d columns, got %d", line, len(cols), len(rec))
+		}
+		t, err := strconv.ParseFloat(strings.TrimSpace(rec[0]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid time %s", line, rec[0])
+		}
+
+		var o Observation
+		var clk bool
+		for i, col := range cols[1:] {
+			v := strings.TrimSpace(rec[i+1])
+			if v != "0" && v != "1" {
+				return nil, fmt.Errorf("line %d: invalid level %s", line, v)
+			}
+			high := (v == "1") != col.inv
+			switch {
+			case col.clk:
+				clk = high
+			case high:
+				o.BUS |= col.bus
+				o.Control |= col.ctrl
+			}
+		}
+		// a cycle is observed by the levels sampled last before the clock
+		// rises
+		if clk && !prevCLK && !first {
+			prev.Time = t
+			c.Cycles = append(c.Cycles, prev)
+		}
+		prev, prevCLK, first = o, clk, false
+	}
+	return c, nil
+}
+
+// controlByName returns the control signal named name, or 0.
+func controlByName(name string) ControlWord {
+	for i, n := range controlNames {
+		if n == name {
+			return BO >> uint(i)
+		}
+	}
+	return 0
+}
+
+// Divergence is the first clock cycle in which a capture differs from the
+// simulation.
+type Divergence struct {
+	// Cycle is the index of the diverging cycle in the capture
+	Cycle int
+
+	// Time of the diverging cycle in the capture
+	Time float64
+
+	// Want is the simulated and Got the captured observation, masked to the
+	// probed lines
+	Want, Got Observation
+}
+
+// Implements the error-interface
+func (d *Divergence) Error() string {
+	s := fmt.Sprintf("cycle %d at %.6gs diverges:", d.Cycle, d.Time)
+	if d.Want.BUS != d.Got.BUS {
+		s += fmt.Sprintf(" bus %08b, simulated %08b;", d.Got.BUS, d.Want.BUS)
+	}
+	if d.Want.Control != d.Got.Control {
+		s += fmt.Sprintf(" control %s, simulated %s;", d.Got.Control, d.Want.Control)
+	}
+	return strings.TrimSuffix(s, ";")
+}
+
+// Compare runs cpu alongside the capture and reports the first cycle in which
+// they differ on the probed lines, as a *Divergence. As captures rarely start
+// exactly at reset, the capture is aligned with the simulation by skipping up
+// to maxOffset simulated cycles, choosing the offset with the longest matching
+// run. Returns the chosen offset.
+func (c *Capture) Compare(cpu *BBCpu, maxOffset int) (int, error) {
+	// simulated observations, the levels before each rising clock edge
+	sim := make([]Observation, 0, len(c.Cycles)+maxOffset)
+	for len(sim) < cap(sim) {
+		if cpu.CLK.CLK {
+			cpu.Exec()
+		}
+		sim = append(sim, Observation{BUS: cpu.BUS & c.BusMask, Control: cpu.CL.Word() & c.ControlMask})
+		if cpu.CL.HLT {
+			// the clock stops, the levels stay
+			continue
+		}
+		cpu.Exec()
+	}
+
+	best, bestLen := 0, -1
+	for off := 0; off <= maxOffset; off++ {
+		n := 0
+		for n < len(c.Cycles) && sim[off+n] == c.masked(n) {
+			n++
+		}
+		if n > bestLen {
+			best, bestLen = off, n
+		}
+		if n == len(c.Cycles) {
+			return off, nil
+		}
+	}
+	got := c.masked(bestLen)
+	got.Time = c.Cycles[bestLen].Time
+	return best, &Divergence{Cycle: bestLen, Time: got.Time, Want: sim[best+bestLen], Got: got}
+}
+
+// masked returns the observation of cycle i without its time.
+func (c *Capture) masked(i int) Observation {
+	o := c.Cycles[i]
+	return Observation{BUS: o.BUS & c.BusMask, Control: o.Control & c.ControlMask}
+}
@@ -0,0 +1,98 @@
+package eatersim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Circuit is a machine built from boards: an ordered set of boards, each known
+// by a name, executed in order. It runs non-standard machines built from the
+// parts of the breadboard cpu, wired by their signals like NewBBCpu wires
+// the cpu, e.g. a cpu with a second output register.
+type Circuit struct {
+	names  []string
+	boards []Board
+	index  map[string]int
+}
+
+// NewCircuit creates an empty circuit.
+func NewCircuit() *Circuit {
+	return &Circuit{index: make(map[string]int)}
+}
+
+// Add appends the board b under name, to be executed after the boards added
+// before. Fails if the name is taken.
+func (c *Circuit) Add(name string, b Board) error {
+	if _, ok := c.index[name]; ok {
+		return fmt.Errorf("board %s already in the circuit", name)
+	}
+	if c.index == nil {
+		c.index = make(map[string]int)
+	}
+	c.index[name] = len(c.boards)
+	c.names = append(c.names, name)
+	c.boards = append(c.boards, b)
+	return nil
+}
+
+// Board returns the board called name. The boolean is false if there is none.
+func (c *Circuit) Board(name string) (Board, bool) {
+	i, ok := c.index[name]
+	if !ok {
+		return nil, false
+	}
+	return c.boards[i], true
+}
+
+// Names returns the names of the boards in the order they are executed.
+func (c *Circuit) Names() []string {
+	return append([]string(nil), c.names...)
+}
+
+// Boards returns the boards in the order they are executed.
+func (c *Circuit) Boards() []Board {
+	return append([]Board(nil), c.boards...)
+}
+
+// Exec executes all boards once, in the order they were added.
+func (c *Circuit) Exec() {
+	for _, b := range c.boards {
+		b.Exec()
+	}
+}
+
+// Reset resets all boards, in the order they were added.
+func (c *Circuit) Reset() {
+	for _, b := range c.boards {
+		b.Reset()
+	}
+}
+
+// Implements the Stringer-interface. Renders each board as text under its
+// name.
+func (c *Circuit) String() string {
+	parts := make([]string, len(c.boards))
+	for i, b := range c.boards {
+		parts[i] = fmt.Sprintf("%s:\n%s", c.names[i], b)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Circuit returns a circuit of the boards of the cpu in the order Exec
+// executes them, named clk, ring, cl, areg, breg, oreg, alu, mar, ram, pc and
+// ir. Executing it executes the boards like Exec, but without the clock
+// domains and the bookkeeping of Exec, e.g. counting Cycles.
+func (c *BBCpu) Circuit() *Circuit {
+	circuit := NewCircuit()
+	for _, b := range []struct {
+		name  string
+		board Board
+	}{
+		{"clk", c.CLK}, {"ring", c.Ring}, {"cl", c.CL}, {"areg", c.Areg},
+		{"breg", c.Breg}, {"oreg", c.Oreg}, {"alu", c.ALU}, {"mar", c.MAR},
+		{"ram", c.RAM}, {"pc", c.PC}, {"ir", c.IR},
+	} {
+		circuit.Add(b.name, b.board)
+	}
+	return circuit
+}
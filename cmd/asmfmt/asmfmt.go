@@ -0,0 +1,78 @@
+// asmfmt formats assembly source for Ben Eater's 8-bit breadboard CPU in the
+// canonical style.
+//
+// Without file arguments asmfmt formats standard input to standard output.
+// With file arguments the formatted files are printed to standard output,
+// unless -w or -l is given.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/oj-mik/eatersim/assembler"
+)
+
+var write, list bool
+
+func init() {
+	flag.BoolVar(&write, "w", false, "write result to source file instead of standard output")
+	flag.BoolVar(&list, "l", false, "list files whose formatting differs from asmfmt's")
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not read standard input: %s\n", err)
+			os.Exit(1)
+		}
+		res, err := assembler.Format(string(src))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "<standard input>:%s\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(res)
+		return
+	}
+
+	failed := false
+	for _, path := range flag.Args() {
+		if err := formatFile(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func formatFile(path string) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	res, err := assembler.Format(string(src))
+	if err != nil {
+		return fmt.Errorf("%s:%s", path, err)
+	}
+
+	if list && res != string(src) {
+		fmt.Println(path)
+	}
+	if write {
+		if res == string(src) {
+			return nil
+		}
+		return os.WriteFile(path, []byte(res), 0644)
+	}
+	if !list {
+		fmt.Print(res)
+	}
+	return nil
+}
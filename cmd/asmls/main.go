@@ -0,0 +1,115 @@
+// asmls is a Language Server Protocol server for the assembly language of
+// Ben Eater's 8-bit breadboard CPU.
+//
+// The server communicates over standard input and output and supports
+// diagnostics, go to definition of labels and symbols, hover information,
+// completion of mnemonics, dot directives, labels and symbols, and semantic
+// tokens for syntax highlighting.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/textproto"
+	"os"
+	"strconv"
+)
+
+// request is a JSON-RPC request or notification. Notifications have no ID.
+type request struct {
+	ID     *json.RawMessage `json:"id,omitempty"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result"`
+}
+
+type errorResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Error   responseError    `json:"error"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+// JSON-RPC error codes
+const (
+	errParse          = -32700
+	errMethodNotFound = -32601
+	errInvalidParams  = -32602
+)
+
+func main() {
+	log.SetOutput(os.Stderr)
+	log.SetPrefix("asmls: ")
+
+	s := newServer(os.Stdout)
+	if err := s.serve(os.Stdin); err != nil && err != io.EOF {
+		log.Fatal(err)
+	}
+}
+
+// serve reads messages from r until exit is requested or r is exhausted.
+func (s *server) serve(r io.Reader) error {
+	tr := textproto.NewReader(bufio.NewReader(r))
+	for !s.exit {
+		hdr, err := tr.ReadMIMEHeader()
+		if err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(hdr.Get("Content-Length"))
+		if err != nil {
+			return fmt.Errorf("invalid Content-Length header: %s", err)
+		}
+		body := make([]byte, n)
+		if _, err := io.ReadFull(tr.R, body); err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.replyError(nil, errParse, err.Error())
+			continue
+		}
+		s.handle(&req)
+	}
+	return nil
+}
+
+// send writes msg to the client with the LSP base protocol header.
+func (s *server) send(msg interface{}) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(b), b)
+}
+
+func (s *server) reply(id *json.RawMessage, result interface{}) {
+	s.send(response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *server) replyError(id *json.RawMessage, code int, msg string) {
+	s.send(errorResponse{JSONRPC: "2.0", ID: id, Error: responseError{code, msg}})
+}
+
+func (s *server) notify(method string, params interface{}) {
+	s.send(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/oj-mik/eatersim/assembler"
+)
+
+// protocol types, only the fields used by the server are declared
+
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+type location struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type textDocumentPositionParams struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position position `json:"position"`
+}
+
+type diagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type completionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type hover struct {
+	Contents struct {
+		Kind  string `json:"kind"`
+		Value string `json:"value"`
+	} `json:"contents"`
+	Range lspRange `json:"range"`
+}
+
+// completion item kinds
+const (
+	kindKeyword  = 14
+	kindConstant = 21
+	kindVariable = 6
+)
+
+// server holds the state of the language server.
+type server struct {
+	out  io.Writer
+	docs map[string]string
+
+	shutdown, exit bool
+}
+
+func newServer(out io.Writer) *server {
+	return &server{out: out, docs: make(map[string]string)}
+}
+
+// handle dispatches a single request or notification.
+func (s *server) handle(req *request) {
+	switch req.Method {
+	case "initialize":
+		s.reply(req.ID, map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   1, // full document sync
+				"hoverProvider":      true,
+				"definitionProvider": true,
+				"completionProvider": map[string]interface{}{},
+				"semanticTokensProvider": map[string]interface{}{
+					"legend": map[string]interface{}{
+						"tokenTypes":     semanticTypes,
+						"tokenModifiers": []string{"declaration"},
+					},
+					"full": true,
+				},
+			},
+			"serverInfo": map[string]string{"name": "asmls"},
+		})
+	case "shutdown":
+		s.shutdown = true
+		s.reply(req.ID, nil)
+	case "exit":
+		s.exit = true
+
+	case "textDocument/didOpen":
+		var p struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if json.Unmarshal(req.Params, &p) == nil {
+			s.update(p.TextDocument.URI, p.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		var p struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if json.Unmarshal(req.Params, &p) == nil && len(p.ContentChanges) > 0 {
+			s.update(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+		}
+	case "textDocument/didClose":
+		var p textDocumentPositionParams
+		if json.Unmarshal(req.Params, &p) == nil {
+			delete(s.docs, p.TextDocument.URI)
+			s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+				"uri":         p.TextDocument.URI,
+				"diagnostics": []diagnostic{},
+			})
+		}
+
+	case "textDocument/semanticTokens/full":
+		var p textDocumentPositionParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			s.replyError(req.ID, errInvalidParams, err.Error())
+			return
+		}
+		s.reply(req.ID, map[string]interface{}{"data": semanticTokens(s.docs[p.TextDocument.URI])})
+
+	case "textDocument/definition", "textDocument/hover", "textDocument/completion":
+		var p textDocumentPositionParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			s.replyError(req.ID, errInvalidParams, err.Error())
+			return
+		}
+		src := s.docs[p.TextDocument.URI]
+		switch req.Method {
+		case "textDocument/definition":
+			s.reply(req.ID, definition(p.TextDocument.URI, src, p.Position))
+		case "textDocument/hover":
+			s.reply(req.ID, hoverAt(src, p.Position))
+		case "textDocument/completion":
+			s.reply(req.ID, completion(src))
+		}
+
+	default:
+		// unknown notifications are ignored, unknown requests are errors
+		if req.ID != nil {
+			s.replyError(req.ID, errMethodNotFound, "method not supported: "+req.Method)
+		}
+	}
+}
+
+// update stores the new content of a document and publishes its diagnostics.
+func (s *server) update(uri, src string) {
+	s.docs[uri] = src
+
+	diags := []diagnostic{}
+	prog, err := assembler.Parse(src)
+	var obj *assembler.Object
+	if err == nil {
+		obj, err = assembler.AssembleObject(prog)
+	}
+	if err != nil {
+		d := diagnostic{Severity: 1, Source: "asmls", Message: err.Error()}
+		if e, ok := err.(*assembler.Error); ok {
+			d.Message = e.Msg
+			d.Range = tokenRange(src, e.Pos)
+		}
+		diags = append(diags, d)
+	}
+	if obj != nil {
+		for _, w := range obj.CheckJumps(src) {
+			d := diagnostic{Severity: 2, Source: "asmls", Message: w.Error()}
+			if e, ok := w.(*assembler.Error); ok {
+				d.Message = e.Msg
+				d.Range = tokenRange(src, e.Pos)
+			}
+			diags = append(diags, d)
+		}
+	}
+	s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+		"uri":         uri,
+		"diagnostics": diags,
+	})
+}
+
+// definition returns the location of the label or symbol definition referenced
+// at pos, or nil.
+func definition(uri, src string, pos position) interface{} {
+	tok, ok := tokenAt(src, pos)
+	if !ok || (tok.Kind != assembler.Symbol && tok.Kind != assembler.Label) {
+		return nil
+	}
+	def, ok := findDef(src, tok.Text)
+	if !ok {
+		return nil
+	}
+	return location{URI: uri, Range: toRange(src, def)}
+}
+
+// hoverAt returns hover information for the token at pos, or nil.
+func hoverAt(src string, pos position) interface{} {
+	tok, ok := tokenAt(src, pos)
+	if !ok {
+		return nil
+	}
+
+	var text string
+	switch tok.Kind {
+	case assembler.Mnemonic, assembler.Directive:
+		text = assembler.Describe(tok.Text)
+		if code, _, ok := assembler.Opcode(tok.Text); ok {
+			text = fmt.Sprintf("%s\n\nopcode: %04b", text, code>>4)
+		}
+	case assembler.String:
+		b, err := assembler.Unquote(tok.Text)
+		if err != nil {
+			return nil
+		}
+		if len(b) == 1 {
+			text = valueText(b[0])
+		} else {
+			text = fmt.Sprintf("%d bytes: % x", len(b), b)
+		}
+	case assembler.Number:
+		prog, err := assembler.Parse(" .byte " + tok.Text)
+		if err != nil {
+			return nil
+		}
+		v := prog.Stmts[0].(*assembler.DotDirective).Args[0].(*assembler.Literal).Value
+		text = valueText(v)
+	case assembler.Symbol, assembler.Label:
+		prog, err := assembler.Parse(src)
+		if err != nil {
+			return nil
+		}
+		obj, err := assembler.AssembleObject(prog)
+		if err != nil {
+			return nil
+		}
+		v, ok := obj.Symbols[tok.Text]
+		if !ok {
+			return nil
+		}
+		text = fmt.Sprintf("%s = %s", tok.Text, valueText(v))
+	}
+	if text == "" {
+		return nil
+	}
+
+	var h hover
+	h.Contents.Kind = "plaintext"
+	h.Contents.Value = text
+	h.Range = toRange(src, tok)
+	return h
+}
+
+// completion returns all mnemonics, directives, labels and symbols.
+func completion(src string) []completionItem {
+	items := []completionItem{}
+	for _, m := range assembler.Mnemonics() {
+		items = append(items, completionItem{Label: m, Kind: kindKeyword, Detail: assembler.Describe(m)})
+	}
+	for _, d := range assembler.Directives() {
+		items = append(items, completionItem{Label: d, Kind: kindKeyword, Detail: assembler.Describe(d)})
+	}
+
+	seen := make(map[string]bool)
+	for _, t := range assembler.Tokenize(src) {
+		if (t.Kind == assembler.Label || t.Kind == assembler.Symbol) && !seen[t.Text] {
+			seen[t.Text] = true
+			kind := kindVariable
+			if t.Kind == assembler.Label {
+				kind = kindConstant
+			}
+			items = append(items, completionItem{Label: t.Text, Kind: kind})
+		}
+	}
+	return items
+}
+
+// semanticTypes is the token type legend of the semantic tokens, indexed by
+// assembler.SpanClass. Invalid spans are not reported.
+var semanticTypes = []string{
+	assembler.ClassInstruction: "keyword",
+	assembler.ClassDirective:   "macro",
+	assembler.ClassNumber:      "number",
+	assembler.ClassString:      "string",
+	assembler.ClassLabelDef:    "function",
+	assembler.ClassLabelRef:    "function",
+	assembler.ClassSymbolDef:   "variable",
+	assembler.ClassSymbolRef:   "variable",
+	assembler.ClassComment:     "comment",
+	assembler.ClassPunctuation: "operator",
+}
+
+// semanticTokens returns the semantic tokens of src in the relative integer
+// encoding of the protocol.
+func semanticTokens(src string) []int {
+	data := []int{}
+	var prev position
+	for _, sp := range assembler.Classify(src) {
+		if sp.Class == assembler.ClassInvalid {
+			continue
+		}
+		r := toRange(src, sp.Token)
+		mod := 0
+		if sp.Class == assembler.ClassLabelDef || sp.Class == assembler.ClassSymbolDef {
+			mod = 1
+		}
+		dl, dc := r.Start.Line-prev.Line, r.Start.Character
+		if dl == 0 {
+			dc -= prev.Character
+		}
+		data = append(data, dl, dc, r.End.Character-r.Start.Character, int(sp.Class), mod)
+		prev = r.Start
+	}
+	return data
+}
+
+func valueText(v byte) string {
+	return fmt.Sprintf("%d ($%02x, %%%08b)", v, v, v)
+}
+
+// findDef returns the token defining the label or symbol name.
+func findDef(src, name string) (assembler.Token, bool) {
+	toks := assembler.Tokenize(src)
+	for i, t := range toks {
+		if t.Text != name || t.Pos.Col != 1 {
+			continue
+		}
+		if t.Kind == assembler.Label {
+			return t, true
+		}
+		if t.Kind == assembler.Symbol && i+1 < len(toks) && toks[i+1].Kind == assembler.Equals {
+			return t, true
+		}
+	}
+	return assembler.Token{}, false
+}
+
+// tokenAt returns the token at the LSP position pos.
+func tokenAt(src string, pos position) (assembler.Token, bool) {
+	for _, t := range assembler.Tokenize(src) {
+		r := toRange(src, t)
+		if r.Start.Line == pos.Line && r.Start.Character <= pos.Character && pos.Character <= r.End.Character {
+			return t, true
+		}
+	}
+	return assembler.Token{}, false
+}
+
+// tokenRange returns the range of the token starting at p, or an empty range
+// at p if there is no token.
+func tokenRange(src string, p assembler.Pos) lspRange {
+	for _, t := range assembler.Tokenize(src) {
+		if t.Pos == p {
+			return toRange(src, t)
+		}
+	}
+	start := toPosition(src, p)
+	return lspRange{start, start}
+}
+
+func toRange(src string, t assembler.Token) lspRange {
+	return lspRange{toPosition(src, t.Pos), toPosition(src, t.End())}
+}
+
+// toPosition converts an assembler position to an LSP position, which is
+// 0-based and counts characters in UTF-16 code units.
+func toPosition(src string, p assembler.Pos) position {
+	start := p.Offset - (p.Col - 1)
+	if start < 0 || p.Offset > len(src) {
+		return position{Line: p.Line - 1}
+	}
+	prefix := src[start:p.Offset]
+	n := 0
+	for len(prefix) > 0 {
+		r, w := utf8.DecodeRuneInString(prefix)
+		n += len(utf16.Encode([]rune{r}))
+		prefix = prefix[w:]
+	}
+	return position{Line: p.Line - 1, Character: n}
+}
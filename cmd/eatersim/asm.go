@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oj-mik/eatersim"
+	"github.com/oj-mik/eatersim/assembler"
+)
+
+func runAsm(args []string) error {
+	fs := flag.NewFlagSet("asm", flag.ExitOnError)
+	in := fs.String("i", "in.asm", "path of file to assemble, - for standard input")
+	out := fs.String("o", "a.out", "path of where to store assembled output file, - for standard output")
+	format := fs.String("f", "bin", "output format, bin, hex, c, logisim or table")
+	arrayName := fs.String("name", "program", "name of the array in c output")
+	fill := fs.Uint("fill", 0, "value of memory addresses not used by the program")
+	trim := fs.Bool("trim", false, "only output the range of memory addresses used by the program")
+	listing := fs.Bool("l", false, "write a listing file (.lst) next to the output file")
+	symbols := fs.Bool("sym", false, "write a symbol table file (.sym) next to the output file")
+	srcmap := fs.Bool("map", false, "write a source map file (.map) next to the output file")
+	memmap := fs.Bool("memmap", false, "write a memory map report (.mem) next to the output file, and report all address conflicts instead of the first")
+	lang := fs.String("lang", "extended", "language level of programs without a .lang directive, classic or extended")
+	micro := fs.String("microcode", "", "fail if the program uses instructions the `microcode` does not implement, default or classic")
+	machine := fs.String("machine", "", "assemble for the language level of the `machine` and fail if the program uses instructions its microcode does not implement, unless -lang or -microcode are given: default, stock or a configuration file")
+	fs.Parse(args)
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	if *fill > 0xff {
+		return fmt.Errorf("fill value %v does not fit in a byte", *fill)
+	}
+	level, err := assembler.ParseLevel(*lang)
+	if err != nil {
+		return err
+	}
+	var isa *assembler.ISA
+	if *machine != "" {
+		m, err := machineByName(*machine)
+		if err != nil {
+			return err
+		}
+		if !set["lang"] {
+			level = m.Level
+		}
+		cpu, err := eatersim.New(m.Options()...)
+		if err != nil {
+			return err
+		}
+		v := cpu.ISA()
+		isa = &v
+	}
+
+	var src []byte
+	name := *in
+	if *in == "-" {
+		name = "<stdin>"
+		src, err = io.ReadAll(os.Stdin)
+	} else {
+		src, err = os.ReadFile(*in)
+	}
+	if err != nil {
+		return fmt.Errorf("could not read input file: %s", err)
+	}
+	prog, err := assembler.ParseFile(name, string(src))
+	if err != nil {
+		return err
+	}
+
+	// the listing, symbol and map files are named after the output file, or
+	// after the input file when writing to standard output
+	base := *out
+	if base == "-" {
+		base = *in
+	}
+	if (*listing || *symbols || *srcmap || *memmap) && base == "-" {
+		return errors.New("can not name listing, symbol or map files when using standard input and output")
+	}
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+
+	if *memmap {
+		m, err := assembler.NewMemoryMap(prog, assembler.Options{Level: level})
+		if err != nil {
+			return err
+		}
+		err = writeFile(base+".mem", func(w io.Writer) error { return assembler.WriteMemoryMap(w, string(src), m) })
+		if err != nil {
+			return err
+		}
+		if err := joinErrors(m.Conflicts()); err != nil {
+			return err
+		}
+	}
+	obj, err := assembler.AssembleObjectOptions(prog, assembler.Options{Level: level})
+	if err != nil {
+		return err
+	}
+	if *micro != "" {
+		m, err := microcodeByName(*micro)
+		if err != nil {
+			return err
+		}
+		v := m.ISA()
+		isa = &v
+	}
+	if isa != nil {
+		if err := joinErrors(obj.CheckISA(*isa)); err != nil {
+			return err
+		}
+	}
+
+	for _, w := range obj.CheckJumps(string(src)) {
+		fmt.Fprintf(os.Stderr, "warning: %s (add '; %s' to the line if intended)\n", w, assembler.JumpOK)
+	}
+
+	bin, org := obj.Image(assembler.ImageOptions{Fill: byte(*fill), Trim: *trim})
+	if org != 0 {
+		fmt.Fprintf(os.Stderr, "Output starts at address $%x\n", org)
+	}
+
+	outfile := os.Stdout
+	if *out != "-" {
+		outfile, err = os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("could not create output file: %s", err)
+		}
+		defer outfile.Close()
+	}
+
+	if *listing {
+		err = writeFile(base+".lst", func(w io.Writer) error { return assembler.WriteListing(w, string(src), obj) })
+		if err != nil {
+			return err
+		}
+	}
+	if *symbols {
+		err = writeFile(base+".sym", func(w io.Writer) error { return assembler.WriteSymbols(w, obj) })
+		if err != nil {
+			return err
+		}
+	}
+	if *srcmap {
+		err = writeFile(base+".map", func(w io.Writer) error {
+			_, err := obj.SourceMap.WriteTo(w)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	switch *format {
+	case "bin":
+		_, err = outfile.Write(bin)
+	case "hex":
+		err = assembler.WriteIntelHex(outfile, bin, org)
+	case "c":
+		err = assembler.WriteC(outfile, bin, *arrayName)
+	case "logisim":
+		err = assembler.WriteLogisim(outfile, bin)
+	case "table":
+		err = assembler.WriteTable(outfile, bin, org)
+	default:
+		err = fmt.Errorf("unknown output format %s", *format)
+	}
+	if err != nil {
+		return fmt.Errorf("could not write to output file: %s", err)
+	}
+	return nil
+}
+
+// writeFile creates the file at path and writes it with write.
+func writeFile(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create %s: %s", path, err)
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("could not write %s: %s", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/oj-mik/eatersim"
+	"github.com/oj-mik/eatersim/assembler"
+)
+
+// benchProgram is the standard workload of the bench command. It never halts
+// and uses every instruction except NOP and HLT.
+const benchProgram = `start:
+  ldi 0
+loop:
+  out
+  add three
+  sta x
+  sub three
+  lda x
+  jc start
+  jz start
+  jmp loop
+  .org 14
+three:
+  .byte 3
+x:
+  .byte 0
+`
+
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	dur := fs.Duration("t", 3*time.Second, "duration of the benchmark")
+	fs.Parse(args)
+
+	bin, err := assembler.Assemble(benchProgram)
+	if err != nil {
+		return err
+	}
+	cpu := eatersim.NewBBCpu()
+	cpu.RAM.Write(bin)
+
+	// run in batches to keep the cost of reading the clock low
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for time.Since(start) < *dur {
+		for i := 0; i < 10000; i++ {
+			cpu.Exec()
+		}
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	cycles := float64(cpu.Cycles)
+	fmt.Printf("cycles:       %d in %v\n", cpu.Cycles, elapsed.Round(time.Millisecond))
+	fmt.Printf("simulated Hz: %.0f\n", cycles/elapsed.Seconds())
+	fmt.Printf("allocations:  %.3f allocs/cycle, %.3f bytes/cycle\n",
+		float64(after.Mallocs-before.Mallocs)/cycles, float64(after.TotalAlloc-before.TotalAlloc)/cycles)
+
+	fmt.Printf("\nper board cost of one half cycle:\n")
+	boards := []struct {
+		name string
+		exec func()
+	}{
+		{"clk", cpu.CLK.Exec},
+		{"cl", cpu.CL.Exec},
+		{"areg", cpu.Areg.Exec},
+		{"breg", cpu.Breg.Exec},
+		{"oreg", cpu.Oreg.Exec},
+		{"alu", cpu.ALU.Exec},
+		{"mar", cpu.MAR.Exec},
+		{"ram", cpu.RAM.Exec},
+		{"pc", cpu.PC.Exec},
+		{"ir", cpu.IR.Exec},
+	}
+
+	// each board is timed on its own with the clock signal toggling, and the
+	// cost of the loop itself is subtracted
+	const n = 1000000
+	overhead := timeLoop(cpu, n, func() {})
+	costs := make([]time.Duration, len(boards))
+	var total time.Duration
+	for i, b := range boards {
+		costs[i] = timeLoop(cpu, n, b.exec) - overhead
+		if costs[i] < 0 {
+			costs[i] = 0
+		}
+		total += costs[i]
+	}
+	for i, b := range boards {
+		share := 0.0
+		if total > 0 {
+			share = 100 * float64(costs[i]) / float64(total)
+		}
+		fmt.Printf("  %-5s %6.2f ns %5.1f%%\n", b.name, float64(costs[i])/n, share)
+	}
+	return nil
+}
+
+// timeLoop returns the time it takes to toggle the clock and call exec n
+// times.
+func timeLoop(cpu *eatersim.BBCpu, n int, exec func()) time.Duration {
+	clk := cpu.CLK.CLK
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		cpu.CLK.CLK = !cpu.CLK.CLK
+		exec()
+	}
+	d := time.Since(start)
+	cpu.CLK.CLK = clk
+	return d
+}
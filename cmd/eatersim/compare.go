@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/oj-mik/eatersim"
+)
+
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: eatersim compare [flags] capture.csv program\n\n"+
+			"Compares a logic analyzer capture of a physical build, exported as CSV,\n"+
+			"with the simulation of the program and reports the first clock cycle in\n"+
+			"which they differ. Channels are mapped to signals by -channels, e.g.\n\n"+
+			"\t-channels 'Channel 0=CLK,Channel 1=D0,Channel 9=~AO'\n\n"+
+			"where ~ marks an active low line. Channels named like signals are mapped\n"+
+			"by their name.\n\n")
+		fs.PrintDefaults()
+	}
+	channels := fs.String("channels", "", "comma separated `channel=signal` mapping")
+	maxOffset := fs.Int("max-offset", 100, "maximum number of simulated cycles to skip to align the capture")
+	sel := machineFlag(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		return fmt.Errorf("expecting a capture and a program file, got %v files", fs.NArg())
+	}
+
+	m := make(map[string]string)
+	if *channels != "" {
+		for _, kv := range strings.Split(*channels, ",") {
+			i := strings.LastIndexByte(kv, '=')
+			if i < 0 {
+				return fmt.Errorf("invalid channel mapping %s", kv)
+			}
+			m[strings.TrimSpace(kv[:i])] = strings.TrimSpace(kv[i+1:])
+		}
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	c, err := eatersim.ReadCapture(f, m)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %s", fs.Arg(0), err)
+	}
+	if len(c.Cycles) == 0 {
+		return fmt.Errorf("%s: no clock cycles captured", fs.Arg(0))
+	}
+
+	cpu, _, err := newMachine(fs.Arg(1), sel)
+	if err != nil {
+		return err
+	}
+	off, err := c.Compare(cpu, *maxOffset)
+	if err != nil {
+		return &exitError{1, fmt.Errorf("capture aligned at simulated cycle %d, %s", off, err)}
+	}
+	fmt.Printf("%d captured cycles match the simulation from cycle %d\n", len(c.Cycles), off)
+	return nil
+}
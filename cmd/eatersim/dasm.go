@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/oj-mik/eatersim/assembler"
+)
+
+func runDasm(args []string) error {
+	fs := flag.NewFlagSet("dasm", flag.ExitOnError)
+	in := fs.String("i", "a.out", "path of binary, Intel HEX (.hex) or address/data table (.tbl) file to disassemble")
+	out := fs.String("o", "", "path of where to store the disassembled source, standard output if empty")
+	symfile := fs.String("sym", "", "path of symbol file to name addresses with")
+	origin := fs.Int("org", -1, "memory address of the first byte, overrides the address in HEX files")
+	fs.Parse(args)
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		return fmt.Errorf("could not open input file: %s", err)
+	}
+
+	var opts assembler.DisasmOptions
+	bin := data
+	switch {
+	case strings.HasSuffix(strings.ToLower(*in), ".hex"):
+		bin, opts.Origin, err = assembler.ReadIntelHex(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("could not read HEX file: %s", err)
+		}
+	case strings.HasSuffix(strings.ToLower(*in), ".tbl"):
+		bin, err = assembler.ReadTable(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("could not read table file: %s", err)
+		}
+	}
+	if *origin >= 0 {
+		opts.Origin = *origin
+	}
+
+	if *symfile != "" {
+		f, err := os.Open(*symfile)
+		if err != nil {
+			return fmt.Errorf("could not open symbol file: %s", err)
+		}
+		opts.Symbols, opts.Labels, err = assembler.ReadSymbols(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("could not read symbol file: %s", err)
+		}
+	}
+
+	outfile := os.Stdout
+	if *out != "" {
+		outfile, err = os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("could not create output file: %s", err)
+		}
+		defer outfile.Close()
+	}
+
+	return assembler.Disassemble(outfile, bin, opts)
+}
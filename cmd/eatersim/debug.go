@@ -0,0 +1,530 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/oj-mik/eatersim"
+)
+
+const debugHelp = `commands:
+  step [n]          execute n instructions, default 1
+  micro [n]         execute n micro instructions, default 1
+  next [n]          execute n instructions, running backward jumps as a
+                    unit until the instruction after the jump, default 1
+  run               run until halt or breakpoint
+  until addr        run until the instruction at addr is next
+  break [addr [if expr]]
+                    set a breakpoint at addr, stopping only if expr is
+                    true if given, or list breakpoints
+  clear addr        remove the breakpoint at addr
+  sbreak [expr]     stop run at the half step expr starts to hold, e.g.
+                    'RI && MAR == $e', or list signal breakpoints
+  sclear n          remove signal breakpoint number n
+  trigger pattern [signals]
+                    stop run when the bus matches pattern while signals
+                    are active, e.g. '%1111xxxx RO' or '$ff AO|OI'
+  watch [expr]      print expr after each command, or list watches
+  unwatch n         remove watch number n
+  print expr        print the value of expr
+  peek [addr]       print memory at addr, or all memory
+  poke addr value   store value in memory at addr
+  set reg value     set register a, b, out, ir, mar or pc to value
+  undo              revert the last poke or set
+  redo              apply the last undone poke or set again
+  regs              print the registers
+  state             print the state of all boards
+  reset             reset the cpu
+  snap [name]       save the state as snapshot name, or list snapshots
+  diff a [b]        print the differences between snapshots a and b, or
+                    between snapshot a and the current state
+  manual [on|off]   disengage or engage the control logic, or show
+                    whether it is disengaged
+  lines signals     drive the control lines by hand while the control
+                    logic is disengaged, e.g. 'CO|MI' or 'none'
+  load file         load a program and reset the cpu
+  verify            compare memory with the loaded program
+  save file         save the session: the state, breakpoints, watches,
+                    snapshots and labels
+  restore file      continue a session saved to file
+  help              print this help
+  quit              leave the debugger
+numbers may be decimal, $hexadecimal or %binary
+expressions combine registers, flags, MEM[addr] and labels, e.g. A+B or PC==loop`
+
+// monitor is an interactive debugging session.
+type monitor struct {
+	dbg *eatersim.Debugger
+	mf  machineFlags
+	out io.Writer
+
+	// image of the loaded program, nil if none was loaded
+	image []byte
+
+	watches []*eatersim.Expr
+}
+
+func runDebug(args []string) error {
+	fs := flag.NewFlagSet("debug", flag.ExitOnError)
+	var mf machineFlags
+	mf.register(fs)
+	persist := fs.String("persist", "", "keep the memory in `file` like a battery backed memory, loaded at start and saved whenever the cpu halts")
+	manual := fs.Bool("manual", false, "start without the control logic, the control lines are driven with the lines command")
+	fs.Parse(args)
+
+	opts := mf.sel.options()
+	if *persist != "" {
+		opts = append(opts, eatersim.WithPersistentMemory(*persist))
+	}
+	if *manual {
+		opts = append(opts, eatersim.WithManualControl())
+	}
+	cpu, err := eatersim.New(opts...)
+	if err != nil {
+		return err
+	}
+	m := &monitor{dbg: eatersim.NewDebugger(cpu), mf: mf, out: os.Stdout}
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		if err := m.load(fs.Arg(0)); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("expecting at most one program file, got %v", fs.NArg())
+	}
+
+	fmt.Fprintln(m.out, "type help for a list of commands")
+	in := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Fprint(m.out, "> ")
+		if !in.Scan() {
+			fmt.Fprintln(m.out)
+			if err := in.Err(); err != nil {
+				return err
+			}
+			return cpu.PersistErr()
+		}
+		f := strings.Fields(in.Text())
+		if len(f) == 0 {
+			continue
+		}
+		if f[0] == "quit" || f[0] == "q" {
+			return cpu.PersistErr()
+		}
+		if err := m.exec(f[0], f[1:]); err != nil {
+			fmt.Fprintln(m.out, err)
+		}
+		for _, w := range m.watches {
+			fmt.Fprintf(m.out, "  %s = %d\n", w, w.Eval(m.dbg.CPU.State()))
+		}
+	}
+}
+
+// exec executes a single monitor command.
+func (m *monitor) exec(cmd string, args []string) error {
+	d := m.dbg
+	switch cmd {
+	case "step", "s":
+		n, err := countArg(args)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n && !d.CPU.CL.HLT; i++ {
+			addr := d.PC()
+			d.Step()
+			fmt.Fprintln(m.out, traceLine(d.CPU, addr)+m.annotation(addr))
+		}
+	case "micro", "m":
+		n, err := countArg(args)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n && !d.CPU.CL.HLT; i++ {
+			d.Micro()
+			fmt.Fprintf(m.out, "%-16s BUS=$%02x\n", d.CPU.CL.MicroOp(), d.CPU.BUS)
+		}
+	case "next", "n":
+		n, err := countArg(args)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n && !d.CPU.CL.HLT; i++ {
+			addr := d.PC()
+			unit := d.StepsOver(addr)
+			reason := d.StepOver(m.mf.maxCycles)
+			if unit || (reason != eatersim.StopReached && reason != eatersim.StopHalt) {
+				m.stopped(reason)
+				if reason != eatersim.StopReached {
+					break
+				}
+				continue
+			}
+			fmt.Fprintln(m.out, traceLine(d.CPU, addr)+m.annotation(addr))
+		}
+	case "until", "u":
+		if len(args) != 1 {
+			return errors.New("usage: until addr")
+		}
+		addr, err := m.addrArg(args[0])
+		if err != nil {
+			return err
+		}
+		m.stopped(d.RunTo(addr, m.mf.maxCycles))
+	case "run", "r":
+		m.stopped(d.Run(m.mf.maxCycles))
+	case "break", "b":
+		if len(args) == 0 {
+			for _, addr := range d.Breakpoints() {
+				if cond := d.Condition(addr); cond != nil {
+					fmt.Fprintf(m.out, "$%x if %s%s\n", addr, cond, m.annotation(addr))
+				} else {
+					fmt.Fprintf(m.out, "$%x%s\n", addr, m.annotation(addr))
+				}
+			}
+			return nil
+		}
+		addr, err := m.addrArg(args[0])
+		if err != nil {
+			return err
+		}
+		if len(args) == 1 {
+			d.SetBreakpoint(addr)
+			return nil
+		}
+		if args[1] != "if" || len(args) == 2 {
+			return errors.New("usage: break addr [if expr]")
+		}
+		cond, err := eatersim.ParseExpr(strings.Join(args[2:], " "), m.dbg.Symbols())
+		if err != nil {
+			return err
+		}
+		d.SetConditionalBreakpoint(addr, cond)
+	case "sbreak":
+		if len(args) == 0 {
+			for i, cond := range d.SignalBreakpoints() {
+				fmt.Fprintf(m.out, "%d: %s\n", i+1, cond)
+			}
+			return nil
+		}
+		cond, err := eatersim.ParseExpr(strings.Join(args, " "), m.dbg.Symbols())
+		if err != nil {
+			return err
+		}
+		d.AddSignalBreakpoint(cond)
+	case "trigger":
+		t, err := eatersim.ParseTrigger(strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+		d.AddSignalBreakpoint(t.Expr())
+	case "sclear":
+		n, err := countArg(args)
+		if err != nil || n < 1 || n > len(d.SignalBreakpoints()) {
+			return errors.New("usage: sclear n")
+		}
+		d.RemoveSignalBreakpoint(n - 1)
+	case "watch", "w":
+		if len(args) == 0 {
+			for i, w := range m.watches {
+				fmt.Fprintf(m.out, "%d: %s\n", i+1, w)
+			}
+			return nil
+		}
+		w, err := eatersim.ParseExpr(strings.Join(args, " "), m.dbg.Symbols())
+		if err != nil {
+			return err
+		}
+		m.watches = append(m.watches, w)
+	case "unwatch":
+		n, err := countArg(args)
+		if err != nil || n < 1 || n > len(m.watches) {
+			return errors.New("usage: unwatch n")
+		}
+		m.watches = append(m.watches[:n-1], m.watches[n:]...)
+	case "print":
+		e, err := eatersim.ParseExpr(strings.Join(args, " "), m.dbg.Symbols())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(m.out, e.Eval(d.CPU.State()))
+	case "clear":
+		if len(args) != 1 {
+			return errors.New("usage: clear addr")
+		}
+		addr, err := m.addrArg(args[0])
+		if err != nil {
+			return err
+		}
+		d.ClearBreakpoint(addr)
+	case "peek", "p":
+		if len(args) == 0 {
+			for addr := byte(0); addr < 0x10; addr++ {
+				v := d.Peek(addr)
+				fmt.Fprintln(m.out, m.memLine(addr, v))
+			}
+			return nil
+		}
+		addr, err := m.addrArg(args[0])
+		if err != nil {
+			return err
+		}
+		v := d.Peek(addr)
+		fmt.Fprintln(m.out, m.memLine(addr, v))
+	case "poke":
+		if len(args) != 2 {
+			return errors.New("usage: poke addr value")
+		}
+		addr, err := m.addrArg(args[0])
+		if err != nil {
+			return err
+		}
+		v, err := parseNum(args[1], 8)
+		if err != nil {
+			return err
+		}
+		d.Poke(addr, v)
+	case "set":
+		if len(args) != 2 {
+			return errors.New("usage: set reg value")
+		}
+		v, err := parseNum(args[1], 8)
+		if err != nil {
+			return err
+		}
+		return d.SetRegister(args[0], v)
+	case "undo", "redo":
+		undo := d.Undo
+		if cmd == "redo" {
+			undo = d.Redo
+		}
+		c, err := undo()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(m.out, "%s %s\n", cmd, c)
+	case "regs":
+		st := d.CPU.State()
+		fmt.Fprintf(m.out, "PC=$%x IR=$%02x (%s) MAR=$%x A=$%02x B=$%02x ALU=$%02x OUT=%d CF=%d ZF=%d T%d cycles=%d\n",
+			st.PC, st.IR, instrText(st.IR), st.MAR, st.A, st.B, st.ALU, st.Out, b2i(st.CF), b2i(st.ZF), st.Step, st.Cycles)
+	case "state":
+		fmt.Fprintln(m.out, d.CPU)
+	case "reset":
+		d.Reset()
+	case "load":
+		if len(args) != 1 {
+			return errors.New("usage: load file")
+		}
+		return m.load(args[0])
+	case "manual":
+		switch {
+		case len(args) == 0:
+			state := "off"
+			if d.CPU.Manual() {
+				state = "on"
+			}
+			fmt.Fprintf(m.out, "manual control %s\n", state)
+		case len(args) == 1 && (args[0] == "on" || args[0] == "off"):
+			d.CPU.SetManual(args[0] == "on")
+		default:
+			return errors.New("usage: manual [on|off]")
+		}
+	case "lines":
+		if len(args) != 1 {
+			return errors.New("usage: lines signals")
+		}
+		w, err := eatersim.ParseControlWord(args[0])
+		if err != nil {
+			return err
+		}
+		return d.CPU.SetControl(w)
+	case "snap":
+		if len(args) == 0 {
+			for _, name := range d.Snapshots() {
+				s, _ := d.SnapshotNamed(name)
+				fmt.Fprintf(m.out, "%s: cycle %d\n", name, s.Cycles)
+			}
+			return nil
+		}
+		if len(args) != 1 {
+			return errors.New("usage: snap [name]")
+		}
+		d.Snapshot(args[0])
+	case "diff":
+		if len(args) < 1 || len(args) > 2 {
+			return errors.New("usage: diff a [b]")
+		}
+		a, ok := d.SnapshotNamed(args[0])
+		if !ok {
+			return fmt.Errorf("no snapshot %s", args[0])
+		}
+		b := d.CPU.State()
+		if len(args) == 2 {
+			if b, ok = d.SnapshotNamed(args[1]); !ok {
+				return fmt.Errorf("no snapshot %s", args[1])
+			}
+		}
+		changes := eatersim.Diff(a, b)
+		if len(changes) == 0 {
+			fmt.Fprintln(m.out, "no differences")
+		}
+		for _, c := range changes {
+			fmt.Fprintln(m.out, c)
+		}
+	case "save", "restore":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: %s file", cmd)
+		}
+		if cmd == "save" {
+			return m.save(args[0])
+		}
+		return m.restore(args[0])
+	case "verify":
+		if m.image == nil {
+			return errors.New("no program loaded")
+		}
+		if err := d.CPU.RAM.Verify(m.image); err != nil {
+			return err
+		}
+		fmt.Fprintf(m.out, "memory matches the program, checksum $%04x\n", d.CPU.RAM.Checksum())
+	case "help", "h", "?":
+		io.WriteString(m.out, debugHelp+"\n")
+	default:
+		return fmt.Errorf("unknown command %s, type help for a list of commands", cmd)
+	}
+	return nil
+}
+
+func (m *monitor) load(path string) error {
+	p, err := loadProgram(path, m.mf.sel.level())
+	if err != nil {
+		return err
+	}
+	m.image = p.Bin
+	if p.Obj != nil {
+		return m.dbg.LoadObject(p.Obj)
+	}
+	return m.dbg.Load(p.Bin)
+}
+
+// save writes the session to the file at path.
+func (m *monitor) save(path string) error {
+	s := m.dbg.Session()
+	for _, w := range m.watches {
+		s.Watches = append(s.Watches, w.String())
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := s.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// restore continues the session saved to the file at path.
+func (m *monitor) restore(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s, err := eatersim.ReadSession(f)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	var watches []*eatersim.Expr
+	for _, src := range s.Watches {
+		var symbols map[string]byte
+		if s.Program != nil {
+			symbols = s.Program.Symbols
+		}
+		w, err := eatersim.ParseExpr(src, symbols)
+		if err != nil {
+			return fmt.Errorf("%s: watch %s: %s", path, src, err)
+		}
+		watches = append(watches, w)
+	}
+	if err := m.dbg.RestoreSession(s); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	m.watches = watches
+	m.image = nil
+	if s.Program != nil {
+		m.image = s.Program.Bin
+	}
+	return nil
+}
+
+// addrArg parses an address given as a number or as a symbol of the loaded
+// program.
+func (m *monitor) addrArg(s string) (byte, error) {
+	if v, ok := m.dbg.Symbols()[s]; ok {
+		return v & 0x0f, nil
+	}
+	return addrArg(s)
+}
+
+// stopped reports why the cpu stopped running.
+func (m *monitor) stopped(reason eatersim.StopReason) {
+	d := m.dbg
+	if reason == eatersim.StopSignal {
+		fmt.Fprintf(m.out, "%s %s, T%d %s BUS=$%02x\n", reason, d.SignalHit(), d.CPU.CL.Cnt, d.CPU.CL.Word(), d.CPU.BUS)
+		return
+	}
+	fmt.Fprintf(m.out, "%s at $%x%s\n", reason, d.PC(), m.annotation(d.PC()))
+}
+
+// memLine describes the value v in memory at addr.
+func (m *monitor) memLine(addr, v byte) string {
+	if a := m.annotation(addr); a != "" {
+		return fmt.Sprintf("$%x: $%02x %08b %-9s%s", addr, v, v, instrText(v), a)
+	}
+	return fmt.Sprintf("$%x: $%02x %08b %s", addr, v, v, instrText(v))
+}
+
+// annotation returns the annotation of addr in the loaded program as a
+// comment, see Debugger.Annotate, or an empty string.
+func (m *monitor) annotation(addr byte) string {
+	if a := m.dbg.Annotate(addr); a != "" {
+		return "  ; " + a
+	}
+	return ""
+}
+
+// countArg returns the optional repeat count in args, 1 if there is none.
+func countArg(args []string) (int, error) {
+	if len(args) == 0 {
+		return 1, nil
+	}
+	return strconv.Atoi(args[0])
+}
+
+func addrArg(s string) (byte, error) {
+	return parseNum(s, 4)
+}
+
+// parseNum parses a decimal, $hexadecimal or %binary number of at most
+// bitSize bits.
+func parseNum(s string, bitSize int) (byte, error) {
+	base := 10
+	switch {
+	case strings.HasPrefix(s, "$"):
+		s, base = s[1:], 16
+	case strings.HasPrefix(s, "%"):
+		s, base = s[1:], 2
+	}
+	v, err := strconv.ParseUint(s, base, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %s", s)
+	}
+	return byte(v), nil
+}
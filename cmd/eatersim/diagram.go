@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/oj-mik/eatersim"
+)
+
+func runDiagram(args []string) error {
+	fs := flag.NewFlagSet("diagram", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: eatersim diagram [flags] [program]\n\n"+
+			"Writes the architecture of the cpu as a Graphviz DOT graph to standard\n"+
+			"output. With -live, the program is run and a graph is written after\n"+
+			"every clock cycle, highlighting the active data paths, e.g.\n\n"+
+			"\teatersim diagram -live prog.asm | dot -Tpng -O\n\n")
+		fs.PrintDefaults()
+	}
+	live := fs.Bool("live", false, "run the program and write a graph after every clock cycle")
+	maxCycles := fs.Uint64("max-cycles", 1000, "maximum number of clock cycles to run in live mode, 0 for no limit")
+	fs.Parse(args)
+
+	cpu := eatersim.NewBBCpu()
+	switch len(fs.Args()) {
+	case 0:
+	case 1:
+		var err error
+		if cpu, _, err = newMachine(fs.Arg(0), nil); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("expecting at most one program file, got %v", len(fs.Args()))
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	if err := eatersim.WriteDot(w, cpu.State()); err != nil {
+		return err
+	}
+	start := cpu.Cycles
+	for *live && !cpu.CL.HLT && (*maxCycles == 0 || cpu.Cycles-start < *maxCycles) {
+		cpu.Step()
+		if err := eatersim.WriteDot(w, cpu.State()); err != nil {
+			return err
+		}
+		// flush each graph, so that consumers may render it right away
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/oj-mik/eatersim/assembler"
+	"github.com/oj-mik/eatersim/ref"
+)
+
+func runFuzz(args []string) error {
+	fs := flag.NewFlagSet("fuzz", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: eatersim fuzz [flags]\n\n"+
+			"Runs random programs on the emulator and on a behavioral reference model\n"+
+			"and reports the programs on which they differ, hang or panic.\n\n")
+		fs.PrintDefaults()
+	}
+	n := fs.Int("n", 10000, "number of programs to run")
+	seed := fs.Int64("seed", 0, "seed of the random programs, 0 for a random seed")
+	maxSteps := fs.Int("max-steps", 1000, "maximum number of instructions to run each program for")
+	fs.Parse(args)
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	r := rand.New(rand.NewSource(*seed))
+
+	failed := 0
+	for i := 0; i < *n; i++ {
+		bin := ref.Generate(r)
+		if err := ref.Check(bin, *maxSteps); err != nil {
+			failed++
+			fmt.Printf("--- program %d: %s\n", i, err)
+			assembler.Disassemble(os.Stdout, bin, assembler.DisasmOptions{})
+		}
+	}
+	if failed > 0 {
+		return &exitError{1, fmt.Errorf("%d of %d programs failed with seed %d", failed, *n, *seed)}
+	}
+	fmt.Printf("ok   %d programs with seed %d\n", *n, *seed)
+	return nil
+}
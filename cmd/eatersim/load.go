@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oj-mik/eatersim/assembler"
+)
+
+// program is a program loaded from a file, ready to be written to memory.
+type program struct {
+	// Bin is the memory image, always 16 bytes
+	Bin []byte
+
+	// Obj is the assembled object if the program was loaded from source, or
+	// nil
+	Obj *assembler.Object
+}
+
+// loadProgram loads an assembly source (.asm), Intel HEX (.hex), address/data
+// table (.tbl) or raw binary file into a memory image. Sources are assembled
+// for the language level level unless they select their own.
+func loadProgram(path string, level assembler.Level) (*program, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &program{Bin: make([]byte, 16)}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".asm", ".s":
+		prog, err := assembler.ParseFile(path, string(data))
+		if err != nil {
+			return nil, err
+		}
+		p.Obj, err = assembler.AssembleObjectOptions(prog, assembler.Options{Level: level})
+		if err != nil {
+			return nil, err
+		}
+		if len(p.Obj.Bin) > len(p.Bin) {
+			return nil, fmt.Errorf("%s: language level %s needs %d bytes of memory, the cpu has %d", path, p.Obj.Level, len(p.Obj.Bin), len(p.Bin))
+		}
+		copy(p.Bin, p.Obj.Bin)
+	case ".hex":
+		bin, base, err := assembler.ReadIntelHex(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if base+len(bin) > len(p.Bin) {
+			return nil, fmt.Errorf("%s: program exceeds registry size of 16 bytes", path)
+		}
+		copy(p.Bin[base:], bin)
+	case ".tbl":
+		bin, err := assembler.ReadTable(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		copy(p.Bin, bin)
+	default:
+		if len(data) > len(p.Bin) {
+			return nil, fmt.Errorf("%s: program exceeds registry size of 16 bytes", path)
+		}
+		copy(p.Bin, data)
+	}
+	return p, nil
+}
+
+// programArg returns the single program path in args.
+func programArg(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("expecting exactly one program file, got %v", len(args))
+	}
+	return args[0], nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/oj-mik/eatersim"
+	"github.com/oj-mik/eatersim/assembler"
+)
+
+// machineSel is the machine selected by the -machine flag, with the behavior
+// of the flags selected by -sub-carry and -zero-from overriding it.
+type machineSel struct {
+	machine  eatersim.Machine
+	subCarry *eatersim.CarryPolarity
+	zeroFrom *eatersim.ZeroSource
+}
+
+// machineFlag registers the -machine, -sub-carry and -zero-from flags.
+func machineFlag(fs *flag.FlagSet) *machineSel {
+	s := new(machineSel)
+	s.machine, _ = eatersim.MachinePreset("default")
+	fs.Func("machine", "the `machine` to emulate, default, stock for the microcode and flags of Ben's build, or a configuration file", func(v string) (err error) {
+		s.machine, err = machineByName(v)
+		return err
+	})
+	fs.Func("sub-carry", "set the carry flag on a subtraction if it borrows, or like the real hardware if it does not, `borrow` or no-borrow, overriding -machine", func(v string) error {
+		p, err := eatersim.ParseCarryPolarity(v)
+		s.subCarry = &p
+		return err
+	})
+	fs.Func("zero-from", "compute the zero flag from the result of the `alu`, or like the real hardware from the bus, overriding -machine", func(v string) error {
+		z, err := eatersim.ParseZeroSource(v)
+		s.zeroFrom = &z
+		return err
+	})
+	return s
+}
+
+// machineByName returns the machine preset called name, or the machine
+// described by the configuration file name.
+func machineByName(name string) (eatersim.Machine, error) {
+	if m, err := eatersim.MachinePreset(name); err == nil {
+		return m, nil
+	}
+	if _, err := os.Stat(name); err != nil {
+		return eatersim.Machine{}, fmt.Errorf("unknown machine %s, expecting %s or a configuration file", name, strings.Join(eatersim.MachinePresets(), ", "))
+	}
+	return eatersim.LoadMachine(name)
+}
+
+// options returns the options creating the cpu of the selected machine. A nil
+// selection selects the default machine.
+func (s *machineSel) options() []eatersim.Option {
+	if s == nil {
+		return nil
+	}
+	opts := s.machine.Options()
+	if s.subCarry != nil {
+		opts = append(opts, eatersim.WithSubCarry(*s.subCarry))
+	}
+	if s.zeroFrom != nil {
+		opts = append(opts, eatersim.WithZeroSource(*s.zeroFrom))
+	}
+	return opts
+}
+
+// level returns the language level programs are assembled for.
+func (s *machineSel) level() assembler.Level {
+	if s == nil {
+		return assembler.LevelDefault
+	}
+	return s.machine.Level
+}
@@ -0,0 +1,100 @@
+// eatersim is the command line toolchain for Ben Eater's 8-bit breadboard CPU.
+//
+// Usage:
+//
+//	eatersim <command> [flags]
+//
+// The commands are:
+//
+//	asm        assemble a source file
+//	dasm       disassemble a binary or Intel HEX file
+//	run        run a program until it halts and print the output register
+//	trace      run a program and print the machine state after each instruction
+//	debug      step through a program interactively
+//	test       check programs against their expected outputs
+//	bench      measure the simulated clock rate
+//	panel      show the front panel LEDs of a running program
+//	replay     replay a recorded panel session
+//	record     draw the LED panel of a run into an animated GIF
+//	serve      serve the cpu over HTTP and WebSocket
+//	diagram    write the architecture as a Graphviz graph, live while running
+//	compare    compare a logic analyzer capture of a build with the simulation
+//	fuzz       check random programs against a behavioral reference model
+//	microcode  print and validate the microcode table
+//	script     run test bench scripts
+//
+// Programs passed to the commands executing them may be assembly source (.asm),
+// Intel HEX (.hex), address/data tables (.tbl) as used in Ben's videos, or raw
+// binary files. Run 'eatersim <command> -h' for the flags of each command.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// command is a subcommand of eatersim.
+type command struct {
+	name  string
+	short string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"asm", "assemble a source file", runAsm},
+	{"dasm", "disassemble a binary or Intel HEX file", runDasm},
+	{"run", "run a program until it halts and print the output register", runRun},
+	{"trace", "run a program and print the machine state after each instruction", runTrace},
+	{"debug", "step through a program interactively", runDebug},
+	{"test", "check programs against their expected outputs", runTest},
+	{"bench", "measure the simulated clock rate", runBench},
+	{"panel", "show the front panel LEDs of a running program", runPanel},
+	{"replay", "replay a recorded panel session", runReplay},
+	{"record", "draw the LED panel of a run into an animated GIF", runRecord},
+	{"serve", "serve the cpu over HTTP and WebSocket", runServe},
+	{"diagram", "write the architecture as a Graphviz graph, live while running", runDiagram},
+	{"compare", "compare a logic analyzer capture of a build with the simulation", runCompare},
+	{"fuzz", "check random programs against a behavioral reference model", runFuzz},
+	{"microcode", "print and validate the microcode table", runMicrocode},
+	{"script", "run test bench scripts", runScript},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: eatersim <command> [flags]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.short)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			err := c.run(os.Args[2:])
+			if e, ok := err.(*exitError); ok {
+				if e.err != nil {
+					fmt.Fprintf(os.Stderr, "eatersim %s: %s\n", c.name, e.err)
+				}
+				os.Exit(e.status)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "eatersim %s: %s\n", c.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	switch os.Args[1] {
+	case "help", "-h", "-help", "--help":
+		usage()
+		return
+	}
+	fmt.Fprintf(os.Stderr, "eatersim: unknown command %q\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/oj-mik/eatersim"
+	"github.com/oj-mik/eatersim/programs"
+)
+
+// microcodeByName returns the microcode called name, default for the microcode
+// of the control logic, classic for the microcode of Ben's build, or else the
+// microcode table in the file name, in the format printed by the microcode
+// command.
+func microcodeByName(name string) (eatersim.Microcode, error) {
+	switch name {
+	case "default":
+		return eatersim.NewBBCpu().CL.Microcode(), nil
+	case "classic":
+		return eatersim.ClassicMicrocode(), nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return eatersim.Microcode{}, fmt.Errorf("unknown microcode %s, expecting default, classic or a file", name)
+	}
+	defer f.Close()
+	m, err := eatersim.ParseMicrocode(f)
+	if err != nil {
+		return m, fmt.Errorf("%s: %s", name, err)
+	}
+	return m, nil
+}
+
+func runMicrocode(args []string) error {
+	fs := flag.NewFlagSet("microcode", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: eatersim microcode [flags]\n\n"+
+			"Prints the microcode table of the control logic and validates it. With\n"+
+			"-timing, prints the number of clock cycles of each instruction instead.\n"+
+			"With -compare, runs the demo programs with the old microcode given to\n"+
+			"-compare and the new one given to -microcode, and prints a table of the\n"+
+			"differences in their outputs, final state and clock cycles.\n\n")
+		fs.PrintDefaults()
+	}
+	timing := fs.Bool("timing", false, "print the clock cycles of each instruction")
+	name := fs.String("microcode", "default", "the `microcode` to print, default, classic for the microcode of Ben's build, or a file")
+	compare := fs.String("compare", "", "compare the demo programs run with the `old` microcode, default, classic or a file, to -microcode")
+	fs.Parse(args)
+
+	m, err := microcodeByName(*name)
+	if err != nil {
+		return err
+	}
+	if *compare != "" {
+		old, err := microcodeByName(*compare)
+		if err != nil {
+			return err
+		}
+		diffs, err := programs.CompareMicrocode(old, m)
+		if err != nil {
+			return err
+		}
+		if len(diffs) == 0 {
+			fmt.Println("no differences")
+			return nil
+		}
+		programs.WriteDifferences(os.Stdout, diffs)
+		return &exitError{1, fmt.Errorf("%d differences", len(diffs))}
+	}
+	if *timing {
+		t := m.Timing()
+		fmt.Println(t.String())
+		return nil
+	}
+	// the findings are comments, so that the output can be edited and read
+	// back with -microcode
+	fmt.Println(m.String())
+	fmt.Println("# implements", m.ISA())
+
+	issues := m.Validate()
+	for _, i := range issues {
+		fmt.Println("#", i)
+	}
+	if len(issues) > 0 {
+		return &exitError{1, fmt.Errorf("%d issues in microcode", len(issues))}
+	}
+	fmt.Println("# no issues")
+	return nil
+}
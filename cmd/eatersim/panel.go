@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/oj-mik/eatersim"
+)
+
+const panelHelp = `keys:
+  a        toggle the clock between automatic and manual mode
+  space    pulse the clock once in manual mode
+  i        run to the end of the current instruction
+  + -      double or halve the clock frequency
+  r        reset
+  h        toggle the external halt switch
+  p        toggle the memory between run and programming mode
+  [ ]      decrement or increment the address switches in programming mode
+  0-7      toggle a data switch in programming mode
+  w        store the data switches in memory in programming mode
+  q        quit`
+
+func runPanel(args []string) error {
+	fs := flag.NewFlagSet("panel", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: eatersim panel [flags] program\n\n"+
+			"Shows the LEDs of the boards as laid out in the breadboard build and\n"+
+			"updates them live while the program runs.\n\n%s\n\n", panelHelp)
+		fs.PrintDefaults()
+	}
+	hz := fs.Float64("hz", 4, "initial clock frequency in full clock cycles per second")
+	manual := fs.Bool("manual", false, "start with the clock in manual mode")
+	record := fs.String("record", "", "record the session to `file` for a bit exact replay with 'eatersim replay'")
+	duty := fs.Float64("duty", 0.5, "fraction of the clock period the clock is high")
+	jitter := fs.Float64("jitter", 0, "maximum deviation of the length of a clock phase, as a fraction of its length")
+	seed := fs.Int64("seed", 0, "seed of the clock jitter, 0 for a random seed")
+	sel := machineFlag(fs)
+	fs.Parse(args)
+
+	path, err := programArg(fs.Args())
+	if err != nil {
+		return err
+	}
+	cpu, _, err := newMachine(path, sel)
+	if err != nil {
+		return err
+	}
+
+	restore, err := cbreakTerminal()
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	if *duty != 0.5 || *jitter != 0 {
+		if *seed == 0 {
+			*seed = time.Now().UnixNano()
+		}
+		if cpu.CLK.Timing, err = eatersim.NewClockTiming(*duty, *jitter, *seed); err != nil {
+			return err
+		}
+	}
+	cpu.Duty = new(eatersim.DutyCycle)
+	cpu.Writes = eatersim.NewWriteHistory(recentWrites)
+	p := &panel{cpu: cpu, j: eatersim.NewJournal(cpu), name: filepath.Base(path), hz: *hz, auto: !*manual}
+	if err := p.loop(os.Stdin, os.Stdout); err != nil {
+		return err
+	}
+	if *record == "" {
+		return nil
+	}
+	f, err := os.Create(*record)
+	if err != nil {
+		return err
+	}
+	if _, err := p.j.Replay().WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// panel is the state of the interactive front panel.
+type panel struct {
+	cpu  *eatersim.BBCpu
+	name string
+
+	// j records all inputs, the cpu is only changed through it
+	j *eatersim.Journal
+
+	// hz is the clock frequency in automatic mode
+	hz float64
+	// auto is true when the clock runs by itself, false when it is pulsed
+	// by hand
+	auto bool
+	// due counts the clock cycles due in automatic mode but not yet executed
+	due float64
+}
+
+// recentWrites is the number of clock cycles a write is highlighted in the
+// memory view.
+const recentWrites = 8
+
+// frameRate is the number of times per second the panel is redrawn.
+const frameRate = 30
+
+func (p *panel) loop(in io.Reader, out io.Writer) error {
+	keys := make(chan byte)
+	go func() {
+		r := bufio.NewReader(in)
+		for {
+			b, err := r.ReadByte()
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- b
+		}
+	}()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	// hide the cursor and clear the screen, and undo it when done
+	fmt.Fprint(out, "\x1b[?25l\x1b[2J")
+	defer fmt.Fprint(out, "\x1b[?25h\n")
+
+	ticker := time.NewTicker(time.Second / frameRate)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case k, ok := <-keys:
+			if !ok || !p.key(k) {
+				return nil
+			}
+		case <-interrupt:
+			return nil
+		case now := <-ticker.C:
+			if p.auto {
+				p.due += p.hz * now.Sub(last).Seconds()
+				for ; p.due >= 1; p.due-- {
+					p.pulse()
+				}
+			}
+			last = now
+		}
+		io.WriteString(out, p.draw())
+	}
+}
+
+// key handles a key press. Returns false if the panel should quit.
+func (p *panel) key(k byte) bool {
+	switch k {
+	case 'q', 'Q', 0x1b:
+		return false
+	case 'a', 'A':
+		p.auto = !p.auto
+		p.due = 0
+	case ' ':
+		if !p.auto {
+			p.pulse()
+		}
+	case 'i', 'I':
+		if !p.auto {
+			p.j.Instruction()
+			// leave the clock low, as after a pulse
+			if p.cpu.CLK.CLK {
+				p.j.Exec()
+			}
+		}
+	case '+', '=':
+		if p.hz < 1e6 {
+			p.hz *= 2
+		}
+	case '-', '_':
+		if p.hz > 0.125 {
+			p.hz /= 2
+		}
+	case 'r', 'R':
+		p.j.Reset()
+	case 'h', 'H':
+		p.j.SetStop(!p.cpu.Stop)
+	case 'p', 'P':
+		ram := p.cpu.RAM
+		p.j.SetProg(!ram.Prog)
+		p.j.SetSwitches(ram.AddrSW, ram.MEM[ram.AddrSW&0x0f])
+	case '[', ']':
+		ram := p.cpu.RAM
+		if ram.Prog {
+			addr := ram.AddrSW + 1
+			if k == '[' {
+				addr = ram.AddrSW - 1
+			}
+			addr &= 0x0f
+			p.j.SetSwitches(addr, ram.MEM[addr])
+		}
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		if p.cpu.RAM.Prog {
+			p.j.SetSwitches(p.cpu.RAM.AddrSW, p.cpu.RAM.DataSW^1<<(k-'0'))
+		}
+	case 'w', 'W':
+		p.j.Program()
+	}
+	return true
+}
+
+// pulse executes one full clock cycle, from low over high back to low.
+func (p *panel) pulse() {
+	p.j.Step()
+}
+
+// draw renders the panel as a full screen update.
+func (p *panel) draw() string {
+	cpu := p.cpu
+	var b strings.Builder
+
+	mode := "manual"
+	if p.auto {
+		mode = fmt.Sprintf("auto %g Hz", p.hz)
+	}
+	if cpu.Stop {
+		mode += ", stopped"
+	}
+	line := func(left, right string) {
+		b.WriteString(padVisible(left, 38) + right + "\x1b[K\n")
+	}
+
+	// at high clock frequencies the LEDs are dimmed by how long they were lit
+	// since the last frame, as they would look on the real boards
+	lv := cpu.DutyLevels()
+	red, green, yellow, blue := eatersim.LEDRed, eatersim.LEDGreen, eatersim.LEDYellow, eatersim.LEDBlue
+
+	b.WriteString("\x1b[H")
+	line("eatersim panel  "+p.name, fmt.Sprintf("clock %s, cycle %d", mode, cpu.Cycles))
+	line("", "")
+	line("    BUS  "+eatersim.DimLEDs(lv.BUS[:], yellow), "")
+	line("", "")
+	line("  CLOCK  "+eatersim.DimLED(lv.CLK, blue)+" "+eatersim.DimLED(lv.HLT, red),
+		"     PC  "+eatersim.DimLEDs(lv.PC[:], green))
+	line("    MAR  "+eatersim.DimLEDs(lv.MAR[:], yellow),
+		"  A REG  "+eatersim.DimLEDs(lv.A[:], red))
+	line("    RAM  "+eatersim.DimLEDs(lv.RAMAddr[:], yellow)+" "+eatersim.DimLEDs(lv.RAM[:], red),
+		"    ALU  "+eatersim.DimLEDs(lv.ALU[:], red)+" "+eatersim.DimLED(lv.CF, green)+eatersim.DimLED(lv.ZF, green))
+	line("     IR  "+eatersim.DimLEDs(lv.IR[4:], blue)+eatersim.DimLEDs(lv.IR[:4], yellow)+"  "+instrText(cpu.IR.BUF),
+		"  B REG  "+eatersim.DimLEDs(lv.B[:], red))
+	if cpu.RAM.Prog {
+		line("   PROG  "+switches(cpu.RAM.AddrSW, 4)+" "+switches(cpu.RAM.DataSW, 8), "")
+	} else {
+		line("    RUN", "")
+	}
+
+	digits := sevenSegment(cpu.Oreg.BUF)
+	for i, d := range digits {
+		label := "         "
+		if i == 0 {
+			label = "    OUT  "
+		}
+		line("", label+string(eatersim.LEDRed)+d+"\x1b[0m")
+	}
+
+	// the step counter lights its LEDs from left to right
+	var step [eatersim.Steps]float64
+	for i, v := range lv.Step {
+		step[len(step)-1-i] = v
+	}
+	var names, lights strings.Builder
+	for i := len(lv.Control) - 1; i >= 0; i-- {
+		fmt.Fprintf(&names, "%-4s", eatersim.ControlWord(1)<<uint(i))
+		lights.WriteString(eatersim.DimLED(lv.Control[i], blue) + "   ")
+	}
+	line("", "")
+	line("CONTROL  "+eatersim.DimLEDs(step[:], green), "")
+	line("         "+names.String(), "")
+	line("         "+lights.String(), "")
+	line("", "")
+	for i, l := range strings.Split(cpu.MemoryView().Text(eatersim.MemoryHex, true), "\n") {
+		label := "         "
+		if i == 0 {
+			label = " MEMORY  "
+		}
+		line(label+l, "")
+	}
+	line("", "")
+	for _, l := range strings.Split(panelHelp, "\n") {
+		line(l, "")
+	}
+	b.WriteString("\x1b[J")
+	return b.String()
+}
+
+// switches renders the n least significant bits of v as DIP switches, most
+// significant bit first.
+func switches(v byte, n int) string {
+	var b strings.Builder
+	for i := n - 1; i >= 0; i-- {
+		if v&(1<<uint(i)) != 0 {
+			b.WriteString("■")
+		} else {
+			b.WriteString("□")
+		}
+	}
+	return b.String()
+}
+
+// padVisible pads s with spaces to width visible characters, ignoring ANSI
+// escape sequences.
+func padVisible(s string, width int) string {
+	n := 0
+	for i := 0; i < len(s); {
+		if s[i] == 0x1b {
+			for i < len(s) && s[i] != 'm' {
+				i++
+			}
+			i++
+			continue
+		}
+		_, w := utf8.DecodeRuneInString(s[i:])
+		i += w
+		n++
+	}
+	if n >= width {
+		return s
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+var segmentFont = [3]string{
+	" _     _  _     _  _  _  _  _ ",
+	"| |  | _| _||_||_ |_   ||_||_|",
+	"|_|  ||_  _|  | _||_|  ||_| _|",
+}
+
+// sevenSegment renders v in decimal on a three digit seven segment display,
+// with leading zeros blanked. Returns the three lines of the display.
+func sevenSegment(v byte) [3]string {
+	digits := fmt.Sprintf("%3d", v)
+	var lines [3]string
+	for _, d := range digits {
+		for i := range lines {
+			if d == ' ' {
+				lines[i] += "   "
+			} else {
+				n := int(d - '0')
+				lines[i] += segmentFont[i][3*n : 3*n+3]
+			}
+		}
+	}
+	return lines
+}
+
+// cbreakTerminal switches the terminal on standard input to unbuffered input
+// without echo, so that key presses are read as they happen. The returned
+// function restores the previous terminal settings.
+func cbreakTerminal() (restore func(), err error) {
+	state, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("standard input must be a terminal: %v", err)
+	}
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return nil, err
+	}
+	return func() { stty(strings.TrimSpace(state)) }, nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
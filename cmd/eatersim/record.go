@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/oj-mik/eatersim"
+)
+
+func runRecord(args []string) error {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: eatersim record [flags] program\n\n"+
+			"Runs the program until it halts and draws the LED panel after each clock\n"+
+			"cycle, into an animated GIF and optionally into one PNG file per cycle.\n\n")
+		fs.PrintDefaults()
+	}
+	out := fs.String("o", "out.gif", "output GIF file")
+	delay := fs.Int("delay", 10, "time each frame is shown in 100ths of a second")
+	pngDir := fs.String("png", "", "also write each frame as a PNG file to `dir`")
+	maxCycles := fs.Uint64("max-cycles", 1000, "maximum number of clock cycles to record, 0 for no limit")
+	fs.Parse(args)
+
+	path, err := programArg(fs.Args())
+	if err != nil {
+		return err
+	}
+	cpu, _, err := newMachine(path, nil)
+	if err != nil {
+		return err
+	}
+
+	r := eatersim.NewRecorder(*delay)
+	if *pngDir == "" {
+		r.Record(cpu, *maxCycles)
+	} else {
+		if err := os.MkdirAll(*pngDir, 0755); err != nil {
+			return err
+		}
+		// record by hand to write each frame as it is drawn
+		start := cpu.Cycles
+		for frames := 0; ; frames++ {
+			s := cpu.State()
+			r.Add(s)
+			if err := writePNG(filepath.Join(*pngDir, fmt.Sprintf("frame%04d.png", frames)), s); err != nil {
+				return err
+			}
+			if cpu.CL.HLT || (*maxCycles > 0 && cpu.Cycles-start >= *maxCycles) {
+				break
+			}
+			cpu.Step()
+		}
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := r.Encode(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "recorded %d frames\n", r.Len())
+	return nil
+}
+
+func writePNG(name string, s eatersim.State) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, eatersim.Frame(s)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
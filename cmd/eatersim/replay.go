@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/oj-mik/eatersim"
+)
+
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: eatersim replay recording\n\n"+
+			"Replays a session recorded with 'eatersim panel -record' and verifies that\n"+
+			"the replay went through exactly the same states, then prints the final\n"+
+			"state of the cpu.\n")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return &exitError{2, nil}
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	rep, err := eatersim.ReadReplay(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %s", fs.Arg(0), err)
+	}
+
+	cpu, err := rep.Run()
+	if err != nil {
+		return &exitError{1, err}
+	}
+	fmt.Println(cpu)
+	fmt.Printf("replayed %d half steps and %d inputs, trace hash %s\n", rep.HalfSteps, len(rep.Inputs), rep.Hash)
+	return nil
+}
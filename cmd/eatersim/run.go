@@ -0,0 +1,525 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/oj-mik/eatersim"
+	"github.com/oj-mik/eatersim/assembler"
+)
+
+// machineFlags are the flags shared by the commands executing programs.
+type machineFlags struct {
+	maxCycles uint64
+	hz        float64
+	check     bool
+	protect   addrRange
+	loops     bool
+	sel       *machineSel
+}
+
+func (m *machineFlags) register(fs *flag.FlagSet) {
+	fs.Uint64Var(&m.maxCycles, "max-cycles", 100000, "maximum number of clock cycles to execute before giving up, 0 for no limit")
+	fs.Float64Var(&m.hz, "hz", 0, "clock frequency in full clock cycles per second, 0 to run as fast as possible")
+	fs.BoolVar(&m.check, "check", false, "check the machine invariants after every half step and stop at the first violation")
+	fs.Var(&m.protect, "protect", "make the addresses `start-end` read-only and stop at the first write to them, e.g. 0-11")
+	m.sel = machineFlag(fs)
+	fs.BoolVar(&m.loops, "detect-loops", false, "stop once the program comes back to the state of an earlier instruction, as it never halts then")
+}
+
+// addrRange is a range of memory addresses given as a flag, end included.
+type addrRange struct {
+	set        bool
+	start, end byte
+}
+
+func (r *addrRange) String() string {
+	if !r.set {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d", r.start, r.end)
+}
+
+func (r *addrRange) Set(s string) error {
+	lo, hi, ok := strings.Cut(s, "-")
+	if !ok {
+		hi = lo
+	}
+	start, err1 := strconv.ParseUint(lo, 0, 4)
+	end, err2 := strconv.ParseUint(hi, 0, 4)
+	if err1 != nil || err2 != nil || start > end {
+		return fmt.Errorf("invalid address range %s", s)
+	}
+	*r = addrRange{true, byte(start), byte(end)}
+	return nil
+}
+
+// newMachine loads the program at path into a new cpu of the machine sel, or
+// of the default machine if sel is nil.
+func newMachine(path string, sel *machineSel) (*eatersim.BBCpu, *program, error) {
+	p, err := loadProgram(path, sel.level())
+	if err != nil {
+		return nil, nil, err
+	}
+	cpu, err := eatersim.New(append(sel.options(), eatersim.WithMemoryImage(p.Bin))...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cpu.RAM.Verify(p.Bin); err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if p.Obj != nil {
+		if err := joinErrors(p.Obj.CheckISA(cpu.ISA())); err != nil {
+			return nil, nil, err
+		}
+	}
+	return cpu, p, nil
+}
+
+// joinErrors combines errs, e.g. of CheckISA, into one, one error per line, or
+// returns nil if there are none.
+func joinErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return errors.New(strings.Join(msgs, "\n"))
+}
+
+// hooks are called by execute while the cpu runs.
+type hooks struct {
+	// instruction is called after each instruction with the address the
+	// instruction was fetched from
+	instruction func(addr byte)
+
+	// output is called when the output register latches a value
+	output func(v byte)
+
+	// halfStep is called after each half step with the states before and
+	// after it
+	halfStep func(before, after eatersim.State)
+}
+
+// haltError is returned by execute if the program did not halt on its own.
+type haltError struct {
+	halt eatersim.Halt
+}
+
+func (e *haltError) Error() string {
+	return e.halt.String()
+}
+
+// execute runs cpu half step by half step until it halts, honoring the clock
+// frequency, and returns why it stopped. The error is a *haltError, unless
+// the program executed a HLT instruction.
+func (m *machineFlags) execute(ctx context.Context, cpu *eatersim.BBCpu, h hooks) (eatersim.Halt, error) {
+	var p *eatersim.Pacer
+	if m.hz > 0 {
+		cpu.Hz = m.hz
+		p = eatersim.NewPacer(m.hz, cpu.Cycles)
+	}
+
+	var fault *eatersim.WriteFault
+	if m.protect.set {
+		cpu.RAM.Protect(m.protect.start, m.protect.end+1)
+		cpu.RAM.OnFault = func(f *eatersim.WriteFault) {
+			if fault == nil {
+				fault = f
+			}
+		}
+		defer func() { cpu.RAM.OnFault = nil }()
+	}
+
+	var violation *eatersim.Violation
+	if m.check {
+		cpu.OnViolation = func(v *eatersim.Violation) {
+			if violation == nil {
+				violation = v
+			}
+		}
+		defer func() { cpu.OnViolation = nil }()
+	}
+
+	stop := func(r eatersim.StopReason, err error) (eatersim.Halt, error) {
+		halt := eatersim.Halt{Reason: r, Addr: cpu.PC.CNT, Err: err}
+		if r == eatersim.StopHalt {
+			return halt, nil
+		}
+		return halt, &haltError{halt}
+	}
+
+	var loops eatersim.LoopDetector
+	start := cpu.Cycles
+	addr := cpu.PC.CNT
+	for !cpu.CL.HLT {
+		switch {
+		case cpu.Stop:
+			return stop(eatersim.StopExternal, nil)
+		case ctx.Err() != nil:
+			return stop(eatersim.StopCancelled, ctx.Err())
+		case m.maxCycles > 0 && cpu.Cycles-start >= m.maxCycles:
+			return stop(eatersim.StopCycleLimit, nil)
+		case violation != nil:
+			return stop(eatersim.StopViolation, violation)
+		case fault != nil:
+			return stop(eatersim.StopFault, fault)
+		}
+		if p != nil {
+			p.Wait(cpu.Cycles)
+		}
+
+		clk := cpu.CLK.CLK
+		var before eatersim.State
+		if h.halfStep != nil {
+			before = cpu.State()
+		}
+		cpu.HalfStep()
+		if h.halfStep != nil {
+			h.halfStep(before, cpu.State())
+		}
+
+		if h.output != nil && cpu.CLK.CLK && !clk && cpu.CL.OI {
+			h.output(cpu.Oreg.BUF)
+		}
+		// instructions end on the rising edge of the last micro step, or when
+		// the cpu halts
+		if cpu.AtBoundary() || cpu.CL.HLT {
+			if h.instruction != nil {
+				h.instruction(addr)
+			}
+			addr = cpu.PC.CNT
+			if m.loops && !cpu.CL.HLT && loops.Check(cpu.State()) {
+				return stop(eatersim.StopLoop, nil)
+			}
+		}
+	}
+	if violation != nil {
+		return stop(eatersim.StopViolation, violation)
+	}
+	if fault != nil {
+		return stop(eatersim.StopFault, fault)
+	}
+	return stop(eatersim.StopHalt, nil)
+}
+
+// exitError makes the command exit with the given status, after printing err
+// if it is not nil.
+type exitError struct {
+	status int
+	err    error
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %v", e.status)
+	}
+	return e.err.Error()
+}
+
+func runRun(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: eatersim run [flags] program\n\n"+
+			"Runs the program until it halts and prints the output register, or the\n"+
+			"memory cell selected by -exit-from. The exit status is the value printed\n"+
+			"if the program halts, and the halt reason is printed to standard error.\n"+
+			"If the program does not halt on its own, e.g. as it exceeds -max-cycles,\n"+
+			"the halt reason is printed to standard error and the exit status is 1.\n"+
+			"An interrupt stops the program as well.\n\n"+
+			"With -watch-output every value latched by the output register is printed\n"+
+			"on a line of its own as it occurs, instead of the final value.\n\n")
+		fs.PrintDefaults()
+	}
+	var mf machineFlags
+	mf.register(fs)
+	traceFormat := fs.String("trace-format", "", "print a trace to standard error, of each instruction in the text or source format, of each micro operation in the micro format, or of each half step in the explain, json, csv or vcd format")
+	filter := traceFilterFlags(fs, "trace-")
+	watch := fs.Bool("watch-output", false, "print every value latched by the output register as it occurs")
+	var from exitSource
+	fs.Var(&from, "exit-from", "take the result and exit status from the output register `out`, or from the memory cell at an address, e.g. 15")
+	fs.Parse(args)
+
+	path, err := programArg(fs.Args())
+	if err != nil {
+		return err
+	}
+	cpu, prog, err := newMachine(path, mf.sel)
+	if err != nil {
+		return err
+	}
+	if _, err := from.value(cpu); err != nil {
+		return err
+	}
+
+	var h hooks
+	if *traceFormat != "" {
+		t, err := newTracer(os.Stderr, *traceFormat, prog.Obj)
+		if err != nil {
+			return err
+		}
+		t.filter = filter
+		t.loaded(cpu)
+		t.hook(cpu, &h)
+	}
+	if *watch {
+		h.output = func(v byte) { fmt.Println(v) }
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	halt, err := mf.execute(ctx, cpu, h)
+	if err == nil && cpu.Trace != nil {
+		err = cpu.Trace.Flush()
+	}
+	result, _ := from.value(cpu)
+	if !*watch {
+		fmt.Println(result)
+	}
+	if err != nil {
+		return &exitError{1, err}
+	}
+	fmt.Fprintln(os.Stderr, halt)
+	return &exitError{int(result), nil}
+}
+
+// exitSource selects where run takes its result from, the output register or
+// a memory cell, given as a flag.
+type exitSource struct {
+	mem  bool
+	addr int
+}
+
+func (s *exitSource) String() string {
+	if !s.mem {
+		return "out"
+	}
+	return strconv.Itoa(s.addr)
+}
+
+func (s *exitSource) Set(v string) error {
+	if v == "out" {
+		*s = exitSource{}
+		return nil
+	}
+	addr, err := strconv.ParseUint(v, 0, 8)
+	if err != nil {
+		return fmt.Errorf("invalid exit source %s, expecting out or an address", v)
+	}
+	*s = exitSource{true, int(addr)}
+	return nil
+}
+
+// value returns the result of the run on cpu.
+func (s *exitSource) value(cpu *eatersim.BBCpu) (byte, error) {
+	if !s.mem {
+		return cpu.Oreg.BUF, nil
+	}
+	if s.addr >= len(cpu.RAM.MEM) {
+		return 0, fmt.Errorf("exit source address %d out of range, the memory has %d cells", s.addr, len(cpu.RAM.MEM))
+	}
+	return cpu.RAM.MEM[s.addr], nil
+}
+
+func runTrace(args []string) error {
+	fs := flag.NewFlagSet("trace", flag.ExitOnError)
+	var mf machineFlags
+	mf.register(fs)
+	format := fs.String("format", "text", "trace format, text, source, json, csv, micro, explain or vcd")
+	filter := traceFilterFlags(fs, "")
+	fs.Parse(args)
+
+	path, err := programArg(fs.Args())
+	if err != nil {
+		return err
+	}
+	cpu, prog, err := newMachine(path, mf.sel)
+	if err != nil {
+		return err
+	}
+
+	t, err := newTracer(os.Stdout, *format, prog.Obj)
+	if err != nil {
+		return err
+	}
+	t.filter = filter
+	t.loaded(cpu)
+	var h hooks
+	t.hook(cpu, &h)
+	_, err = mf.execute(context.Background(), cpu, h)
+	if err == nil && cpu.Trace != nil {
+		err = cpu.Trace.Flush()
+	}
+	return err
+}
+
+// tracer writes the machine state after each instruction, micro operation or
+// half step in one of the trace formats. The source format refers to the source code of the program in obj
+// instead of addresses. The records are selected by filter.
+type tracer struct {
+	w      io.Writer
+	format string
+	obj    *assembler.Object
+	filter *eatersim.TraceFilter
+
+	// state at the previous instruction, for the filter
+	prev eatersim.State
+}
+
+// traceFilterFlags registers the flags selecting the records of a trace, their
+// names prefixed by prefix.
+func traceFilterFlags(fs *flag.FlagSet, prefix string) *eatersim.TraceFilter {
+	f := new(eatersim.TraceFilter)
+	fs.Func(prefix+"boards", "trace only records changing one of the comma separated `boards`, e.g. areg,oreg, of clk, ring, cl, bus, areg, breg, oreg, alu, mar, ram, pc and ir", func(s string) (err error) {
+		f.Boards, err = eatersim.ParseTraceBoards(s)
+		return err
+	})
+	fs.Func(prefix+"signals", "trace only records with one of the `signals` active, e.g. RI|OI", func(s string) (err error) {
+		f.Signals, err = eatersim.ParseControlWord(s)
+		return err
+	})
+	fs.BoolVar(&f.Instructions, prefix+"instructions", false, "trace only the half steps ending an instruction in the explain, json, csv or vcd format")
+	fs.IntVar(&f.Every, prefix+"every", 0, "trace only the first and then every `n`th of the records passing the other filters")
+	return f
+}
+
+func newTracer(w io.Writer, format string, obj *assembler.Object) (*tracer, error) {
+	switch format {
+	case "source":
+		if obj == nil {
+			return nil, errors.New("the source trace format needs an assembly program")
+		}
+		fallthrough
+	case "text", "json", "csv", "micro", "explain", "vcd":
+		return &tracer{w: w, format: format, obj: obj}, nil
+	}
+	return nil, fmt.Errorf("unknown trace format %s", format)
+}
+
+// hook sets the hook of h the tracer writes its trace from, the half step hook
+// for the micro and explain formats and the instruction hook otherwise. The
+// json, csv and vcd formats are written by the trace of the cpu instead, see
+// sink.
+func (t *tracer) hook(cpu *eatersim.BBCpu, h *hooks) {
+	switch t.format {
+	case "json", "csv", "vcd":
+		cpu.Trace = eatersim.NewTracer(t.sink())
+		cpu.Trace.Filter = t.filter
+		return
+	case "micro":
+		// the control word of a micro operation is set up on the falling
+		// clock edge
+		h.halfStep = func(before, after eatersim.State) {
+			if before.CLK && !after.CLK && t.keep(before, after) {
+				fmt.Fprintf(t.w, "%-16s BUS=$%02x IR=$%02x\n", cpu.CL.MicroOp(), after.BUS, after.IR)
+			}
+		}
+		return
+	case "explain":
+		h.halfStep = func(before, after eatersim.State) {
+			if t.keep(before, after) {
+				fmt.Fprintln(t.w, eatersim.Explain(before, after))
+			}
+		}
+		return
+	}
+	h.instruction = func(addr byte) { t.trace(cpu, addr) }
+}
+
+// sink returns the trace sink writing the json, csv or vcd format, an event
+// per half step.
+func (t *tracer) sink() eatersim.TraceSink {
+	switch t.format {
+	case "json":
+		return eatersim.NewJSONLSink(t.w)
+	case "csv":
+		return eatersim.NewCSVSink(t.w)
+	}
+	return eatersim.NewVCDSink(t.w)
+}
+
+// loaded writes the checksum of the verified memory image before the trace in
+// the text formats.
+func (t *tracer) loaded(cpu *eatersim.BBCpu) {
+	t.prev = cpu.State()
+	if t.format == "text" || t.format == "source" {
+		fmt.Fprintf(t.w, "; memory verified, checksum $%04x\n", cpu.RAM.Checksum())
+	}
+}
+
+// keep tells whether the filter of the tracer keeps the record of after.
+func (t *tracer) keep(before, after eatersim.State) bool {
+	return t.filter == nil || t.filter.Keep(before, after)
+}
+
+func (t *tracer) trace(cpu *eatersim.BBCpu, addr byte) {
+	s := cpu.State()
+	before := t.prev
+	t.prev = s
+	if !t.keep(before, s) {
+		return
+	}
+	switch t.format {
+	case "text":
+		fmt.Fprintln(t.w, traceLine(cpu, addr))
+	case "source":
+		fmt.Fprintln(t.w, sourceTraceLine(cpu, addr, t.obj))
+	}
+}
+
+// instrText returns the disassembled instruction in ir.
+func instrText(ir byte) string {
+	if name, arg, ok := assembler.Decode(ir); ok {
+		return fmt.Sprintf("%s %d", name, arg)
+	}
+	return fmt.Sprintf(".byte $%02x", ir)
+}
+
+// traceLine describes the instruction just executed from addr and the
+// resulting machine state.
+func traceLine(cpu *eatersim.BBCpu, addr byte) string {
+	return fmt.Sprintf("$%x %-9s A=$%02x B=$%02x OUT=%3d CF=%d ZF=%d T=%v",
+		addr, instrText(cpu.IR.BUF), cpu.Areg.BUF, cpu.Breg.BUF, cpu.Oreg.BUF, b2i(cpu.ALU.CF), b2i(cpu.ALU.ZF), cpu.Time())
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// sourceTraceLine describes the instruction just executed from addr by its
+// location and text in the source code of obj, followed by the resulting
+// machine state, e.g.
+//
+//	mul.asm:3   start+1: add factor1   ; A=$02 B=$02 OUT=  0 CF=0 ZF=0 T=10ms
+//
+// Instructions modified at run time are disassembled instead.
+func sourceTraceLine(cpu *eatersim.BBCpu, addr byte, obj *assembler.Object) string {
+	loc, ok := obj.SourceMap.Lookup(int(addr))
+	file := "-"
+	if ok {
+		file = fmt.Sprintf("%s:%d", filepath.Base(loc.File), loc.Line)
+	}
+	where := loc.Context()
+	if where == "" {
+		where = fmt.Sprintf("$%x", addr)
+	}
+	text := obj.StmtText(int(addr))
+	if text == "" || obj.Bin[addr] != cpu.IR.BUF {
+		text = instrText(cpu.IR.BUF)
+	}
+	return fmt.Sprintf("%-11s %-9s %-13s ; A=$%02x B=$%02x OUT=%3d CF=%d ZF=%d T=%v",
+		file, where+":", text, cpu.Areg.BUF, cpu.Breg.BUF, cpu.Oreg.BUF, b2i(cpu.ALU.CF), b2i(cpu.ALU.ZF), cpu.Time())
+}
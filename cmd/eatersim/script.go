@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/oj-mik/eatersim"
+	"github.com/oj-mik/eatersim/assembler"
+	"github.com/oj-mik/eatersim/script"
+)
+
+func runScript(args []string) error {
+	fs := flag.NewFlagSet("script", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: eatersim script [flags] bench...\n\n"+
+			"Runs test bench scripts, each on a new cpu. Programs loaded by a script are\n"+
+			"looked up relative to the script. See the documentation of the package\n"+
+			"github.com/oj-mik/eatersim/script for the commands.\n\n")
+		fs.PrintDefaults()
+	}
+	verbose := fs.Bool("v", false, "print passing scripts too")
+	resetOnLoad := fs.Bool("reset-on-load", false, "reset the cpu after each load command")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return &exitError{2, nil}
+	}
+
+	failed := 0
+	for _, path := range fs.Args() {
+		err := runBenchScript(path, *resetOnLoad)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Printf("--- FAIL: %s\n    %s\n", path, err)
+		case *verbose:
+			fmt.Printf("--- PASS: %s\n", path)
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("FAIL %v of %v scripts\n", failed, fs.NArg())
+		return &exitError{1, nil}
+	}
+	fmt.Printf("ok   %v scripts\n", fs.NArg())
+	return nil
+}
+
+// runBenchScript runs the test bench script at path on a new cpu.
+func runBenchScript(path string, resetOnLoad bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cpu := eatersim.NewBBCpu()
+	cpu.ResetOnLoad = resetOnLoad
+	b := script.New(cpu)
+	b.Out = os.Stdout
+	b.Load = func(name string) ([]byte, error) {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(filepath.Dir(path), name)
+		}
+		p, err := loadProgram(name, assembler.LevelDefault)
+		if err != nil {
+			return nil, err
+		}
+		return p.Bin, nil
+	}
+	return b.Run(f)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/oj-mik/eatersim"
+	"github.com/oj-mik/eatersim/server"
+)
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: eatersim serve [flags] [program]\n\n"+
+			"Serves the cpu over HTTP and streams its state over WebSocket, see the\n"+
+			"documentation of package github.com/oj-mik/eatersim/server for the API.\n\n")
+		fs.PrintDefaults()
+	}
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	sel := machineFlag(fs)
+	fs.Parse(args)
+
+	cpu, err := eatersim.New(sel.options()...)
+	if err != nil {
+		return err
+	}
+	switch len(fs.Args()) {
+	case 0:
+	case 1:
+		if cpu, _, err = newMachine(fs.Arg(0), sel); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("expecting at most one program file, got %v", len(fs.Args()))
+	}
+
+	fmt.Fprintf(os.Stderr, "listening on %s\n", *addr)
+	return http.ListenAndServe(*addr, server.New(cpu))
+}
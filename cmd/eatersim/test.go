@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/oj-mik/eatersim"
+	"github.com/oj-mik/eatersim/programs"
+)
+
+func runTest(args []string) error {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: eatersim test [flags] program...\n\n"+
+			"Runs each program and checks it against the expectations in the file of\n"+
+			"the same name with the extension .expect, e.g. mul.expect for mul.asm.\n"+
+			"Directories are searched for .asm files with an expectation file. With\n"+
+			"-demos the built-in demo programs are tested as well.\n\n")
+		fs.PrintDefaults()
+	}
+	verbose := fs.Bool("v", false, "print passing programs too")
+	demos := fs.Bool("demos", false, "test the built-in demo programs")
+	fs.Parse(args)
+
+	paths, err := testPrograms(fs.Args())
+	if err != nil {
+		return err
+	}
+	tests := make([]func() error, len(paths))
+	for i, path := range paths {
+		path := path
+		tests[i] = func() error { return testProgram(path) }
+	}
+	if *demos {
+		for _, name := range programs.Names() {
+			name := name
+			paths = append(paths, "demo:"+name)
+			tests = append(tests, func() error {
+				p, err := programs.Load(name)
+				if err != nil {
+					return err
+				}
+				return p.Check()
+			})
+		}
+	}
+	if len(paths) == 0 {
+		return errors.New("no programs to test")
+	}
+
+	failed := 0
+	for i, path := range paths {
+		start := time.Now()
+		err := tests[i]()
+		d := time.Since(start).Seconds()
+		switch {
+		case err != nil:
+			failed++
+			fmt.Printf("--- FAIL: %s (%.3fs)\n    %s\n", path, d, err)
+		case *verbose:
+			fmt.Printf("--- PASS: %s (%.3fs)\n", path, d)
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("FAIL %v of %v programs\n", failed, len(paths))
+		return &exitError{1, nil}
+	}
+	fmt.Printf("ok   %v programs\n", len(paths))
+	return nil
+}
+
+// testPrograms expands the directories in args to the programs they contain
+// with an expectation file.
+func testPrograms(args []string) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		fi, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "*.asm"))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			if _, err := os.Stat(expectPath(m)); err == nil {
+				paths = append(paths, m)
+			}
+		}
+	}
+	return paths, nil
+}
+
+func expectPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".expect"
+}
+
+// testProgram runs the program at path against its expectation file.
+func testProgram(path string) error {
+	f, err := os.Open(expectPath(path))
+	if err != nil {
+		return err
+	}
+	e, err := eatersim.ParseExpectation(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %s", expectPath(path), err)
+	}
+
+	cpu, _, err := newMachine(path, nil)
+	if err != nil {
+		return err
+	}
+	return e.Check(cpu)
+}
@@ -0,0 +1,270 @@
+package eatersim
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/oj-mik/eatersim/assembler"
+)
+
+// StopReason tells why the execution of the cpu stopped.
+type StopReason int
+
+// The reasons the execution of the cpu may stop for.
+const (
+	// StopHalt means the cpu executed a HLT instruction
+	StopHalt StopReason = iota
+
+	// StopBreakpoint means the next instruction is at a breakpoint
+	StopBreakpoint
+
+	// StopCycleLimit means the cycle budget was used up
+	StopCycleLimit
+
+	// StopExternal means the external halt input of the cpu is asserted
+	StopExternal
+
+	// StopSignal means the condition of a signal breakpoint started to hold,
+	// see AddSignalBreakpoint
+	StopSignal
+
+	// StopReached means the next instruction is at the address run to, see
+	// RunTo
+	StopReached
+
+	// StopViolation means a machine invariant was broken, see
+	// CheckInvariants
+	StopViolation
+
+	// StopFault means the cpu wrote to a read-only address, see Protect
+	StopFault
+
+	// StopLoop means the cpu came back to the state of an earlier
+	// instruction, so that it will never halt, see LoopDetector
+	StopLoop
+
+	// StopCancelled means the context of the run was cancelled
+	StopCancelled
+)
+
+var stopReasonNames = [...]string{
+	StopHalt:       "halted",
+	StopBreakpoint: "breakpoint",
+	StopCycleLimit: "cycle limit exceeded",
+	StopExternal:   "stopped by the halt input",
+	StopSignal:     "signal breakpoint",
+	StopReached:    "reached",
+	StopViolation:  "invariant violation",
+	StopFault:      "write fault",
+	StopLoop:       "loop detected",
+	StopCancelled:  "cancelled",
+}
+
+// Implements the Stringer-interface
+func (r StopReason) String() string {
+	if r < 0 || int(r) >= len(stopReasonNames) {
+		return "unknown"
+	}
+	return stopReasonNames[r]
+}
+
+// Debugger controls the execution of a breadboard cpu for interactive
+// debugging. It steps by instruction or by micro instruction, stops at
+// breakpoints, optionally only if a condition holds, inspects and modifies
+// the memory, and keeps named snapshots of the state to compare.
+type Debugger struct {
+	CPU *BBCpu
+
+	// Program is the program loaded by LoadObject, nil if the memory was
+	// loaded otherwise
+	Program *assembler.Object
+
+	breakpoints map[byte]bool
+	conditions  map[byte]*Expr
+	snapshots   map[string]State
+
+	// manual changes to undo and to redo, the latest last
+	undo, redo []mutation
+
+	signals []*signalBreak
+	hit     *Expr
+}
+
+// NewDebugger creates a new debugger controlling cpu.
+func NewDebugger(cpu *BBCpu) *Debugger {
+	return &Debugger{
+		CPU:         cpu,
+		breakpoints: make(map[byte]bool),
+		conditions:  make(map[byte]*Expr),
+		snapshots:   make(map[string]State),
+	}
+}
+
+// Load writes bin to the memory, verifies it and resets the cpu.
+func (d *Debugger) Load(bin []byte) error {
+	if len(bin) > len(d.CPU.RAM.MEM) {
+		return errors.New("buffer larger than memory")
+	}
+	d.Program = nil
+	d.CPU.RAM.Write(bin)
+	if err := d.CPU.RAM.Verify(bin); err != nil {
+		return err
+	}
+	d.CPU.Reset()
+	return nil
+}
+
+// Reset resets the cpu. Memory and breakpoints are left untouched.
+func (d *Debugger) Reset() {
+	d.CPU.Reset()
+}
+
+// Step executes one instruction. Does nothing if the cpu is halted.
+func (d *Debugger) Step() {
+	d.CPU.Instruction()
+}
+
+// Micro executes one micro instruction, i.e. runs the cpu until the next
+// rising clock edge has been executed. Does nothing if the cpu is halted.
+func (d *Debugger) Micro() {
+	if d.CPU.Halted() {
+		return
+	}
+	d.CPU.Exec()
+	for !d.CPU.CLK.CLK && !d.CPU.Halted() {
+		d.CPU.Exec()
+	}
+}
+
+// Run executes instructions until the cpu halts, the next instruction is at a
+// breakpoint, a signal breakpoint triggers, or maxCycles full clock cycles
+// have been executed. At least one instruction is executed, so Run continues
+// from a breakpoint. A maxCycles of zero means no limit.
+func (d *Debugger) Run(maxCycles uint64) StopReason {
+	return d.run(maxCycles, -1)
+}
+
+// RunTo runs like Run, but stops with StopReached as well once the next
+// instruction is at addr.
+func (d *Debugger) RunTo(addr byte, maxCycles uint64) StopReason {
+	return d.run(maxCycles, int(addr&0x0f))
+}
+
+// StepOver executes one instruction, but runs a backward jump as a unit: if
+// the instruction is a jump to its own or an earlier address, as at the end
+// of a loop, it runs until the instruction following the jump is next, see
+// RunTo. Forward and not taken jumps and other instructions are executed like
+// Step.
+func (d *Debugger) StepOver(maxCycles uint64) StopReason {
+	if pc := d.PC(); d.StepsOver(pc) {
+		return d.RunTo(pc+1, maxCycles)
+	}
+	d.Step()
+	if d.CPU.CL.HLT {
+		return StopHalt
+	}
+	return StopReached
+}
+
+// StepsOver tells whether StepOver runs the instruction at addr as a unit,
+// i.e. whether it is a backward jump.
+func (d *Debugger) StepsOver(addr byte) bool {
+	ir := d.Peek(addr)
+	switch ir >> 4 {
+	case 0x6, 0x7, 0x8:
+		// jmp, jc, jz
+		return ir&0x0f <= addr&0x0f
+	}
+	return false
+}
+
+// run executes instructions like Run, stopping at the address to as well if it
+// is not negative.
+func (d *Debugger) run(maxCycles uint64, to int) StopReason {
+	start := d.CPU.Cycles
+	for first := true; ; first = false {
+		switch {
+		case d.CPU.CL.HLT:
+			return StopHalt
+		case d.CPU.Stop:
+			return StopExternal
+		case !first && int(d.PC()) == to:
+			return StopReached
+		case !first && d.StopsAt(d.PC()):
+			return StopBreakpoint
+		case maxCycles > 0 && d.CPU.Cycles-start >= maxCycles:
+			return StopCycleLimit
+		}
+		if d.instruction() {
+			return StopSignal
+		}
+	}
+}
+
+// PC returns the address of the next instruction to execute.
+func (d *Debugger) PC() byte {
+	return d.CPU.PC.CNT & 0x0f
+}
+
+// SetBreakpoint sets a breakpoint at the instruction at addr.
+func (d *Debugger) SetBreakpoint(addr byte) {
+	d.breakpoints[addr&0x0f] = true
+	delete(d.conditions, addr&0x0f)
+}
+
+// SetConditionalBreakpoint sets a breakpoint at the instruction at addr,
+// which only stops the execution if cond is true.
+func (d *Debugger) SetConditionalBreakpoint(addr byte, cond *Expr) {
+	d.breakpoints[addr&0x0f] = true
+	d.conditions[addr&0x0f] = cond
+}
+
+// ClearBreakpoint removes the breakpoint at addr, if any.
+func (d *Debugger) ClearBreakpoint(addr byte) {
+	delete(d.breakpoints, addr&0x0f)
+	delete(d.conditions, addr&0x0f)
+}
+
+// Condition returns the condition of the breakpoint at addr, or nil if the
+// breakpoint is unconditional or there is none.
+func (d *Debugger) Condition(addr byte) *Expr {
+	return d.conditions[addr&0x0f]
+}
+
+// StopsAt tells whether there is a breakpoint at addr which stops the
+// execution in the current state of the cpu.
+func (d *Debugger) StopsAt(addr byte) bool {
+	if !d.breakpoints[addr&0x0f] {
+		return false
+	}
+	cond := d.conditions[addr&0x0f]
+	return cond == nil || cond.True(d.CPU.State())
+}
+
+// HasBreakpoint tells whether there is a breakpoint at addr.
+func (d *Debugger) HasBreakpoint(addr byte) bool {
+	return d.breakpoints[addr&0x0f]
+}
+
+// Breakpoints returns the addresses of all breakpoints in ascending order.
+func (d *Debugger) Breakpoints() []byte {
+	var addrs []byte
+	for addr := range d.breakpoints {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+	return addrs
+}
+
+// Peek returns the value in memory at addr.
+func (d *Debugger) Peek(addr byte) byte {
+	return d.CPU.RAM.MEM[addr&0x0f]
+}
+
+// Poke stores v in memory at addr. The change can be undone, see Undo.
+func (d *Debugger) Poke(addr, v byte) {
+	addr &= 0x0f
+	mem := &d.CPU.RAM.MEM
+	d.mutate(fmt.Sprintf("MEM[$%x]", addr), mem[addr], v, func(v byte) { mem[addr] = v })
+}
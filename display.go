@@ -0,0 +1,114 @@
+package eatersim
+
+import "fmt"
+
+// Display represents the multiplexed 4 digit display of the output register
+// board. A refresh clock independent of the cpu clock drives a 2-bit digit
+// select counter, and an EEPROM translates the value of the output register
+// and the selected digit to the segments of that digit. Only one digit is lit
+// at a time, the display relies on the refresh clock being fast enough for
+// the eye to see all four.
+//
+// The segments are stored in the bits of a byte with segment a as bit 6
+// through segment g as bit 0 and the decimal point as bit 7, as in Ben's
+// EEPROM programmer.
+type Display struct {
+	// ROM is the content of the display EEPROM, see Address
+	ROM [0x800]byte
+
+	// Signed selects the two's complement mode, address bit 10 of the EEPROM
+	Signed bool
+
+	// Digit is the digit select counter, 0 selects the rightmost digit
+	Digit byte
+
+	// Segments is the output of the EEPROM to the selected digit
+	Segments byte
+
+	// Lit holds the segments last shown by each digit, index 0 is the
+	// rightmost digit. It is what the eye sees of the multiplexed display.
+	Lit [4]byte
+
+	// refresh clock of the display, the 555 timer on the board
+	CLK *Clk
+
+	// value signal of the output register
+	// read only
+	Value *byte
+
+	// helper states
+	clkprev, clkre bool
+}
+
+// NewDisplay creates a new display showing the value signal, with its own
+// refresh clock and the EEPROM content of Ben's build.
+func NewDisplay(value *byte) *Display {
+	d := new(Display)
+	d.Value = value
+	d.CLK = NewClk(nil)
+	d.ROM = DisplayROM()
+	return d
+}
+
+// Executes the refresh clock and the logic of the display once. The digit
+// select counter advances on the rising edge of the refresh clock.
+func (d *Display) Exec() {
+	d.CLK.Exec()
+	d.clkre = d.CLK.CLK && !d.clkprev
+	d.clkprev = d.CLK.CLK
+
+	if d.clkre {
+		d.Digit = (d.Digit + 1) & 0x03
+	}
+	d.Segments = d.ROM[d.Address()]
+	d.Lit[d.Digit] = d.Segments
+}
+
+// Address returns the EEPROM address selected by the mode switch, the digit
+// select counter and the value, as signed<<10 | digit<<8 | value.
+func (d *Display) Address() uint16 {
+	a := uint16(d.Digit&0x03)<<8 | uint16(ptbyte(d.Value))
+	if d.Signed {
+		a |= 1 << 10
+	}
+	return a
+}
+
+// Implements the Stringer-interface
+func (d *Display) String() string {
+	mode := "unsigned"
+	if d.Signed {
+		mode = "signed"
+	}
+	return fmt.Sprintf("Digit: %d, Segments: %08b, Mode: %s\nrefresh CLK: %v", d.Digit, d.Segments, mode, d.CLK.CLK)
+}
+
+// eepromDigits are the segments of the decimal digits in the bit order of the
+// EEPROM.
+var eepromDigits = [10]byte{0x7e, 0x30, 0x6d, 0x79, 0x33, 0x5b, 0x5f, 0x70, 0x7f, 0x7b}
+
+// minusSegments lights the middle segment g only.
+const minusSegments = 0x01
+
+// DisplayROM returns the content of the display EEPROM of Ben's build. In
+// unsigned mode the digits show the value in decimal with leading zeros and
+// the leftmost digit dark. In signed mode the value is read as two's
+// complement and the leftmost digit shows the sign if negative.
+func DisplayROM() [0x800]byte {
+	var rom [0x800]byte
+	for v := 0; v < 0x100; v++ {
+		rom[0<<8|v] = eepromDigits[v%10]
+		rom[1<<8|v] = eepromDigits[v/10%10]
+		rom[2<<8|v] = eepromDigits[v/100%10]
+
+		s := int(int8(v))
+		if s < 0 {
+			rom[1<<10|3<<8|v] = minusSegments
+			s = -s
+		}
+		rom[1<<10|0<<8|v] = eepromDigits[s%10]
+		rom[1<<10|1<<8|v] = eepromDigits[s/10%10]
+		rom[1<<10|2<<8|v] = eepromDigits[s/100%10]
+	}
+	return rom
+}
@@ -0,0 +1,63 @@
+package eatersim
+
+import "fmt"
+
+// ClockDomain runs a peripheral board on a clock of its own, derived from the
+// clock of the cpu by a divisor, e.g. the display multiplexer refreshing
+// slower than the cpu runs. The cpu steps its clock domains after its boards
+// with every Exec, so that the peripherals advance consistently with the cpu.
+// A ClockDomain is a Board itself.
+type ClockDomain struct {
+	// Board is the board executed in the domain
+	Board Board
+
+	// Divisor is the number of executions of the cpu per execution of the
+	// board, so that the clock of the domain runs at 1/Divisor of the clock
+	// of the cpu. Values below 1 count as 1.
+	Divisor int
+
+	// Phase is the number of executions of the cpu since the board was last
+	// executed
+	Phase int
+}
+
+// AddClockDomain adds a clock domain executing b once per divisor executions
+// of the cpu and returns it.
+func (c *BBCpu) AddClockDomain(b Board, divisor int) *ClockDomain {
+	d := &ClockDomain{Board: b, Divisor: divisor}
+	c.Domains = append(c.Domains, d)
+	return d
+}
+
+// Exec counts an execution of the cpu and executes the board once Divisor
+// executions have passed.
+func (d *ClockDomain) Exec() {
+	d.Phase++
+	if d.Phase >= d.Divisor {
+		d.Phase = 0
+		d.Board.Exec()
+	}
+}
+
+// Implements the Stringer-interface. Renders the board and the divisor.
+func (d *ClockDomain) String() string {
+	return fmt.Sprintf("%s\n\ndivisor: %d, phase: %d", d.Board, d.Divisor, d.Phase)
+}
+
+// Reset resets the board and restarts the divided clock.
+func (d *ClockDomain) Reset() {
+	d.Phase = 0
+	d.Board.Reset()
+}
+
+// WithDisplayRefresh executes the display of the output register in a clock
+// domain of its own, refreshing it once per divisor executions of the cpu.
+func WithDisplayRefresh(divisor int) Option {
+	return func(c *BBCpu) error {
+		if divisor < 1 {
+			return fmt.Errorf("display refresh divisor %d out of range", divisor)
+		}
+		c.AddClockDomain(c.Display, divisor)
+		return nil
+	}
+}
@@ -0,0 +1,95 @@
+package eatersim
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// dotEdge is a connection between two boards in the architecture diagram,
+// active while any of the control signals in sig is active. Connections with
+// no signal are always active.
+type dotEdge struct {
+	from, to string
+	sig      ControlWord
+	label    string
+}
+
+// the data paths of the breadboard cpu
+var dotEdges = []dotEdge{
+	{"pc", "bus", CO, "CO"},
+	{"bus", "pc", J, "J"},
+	{"bus", "mar", MI, "MI"},
+	{"mar", "ram", 0, "addr"},
+	{"ram", "bus", RO, "RO"},
+	{"bus", "ram", RI, "RI"},
+	{"ir", "bus", IO, "IO"},
+	{"bus", "ir", II, "II"},
+	{"ir", "ctrl", 0, "instr"},
+	{"a", "bus", AO, "AO"},
+	{"bus", "a", AI, "AI"},
+	{"a", "alu", 0, ""},
+	{"b", "alu", 0, ""},
+	{"alu", "bus", EO, "EO"},
+	{"alu", "ctrl", FI, "flags"},
+	{"bus", "b", BI, "BI"},
+	{"bus", "out", OI, "OI"},
+	{"ctrl", "clk", HLT, "HLT"},
+}
+
+// WriteDot writes the architecture of the breadboard cpu as a Graphviz DOT
+// graph, with the values of the registers and the bus from state s. The data
+// paths enabled by the active control signals are highlighted, so that a
+// sequence of graphs, one per clock cycle, shows the data moving between the
+// boards.
+func WriteDot(w io.Writer, s State) error {
+	var b strings.Builder
+	b.WriteString("digraph eatersim {\n")
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=box, fontname=monospace];\n")
+	b.WriteString("\tedge [fontname=monospace];\n")
+	fmt.Fprintf(&b, "\tlabel=\"cycle %d, step %d, %s\";\n", s.Cycles, s.Step, s.Control)
+
+	node := func(name, label string, active bool) {
+		style := ""
+		if active {
+			style = ", style=filled, fillcolor=lightyellow"
+		}
+		fmt.Fprintf(&b, "\t%s [label=\"%s\"%s];\n", name, label, style)
+	}
+	busDriven := s.Control&(CO|RO|IO|AO|EO) != 0
+	node("bus", fmt.Sprintf("BUS\\n%08b\\n$%02x", s.BUS, s.BUS), busDriven)
+	node("clk", fmt.Sprintf("CLOCK\\n%s", onOff(s.CLK)), s.CLK)
+	node("pc", fmt.Sprintf("PC\\n%04b", s.PC&0x0f), s.Control&(CO|J|CE) != 0)
+	node("mar", fmt.Sprintf("MAR\\n%04b", s.MAR&0x0f), s.Control&MI != 0)
+	node("ram", fmt.Sprintf("RAM\\n%08b", s.MEM[s.MAR&0x0f]), s.Control&(RO|RI) != 0)
+	node("ir", fmt.Sprintf("IR\\n%04b %04b", s.IR>>4, s.IR&0x0f), s.Control&(IO|II) != 0)
+	node("ctrl", fmt.Sprintf("CONTROL\\nT%d", s.Step), true)
+	node("a", fmt.Sprintf("A\\n%08b", s.A), s.Control&(AO|AI) != 0)
+	node("alu", fmt.Sprintf("ALU\\n%08b\\nCF=%d ZF=%d", s.ALU, b2u(s.CF), b2u(s.ZF)), s.Control&(EO|FI) != 0)
+	node("b", fmt.Sprintf("B\\n%08b", s.B), s.Control&BI != 0)
+	node("out", fmt.Sprintf("OUT\\n%d", s.Out), s.Control&OI != 0)
+
+	for _, e := range dotEdges {
+		attr := fmt.Sprintf("label=\"%s\"", e.label)
+		switch {
+		case e.sig == 0:
+		case s.Control&e.sig != 0:
+			attr += ", color=red, fontcolor=red, penwidth=2"
+		default:
+			attr += ", color=gray, fontcolor=gray"
+		}
+		fmt.Fprintf(&b, "\t%s -> %s [%s];\n", e.from, e.to, attr)
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func onOff(b bool) string {
+	if b {
+		return "high"
+	}
+	return "low"
+}
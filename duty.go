@@ -0,0 +1,173 @@
+package eatersim
+
+import "strings"
+
+// DutyCycle accumulates how long each LED of the cpu is lit, so that user
+// interfaces running the cpu faster than they redraw can show LEDs dimmed by
+// their duty cycle instead of an aliased snapshot. Set BBCpu.Duty to enable
+// it, every call to Exec then takes a sample. With Clk.Timing set the samples
+// are weighted by the length of the clock phase they are taken in.
+type DutyCycle struct {
+	samples uint64
+	total   float64
+
+	bus, a, b, out, ir, alu, ram [8]float64
+	mar, pc, addr                [4]float64
+	clk, hlt, cf, zf             float64
+	step                         [Steps]float64
+	control                      [numSignals]float64
+}
+
+// Levels holds the fraction of time each LED was lit, from 0 to 1. The LEDs of
+// a row are indexed by bit, i.e. element 0 is the least significant bit.
+type Levels struct {
+	BUS, A, B, Out, IR, ALU [8]float64
+
+	// RAM is the content of the memory at the selected address, RAMAddr the
+	// selected address
+	RAM     [8]float64
+	RAMAddr [4]float64
+
+	MAR, PC [4]float64
+
+	CLK, HLT, CF, ZF float64
+
+	// Step is the micro instruction counter, one LED per step
+	Step [Steps]float64
+
+	// Control holds the control signals, indexed by their bit in the
+	// control word
+	Control [numSignals]float64
+}
+
+func addBits(acc []float64, v uint, w float64) {
+	for i := range acc {
+		if v>>uint(i)&1 != 0 {
+			acc[i] += w
+		}
+	}
+}
+
+func b2u(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func weight(b bool, w float64) float64 {
+	if b {
+		return w
+	}
+	return 0
+}
+
+// sample adds the current LEDs of cpu.
+func (d *DutyCycle) sample(c *BBCpu) {
+	w := 1.0
+	if c.CLK.Timing != nil {
+		w = c.CLK.Width
+	}
+	d.samples++
+	d.total += w
+	addBits(d.bus[:], uint(c.BUS), w)
+	addBits(d.a[:], uint(c.Areg.BUF), w)
+	addBits(d.b[:], uint(c.Breg.BUF), w)
+	addBits(d.out[:], uint(c.Oreg.BUF), w)
+	addBits(d.ir[:], uint(c.IR.BUF), w)
+	addBits(d.alu[:], uint(c.ALU.BUF), w)
+	addr := c.RAM.Address()
+	addBits(d.ram[:], uint(c.RAM.MEM[addr]), w)
+	addBits(d.addr[:], uint(addr), w)
+	addBits(d.mar[:], uint(c.MAR.BUF), w)
+	addBits(d.pc[:], uint(c.PC.CNT), w)
+	d.clk += weight(c.CLK.CLK, w)
+	d.hlt += weight(c.CL.HLT, w)
+	d.cf += weight(c.ALU.CF, w)
+	d.zf += weight(c.ALU.ZF, w)
+	if c.CL.Cnt < Steps {
+		d.step[c.CL.Cnt] += w
+	}
+	addBits(d.control[:], uint(c.CL.Word()), w)
+}
+
+func fractions(dst []float64, acc []float64, n float64) {
+	for i := range dst {
+		dst[i] = acc[i] / n
+	}
+}
+
+// Levels returns the fraction of samples each LED was lit in since the last
+// call to Reset, weighted by the length of their clock phase.
+func (d *DutyCycle) Levels() Levels {
+	var l Levels
+	n := d.total
+	if d.samples == 0 || n == 0 {
+		return l
+	}
+	fractions(l.BUS[:], d.bus[:], n)
+	fractions(l.A[:], d.a[:], n)
+	fractions(l.B[:], d.b[:], n)
+	fractions(l.Out[:], d.out[:], n)
+	fractions(l.IR[:], d.ir[:], n)
+	fractions(l.ALU[:], d.alu[:], n)
+	fractions(l.RAM[:], d.ram[:], n)
+	fractions(l.RAMAddr[:], d.addr[:], n)
+	fractions(l.MAR[:], d.mar[:], n)
+	fractions(l.PC[:], d.pc[:], n)
+	fractions(l.Step[:], d.step[:], n)
+	fractions(l.Control[:], d.control[:], n)
+	l.CLK = d.clk / n
+	l.HLT = d.hlt / n
+	l.CF = d.cf / n
+	l.ZF = d.zf / n
+	return l
+}
+
+// Samples returns the number of samples taken since the last call to Reset.
+func (d *DutyCycle) Samples() uint64 {
+	return d.samples
+}
+
+// Reset discards all samples.
+func (d *DutyCycle) Reset() {
+	*d = DutyCycle{}
+}
+
+// DutyLevels returns the duty cycle of each LED since the previous call and
+// starts a new period, typically once per displayed frame. If no cycles were
+// executed since, e.g. while the clock is stopped, or Duty is nil, the LEDs
+// as they are now are returned.
+func (c *BBCpu) DutyLevels() Levels {
+	if c.Duty == nil || c.Duty.Samples() == 0 {
+		var d DutyCycle
+		d.sample(c)
+		return d.Levels()
+	}
+	l := c.Duty.Levels()
+	c.Duty.Reset()
+	return l
+}
+
+// DimLED renders a single LED in color c, dimmed according to its duty cycle
+// level between 0 and 1.
+func DimLED(level float64, c LEDColor) string {
+	switch {
+	case level < 0.1:
+		return LED(false, c)
+	case level < 0.6:
+		// the same color, but faint
+		return strings.Replace(string(c), "1;", "2;", 1) + "●" + ansiReset
+	}
+	return LED(true, c)
+}
+
+// DimLEDs renders levels as a row of dimmed LEDs in color c, with the most
+// significant bit, i.e. the last element of levels, first.
+func DimLEDs(levels []float64, c LEDColor) string {
+	var b strings.Builder
+	for i := len(levels) - 1; i >= 0; i-- {
+		b.WriteString(DimLED(levels[i], c))
+	}
+	return b.String()
+}
@@ -10,6 +10,7 @@ package eatersim
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 // Clk represents the Clock-board
@@ -19,7 +20,17 @@ type Clk struct {
 
 	// control signals
 	// HLT stops blocks the CLK from pulsing when true
-	HLT *bool
+	// STOP is the external halt input, e.g. a halt switch or a watchdog, which
+	// stops the clock like HLT
+	HLT, STOP *bool
+
+	// Timing models the duty cycle and jitter of the clock if not nil
+	Timing *ClockTiming
+
+	// Time is the time the clock has run for and Width the length of its
+	// current phase, both in nominal clock periods. They are only tracked
+	// with Timing set.
+	Time, Width float64
 }
 
 // NewClk creates a new clock board and initialize it's signals with the signals
@@ -33,20 +44,29 @@ func NewClk(hlt *bool) *Clk {
 // Executes the logic of the clock once. Updates the internal states based on
 // the previous state of the clock and the current state of the signals.
 func (c *Clk) Exec() {
-	if !ptbool(c.HLT) {
+	if !ptbool(c.HLT) && !ptbool(c.STOP) {
 		c.CLK = !c.CLK
 	} else {
 		c.CLK = false
 	}
+	if c.Timing != nil {
+		c.Time += c.Width
+		c.Width = c.Timing.phase(c.CLK)
+	}
 }
 
 // Implements the Stringer-interface
 func (c *Clk) String() string {
 	s := fmt.Sprintf("CLK: %v\n", c.CLK)
 	s += "\nactive control signals: "
-	if ptbool(c.HLT) {
+	switch {
+	case ptbool(c.HLT) && ptbool(c.STOP):
+		s += "HLT, STOP"
+	case ptbool(c.HLT):
 		s += "HLT"
-	} else {
+	case ptbool(c.STOP):
+		s += "STOP"
+	default:
 		s += "none"
 	}
 	return s
@@ -142,11 +162,16 @@ func (r *Reg) String() string {
 
 // Ireg represents the instruction register board.
 // Reads data from bus to buffer on positive clock edge when enable input is true.
-// Writes the 4 least significant bits from register to bus when enable output is true.
+// Writes the operand bits from register to bus when enable output is true.
 type Ireg struct {
 	// internal instruction register buffer
 	BUF byte
 
+	// OperandBits is the number of least significant bits of the buffer
+	// holding the operand, the remaining bits are the opcode. Ben's build
+	// splits the instruction 4/4, see BBCpu.SetOperandBits.
+	OperandBits byte
+
 	// bus signal
 	// r/w
 	BUS *byte
@@ -159,6 +184,13 @@ type Ireg struct {
 	// EO enables output from the register to the bus
 	CLK, CLR, EI, EO *bool
 
+	// EF (enable full) enables output of all 8 bits of the buffer to the bus,
+	// while EO outputs the operand bits only. It is meant for custom
+	// instruction sets packing data differently, the control logic of Ben's
+	// build leaves it unconnected.
+	// read only
+	EF *bool
+
 	// helper states
 	clkprev, clkre bool
 }
@@ -167,6 +199,7 @@ type Ireg struct {
 // with the signals passed in the function call
 func NewIreg(bus *byte, clk, clr, ei, eo *bool) *Ireg {
 	r := new(Ireg)
+	r.OperandBits = 4
 	r.BUS = bus
 	r.CLK = clk
 	r.CLR = clr
@@ -188,8 +221,19 @@ func (r *Ireg) Exec() {
 		r.BUF = 0
 	}
 	if ptbool(r.EO) && r.BUS != nil {
-		*r.BUS = r.BUF & 0x0f
+		*r.BUS = r.BUF & operandMask(r.OperandBits)
+	}
+	if ptbool(r.EF) && r.BUS != nil {
+		*r.BUS = r.BUF
+	}
+}
+
+// operandMask returns the mask of the n least significant bits of a byte.
+func operandMask(n byte) byte {
+	if n >= 8 {
+		return 0xff
 	}
+	return 1<<n - 1
 }
 
 // Implements the Stringer-interface
@@ -222,6 +266,13 @@ func (r *Ireg) String() string {
 		s += "EO"
 		f = true
 	}
+	if ptbool(r.EF) {
+		if f {
+			s += ", "
+		}
+		s += "EF"
+		f = true
+	}
 	if !f {
 		s += "none"
 	}
@@ -327,6 +378,22 @@ type Mem struct {
 	// RO (ram output) enables output from the memory to the bus
 	CLK, RI, RO *bool
 
+	// front panel switches
+	// Prog is the RUN/PROG switch, in programming mode the address is taken
+	// from the address switches instead of the Addr signal and RI is ignored
+	// AddrSW are the 4 address DIP switches
+	// DataSW are the 8 data DIP switches, stored by the Program button
+	Prog           bool
+	AddrSW, DataSW byte
+
+	// ROM holds a bit per address, bit n set makes address n read-only. Writes
+	// to read-only addresses from the bus or the program button are ignored,
+	// see SetROM and Protect.
+	ROM uint16
+
+	// OnFault, if not nil, is called with each write refused because of ROM
+	OnFault func(f *WriteFault)
+
 	// helper states
 	clkprev, clkre bool
 }
@@ -348,19 +415,37 @@ func (m *Mem) Exec() {
 	m.clkre = ptbool(m.CLK) && !m.clkprev
 	m.clkprev = ptbool(m.CLK)
 
-	if ptbool(m.RI) && m.clkre {
-		m.MEM[int(ptbyte(m.Addr)&0x0f)] = ptbyte(m.BUS)
+	if ptbool(m.RI) && m.clkre && !m.Prog {
+		m.store(m.Address(), ptbyte(m.BUS))
 	}
 
 	if ptbool(m.RO) && m.BUS != nil {
-		*m.BUS = m.MEM[int(ptbyte(m.Addr)&0x0f)]
+		*m.BUS = m.MEM[m.Address()]
+	}
+}
+
+// Address returns the selected memory address, from the address switches in
+// programming mode or from the Addr signal in run mode.
+func (m *Mem) Address() byte {
+	if m.Prog {
+		return m.AddrSW & 0x0f
+	}
+	return ptbyte(m.Addr) & 0x0f
+}
+
+// Program presses the program button, which stores the value of the data
+// switches at the address of the address switches. Does nothing in run mode.
+func (m *Mem) Program() {
+	if m.Prog {
+		m.store(m.AddrSW, m.DataSW)
 	}
 }
 
 // Implements the Writer-interface. Overwrites the memory with the values in p.
 // If p is greater than the memory, write will read the first 16 bytes of p into
 // the memory and return an error. If p is shorter than 16 bytes, the remaining
-// locations in the memory will be left untouched.
+// locations in the memory will be left untouched. Read-only addresses are
+// written too, as by an EEPROM programmer.
 func (m *Mem) Write(p []byte) (n int, err error) {
 	n = len(p)
 
@@ -378,7 +463,10 @@ func (m *Mem) Write(p []byte) (n int, err error) {
 
 // Implements the Stringer-interface
 func (m *Mem) String() string {
-	s := fmt.Sprintf("Addr: %04b, MEM: %04b", ptbyte(m.Addr)&0x0f, m.MEM[int(ptbyte(m.Addr)&0x0f)])
+	s := fmt.Sprintf("Addr: %04b, MEM: %04b", m.Address(), m.MEM[m.Address()])
+	if m.Prog {
+		s += fmt.Sprintf("\nprogramming mode, switches: Addr: %04b, Data: %08b", m.AddrSW&0x0f, m.DataSW)
+	}
 	s += "\nactive control signals: "
 	f := false
 	if ptbool(m.CLK) {
@@ -438,6 +526,27 @@ type Alu struct {
 	// ZF is the zero flag
 	CF, ZF bool
 
+	// SubCarry selects whether a subtraction sets the carry flag if it
+	// borrows, the default, or if it does not borrow, like the real hardware
+	// does. Programs using JC after SUB written for the real machine need
+	// CarryNoBorrow.
+	SubCarry CarryPolarity
+
+	// ZeroFrom selects whether FI latches the zero flag of the result of the
+	// alu, the default, or of the value on the bus, like the real hardware
+	// does. Both are the same unless FI is active without EO.
+	ZeroFrom ZeroSource
+
+	// Select holds the operation select lines of custom machines besides SU.
+	// SU is bit 0 of the selector of the operation, Select[i] is bit i+1
+	// read only
+	Select []*bool
+
+	// Ops maps selectors to the operations registered with SetOp, which
+	// replace the built-in addition, selected by 0, and subtraction, selected
+	// by 1
+	Ops map[int]AluOp
+
 	// helper variables
 	clkprev, clkre bool
 	bufCF, bufZF   bool
@@ -466,6 +575,9 @@ func (a *Alu) Exec() {
 	if ptbool(a.FI) && a.clkre {
 		a.CF = a.bufCF
 		a.ZF = a.bufZF
+		if a.ZeroFrom == ZeroFromBus {
+			a.ZF = ptbyte(a.BUS) == 0
+		}
 	}
 
 	if ptbool(a.CLR) {
@@ -473,22 +585,9 @@ func (a *Alu) Exec() {
 		a.ZF = false
 	}
 
-	if !ptbool(a.SU) {
-		// adding
-		a.BUF = ptbyte(a.Areg) + ptbyte(a.Breg)
-
-		// set flags reg
-		a.bufCF = 0xff < int(ptbyte(a.Areg))+int(ptbyte(a.Breg))
-		a.bufZF = a.BUF == 0
-
-	} else {
-		// subtracting
-		a.BUF = ptbyte(a.Areg) - ptbyte(a.Breg)
-
-		// set flags reg
-		a.bufCF = ptbyte(a.Areg) < ptbyte(a.Breg)
-		a.bufZF = a.BUF == 0
-	}
+	// the operation selected by the select lines, see SetOp
+	a.BUF, a.bufCF = a.Op(a.Selector())(ptbyte(a.Areg), ptbyte(a.Breg))
+	a.bufZF = a.BUF == 0
 
 	if ptbool(a.EO) && a.BUS != nil {
 		*a.BUS = a.BUF
@@ -568,6 +667,9 @@ type Ctr struct {
 	// read write
 	CNT byte
 
+	// reset vector, the counter value after clearing the counter
+	Vector byte
+
 	// bus signals
 	// read/write
 	BUS *byte
@@ -611,7 +713,7 @@ func (c *Ctr) Exec() {
 	}
 
 	if ptbool(c.CLR) {
-		c.CNT = 0
+		c.CNT = c.Vector & 0x0f
 	}
 
 	if ptbool(c.CO) && c.BUS != nil {
@@ -663,17 +765,38 @@ func (c *Ctr) String() string {
 }
 
 // Ctrl represents the control logic board. It reads the current instruction
-// code from Inst and uses the instruction code in combination with an internal
-// micro instruction counter to determine which control signals should be set.
-// The internal micro instruction counter is incremented on falling clock edge.
+// code from Inst and uses the instruction code in combination with the micro
+// instruction step from Step to determine which control signals should be set.
+// Without a Step signal an internal micro instruction counter is used instead,
+// which is incremented on falling clock edge.
 type Ctrl struct {
 	// Instruction code signal
 	// read only
 	Inst *byte
 
-	// micro instruction counter
+	// OperandBits is the number of least significant bits of the instruction
+	// code holding the operand, see Ireg.OperandBits. The opcode is the rest.
+	OperandBits byte
+
+	// micro instruction step signal from the step counter
+	// read only
+	Step *byte
+
+	// micro instruction counter, a copy of the Step signal if connected
 	Cnt byte
 
+	// Manual disengages the decoding of instructions, the control signals
+	// are those of Override instead, see BBCpu.SetManual and NewManualBBCpu.
+	// Override may be set directly, but unlike BBCpu.SetControl it is not
+	// checked for bus conflicts
+	Manual   bool
+	Override ControlWord
+
+	// Table is the microcode table decoding the instructions, indexed by
+	// opcode and step, DefaultMicrocode if nil. See NewCtrlWithMicrocode
+	// and WithMicrocode
+	Table *Microcode
+
 	// clock signal
 	// read only
 	CLK *bool
@@ -696,7 +819,8 @@ type Ctrl struct {
 
 	// b register control flag
 	// BI is the signal to read from the bus into register b
-	BI bool
+	// BO is the signal to write from register b to the bus
+	BI, BO bool
 
 	// output register control flag
 	// OI is the signal to read from the bus into the output register
@@ -738,22 +862,38 @@ type Ctrl struct {
 func NewCtrl(inst *byte, clk, clr, cf, zf *bool) *Ctrl {
 	c := new(Ctrl)
 	c.Inst = inst
+	c.OperandBits = 4
 	c.CLK = clk
 	c.CF = cf
 	c.ZF = zf
 	return c
 }
 
+// NewCtrlWithMicrocode creates a new control logic board like NewCtrl, which
+// decodes the instructions by the microcode table m instead of
+// DefaultMicrocode, e.g. to define custom instructions. Conditional
+// instructions set different steps for the states of the flags, see
+// Microcode.Set.
+func NewCtrlWithMicrocode(m Microcode, inst *byte, clk, clr, cf, zf *bool) *Ctrl {
+	c := NewCtrl(inst, clk, clr, cf, zf)
+	c.Table = &m
+	return c
+}
+
 // Exec executes the logic of the control logic board once.
 func (c *Ctrl) Exec() {
 	c.clkfe = !ptbool(c.CLK) && c.clkprev
 	c.clkprev = ptbool(c.CLK)
 
-	if c.clkfe {
+	if c.Step != nil {
+		c.Cnt = *c.Step
+	} else if c.clkfe {
 		c.Cnt++
 	}
 
-	if c.Cnt == 5 {
+	// wrap after the last step only, a step signal beyond it is left for the
+	// invariant checks
+	if c.Cnt == Steps {
 		c.Cnt = 0
 	}
 
@@ -762,128 +902,83 @@ func (c *Ctrl) Exec() {
 	if c.CLR {
 		c.Cnt = 0
 		c.HLT = false
-		if c.clrrst == 1 {
+		// the clear is released with the clock low only, so that the first
+		// fetch step is set up before the next rising clock edge like after
+		// a falling edge, and the counter drives the bus when it is latched
+		if c.clrrst == 1 && !ptbool(c.CLK) {
 			c.CLR = false
 			c.clrrst = 0
 		}
-		if c.clrrst > 0 {
+		if c.clrrst > 1 {
 			c.clrrst -= 1
 		}
-		return
+		if c.CLR {
+			return
+		}
 	}
 
-	switch c.Cnt {
-	case 0:
-		// fetch 1
-		c.CO, c.MI = true, true
+	c.decode()
+}
 
-	case 1:
-		// fetch 2
-		c.RO, c.II, c.CE = true, true, true
+// decode activates the control signals of the current step, the Override
+// word in manual mode.
+func (c *Ctrl) decode() {
+	if c.Manual {
+		c.setWord(c.Override)
+		return
+	}
 
-	default:
-		switch ptbyte(c.Inst) >> 4 {
-		case 0x0:
-			// nop
-
-		case 0x1:
-			// lda
-			switch c.Cnt {
-			case 2:
-				c.IO, c.MI = true, true
-			case 3:
-				c.RO, c.AI = true, true
-			}
-
-		case 0x2:
-			// add
-			switch c.Cnt {
-			case 2:
-				c.IO, c.MI = true, true
-			case 3:
-				c.RO, c.BI = true, true
-			case 4:
-				c.EO, c.AI, c.FI = true, true, true
-			}
-
-		case 0x3:
-			// sub
-			switch c.Cnt {
-			case 2:
-				c.IO, c.MI = true, true
-			case 3:
-				c.RO, c.BI = true, true
-			case 4:
-				c.EO, c.AI, c.SU, c.FI = true, true, true, true
-			}
-
-		case 0x4:
-			// sta
-			switch c.Cnt {
-			case 2:
-				c.IO, c.MI = true, true
-			case 3:
-				c.AO, c.RI = true, true
-			}
-
-		case 0x5:
-			// ldi
-			switch c.Cnt {
-			case 2:
-				c.IO, c.AI = true, true
-			}
-
-		case 0x6:
-			// jmp
-			switch c.Cnt {
-			case 2:
-				c.IO, c.J = true, true
-			}
-
-		case 0x7:
-			// jc
-			switch c.Cnt {
-			case 2:
-				if ptbool(c.CF) {
-					c.IO, c.J = true, true
-				}
-			}
-
-		case 0x8:
-			// jz
-			switch c.Cnt {
-			case 2:
-				if ptbool(c.ZF) {
-					c.IO, c.J = true, true
-				}
-			}
-
-		case 0xe:
-			// out
-			switch c.Cnt {
-			case 2:
-				c.AO, c.OI = true, true
-			}
-
-		case 0xf:
-			// hlt
-			switch c.Cnt {
-			case 2:
-				c.HLT = true
-			}
-		}
+	m := c.Table
+	if m == nil {
+		m = &defaultMicrocode
 	}
+	// the halt signal stays active once set
+	hlt := c.HLT
+	c.setWord(m.word(c.Opcode(), c.Cnt, ptbool(c.CF), ptbool(c.ZF)))
+	c.HLT = c.HLT || hlt
 }
 
-// Reset activates the CLR flag and keeps it active until the second call to Exec.
-func (c *Ctrl) Reset() {
+// Opcode returns the opcode of the instruction code. Opcodes above $f have no
+// instruction and do nothing, like NOP.
+func (c *Ctrl) Opcode() byte {
+	if c.OperandBits >= 8 {
+		return 0
+	}
+	return ptbyte(c.Inst) >> c.OperandBits
+}
+
+// MicroOp returns the current micro operation as the step and the active
+// control signals, e.g. "T3: RO|BI".
+func (c *Ctrl) MicroOp() string {
+	return fmt.Sprintf("T%d: %s", c.Cnt, c.Word())
+}
+
+// Clear activates the CLR flag like the reset button, resetting the boards
+// wired to the CLR signal, the control logic itself included. It takes effect
+// with the next Exec and is kept active until the second call to Exec, or the
+// next one with the clock low if the clock is high then.
+func (c *Ctrl) Clear() {
 	c.CLR = true
 	c.clrrst = 2
 }
 
+// Reset puts the control logic into its state after a clear released with
+// the clock low at once: CLR is inactive, the clock is no longer halted and
+// the first fetch step is set up. Reset used to activate the CLR flag
+// instead; that is Clear now, use it to reset the boards wired to the CLR
+// signal as well.
+func (c *Ctrl) Reset() {
+	c.CLR, c.clrrst = false, 0
+	c.HLT = false
+	c.Cnt = 0
+	c.clkprev = false
+	c.resetFlags()
+	c.decode()
+}
+
 // Implements the Stringer-interface
 func (c *Ctrl) String() string {
-	s := fmt.Sprintf("Inst: %04b, CNT: %04b", ptbyte(c.Inst)>>4, c.Cnt&0x0f)
+	s := fmt.Sprintf("Inst: %04b, CNT: %04b", c.Opcode(), c.Cnt&0x0f)
 
 	s += "\nactive status flags: "
 	f := false
@@ -905,113 +1000,7 @@ func (c *Ctrl) String() string {
 		s += "none"
 	}
 
-	s += "\nactive control flags: "
-	f = false
-	if c.AI {
-		s += "AI"
-		f = true
-	}
-	if c.AO {
-		if f {
-			s += ", "
-		}
-		s += "AO"
-		f = true
-	}
-	if c.BI {
-		if f {
-			s += ", "
-		}
-		s += "BI"
-		f = true
-	}
-	if c.OI {
-		if f {
-			s += ", "
-		}
-		s += "OI"
-		f = true
-	}
-	if c.MI {
-		if f {
-			s += ", "
-		}
-		s += "MI"
-		f = true
-	}
-	if c.II {
-		if f {
-			s += ", "
-		}
-		s += "II"
-		f = true
-	}
-	if c.IO {
-		if f {
-			s += ", "
-		}
-		s += "IO"
-		f = true
-	}
-	if c.EO {
-		if f {
-			s += ", "
-		}
-		s += "EO"
-		f = true
-	}
-	if c.SU {
-		if f {
-			s += ", "
-		}
-		s += "SU"
-		f = true
-	}
-	if c.FI {
-		if f {
-			s += ", "
-		}
-		s += "FI"
-		f = true
-	}
-	if c.CO {
-		if f {
-			s += ", "
-		}
-		s += "CO"
-		f = true
-	}
-	if c.J {
-		if f {
-			s += ", "
-		}
-		s += "J"
-		f = true
-	}
-	if c.CE {
-		if f {
-			s += ", "
-		}
-		s += "CE"
-		f = true
-	}
-	if c.RI {
-		if f {
-			s += ", "
-		}
-		s += "RI"
-		f = true
-	}
-	if c.RO {
-		if f {
-			s += ", "
-		}
-		s += "RO"
-		f = true
-	}
-	if !f {
-		s += "none"
-	}
+	s += "\nmicro operation: " + c.MicroOp()
 
 	s += "\nactive control signals: "
 	f = false
@@ -1031,7 +1020,7 @@ func (c *Ctrl) resetFlags() {
 	c.AI, c.AO = false, false
 
 	// b register control flag
-	c.BI = false
+	c.BI, c.BO = false, false
 
 	// output register control flag
 	c.OI = false
@@ -1061,6 +1050,9 @@ type BBCpu struct {
 	// Control logics board
 	CL *Ctrl
 
+	// Micro instruction step counter of the control logic
+	Ring *Ring
+
 	// A register, B register and output register board
 	Areg, Breg, Oreg *Reg
 
@@ -1079,22 +1071,94 @@ type BBCpu struct {
 	// Random Access Memory board
 	RAM *Mem
 
+	// Display of the output register board. It runs on its own refresh
+	// clock and is not executed by Exec, call its Exec to refresh it or add
+	// it to a clock domain.
+	Display *Display
+
+	// Outputs records the values latched by the output register if not nil
+	Outputs *OutputCapture
+
+	// Trace writes the states of the cpu to a trace sink if not nil
+	Trace *Tracer
+
+	// Domains are the clock domains of the peripherals, executed after the
+	// boards by Exec, see AddClockDomain
+	Domains []*ClockDomain
+
 	// Data Bus
 	BUS byte
+
+	// Stop is the external halt input. While it is true the clock is stopped
+	// as by the HLT instruction, and Run, RunAt and Instruction return. Host
+	// code or a peripheral may assert it to emulate a halt switch or a
+	// watchdog.
+	Stop bool
+
+	// Cycles counts the full clock cycles, i.e. the rising clock edges, since
+	// the cpu was created
+	Cycles uint64
+
+	// Hz is the clock frequency in full clock cycles per second, used to track
+	// the simulated time, see Time. A new cpu starts at DefaultHz, RunAt sets
+	// the frequency it runs at.
+	Hz float64
+
+	// simulated time since the cpu was created, in nanoseconds
+	ns float64
+
+	// Render selects how String renders the cpu, as text by default
+	Render RenderMode
+
+	// Duty accumulates the duty cycles of the LEDs if not nil
+	Duty *DutyCycle
+
+	// Writes records the recent writes to the memory if not nil
+	Writes *WriteHistory
+
+	// OnViolation, if not nil, enables checking the machine invariants after
+	// every half step, see CheckInvariants, and is called with each
+	// violation
+	OnViolation func(v *Violation)
+
+	// Triggers are checked after every half step, see Trigger
+	Triggers []*Trigger
+
+	// ResetOnLoad resets the cpu after each Load
+	ResetOnLoad bool
+
+	// OnStale, if not nil, is called by Run and RunAt with the reason if
+	// a program was loaded without a reset after a prior run, see Stale
+	OnStale func(reason string)
+
+	// loaded is set by a Load without reset and cleared by Reset
+	loaded bool
+
+	// run coordinates Pause and Resume with the Run and RunAt loops
+	run runState
+
+	// persist saves a battery backed memory on halt, see
+	// WithPersistentMemory
+	persist persistence
 }
 
 // NewBBCpu creates a new 8-bit breadboard CPU and initialize the interface
 // between all the boards according to Ben Eaters instructions.
 func NewBBCpu() *BBCpu {
 	cpu := new(BBCpu)
+	cpu.Hz = DefaultHz
 
 	cpu.CL = new(Ctrl)
 
 	cpu.CLK = NewClk(&cpu.CL.HLT)
+	cpu.CLK.STOP = &cpu.Stop
+
+	cpu.Ring = NewRing(&cpu.CLK.CLK, &cpu.CL.CLR)
 
 	cpu.Areg = NewReg(&cpu.BUS, &cpu.CLK.CLK, &cpu.CL.CLR, &cpu.CL.AI, &cpu.CL.AO)
-	cpu.Breg = NewReg(&cpu.BUS, &cpu.CLK.CLK, &cpu.CL.CLR, &cpu.CL.BI, nil)
+	cpu.Breg = NewReg(&cpu.BUS, &cpu.CLK.CLK, &cpu.CL.CLR, &cpu.CL.BI, &cpu.CL.BO)
 	cpu.Oreg = NewReg(&cpu.BUS, &cpu.CLK.CLK, &cpu.CL.CLR, &cpu.CL.OI, nil)
+	cpu.Display = NewDisplay(&cpu.Oreg.BUF)
 
 	cpu.ALU = NewAlu(&cpu.Areg.BUF, &cpu.Breg.BUF, &cpu.BUS, &cpu.CLK.CLK, &cpu.CL.CLR, &cpu.CL.EO, &cpu.CL.SU, &cpu.CL.FI)
 
@@ -1106,7 +1170,9 @@ func NewBBCpu() *BBCpu {
 	cpu.IR = NewIreg(&cpu.BUS, &cpu.CLK.CLK, &cpu.CL.CLR, &cpu.CL.II, &cpu.CL.IO)
 
 	cpu.CL.CLK = &cpu.CLK.CLK
+	cpu.CL.Step = &cpu.Ring.Cnt
 	cpu.CL.Inst = &cpu.IR.BUF
+	cpu.CL.OperandBits = cpu.IR.OperandBits
 	cpu.CL.CF = &cpu.ALU.CF
 	cpu.CL.ZF = &cpu.ALU.ZF
 
@@ -1115,7 +1181,15 @@ func NewBBCpu() *BBCpu {
 
 // Exec executes the control logic of all the boards once.
 func (c *BBCpu) Exec() {
+	clk, hlt := c.CLK.CLK, c.CL.HLT
 	c.CLK.Exec()
+	if c.CLK.CLK && !clk {
+		c.Cycles++
+	}
+	if c.CLK.CLK != clk && c.Hz > 0 {
+		c.ns += 0.5e9 / c.Hz
+	}
+	c.Ring.Exec()
 	c.CL.Exec()
 	c.Areg.Exec()
 	c.Breg.Exec()
@@ -1125,26 +1199,127 @@ func (c *BBCpu) Exec() {
 	c.RAM.Exec()
 	c.PC.Exec()
 	c.IR.Exec()
+	for _, d := range c.Domains {
+		d.Exec()
+	}
+	if c.Outputs != nil && c.CLK.CLK && !clk && c.CL.OI {
+		c.Outputs.record(c.Oreg.BUF, c.Cycles)
+	}
+	c.persistOnHalt(hlt)
+	if len(c.Triggers) > 0 {
+		c.checkTriggers()
+	}
+	if c.Duty != nil {
+		c.Duty.sample(c)
+	}
+	if c.Writes != nil {
+		c.Writes.Observe(c.State())
+	}
+	if c.Trace != nil {
+		c.Trace.Observe(c.State())
+	}
+	if c.OnViolation != nil {
+		if err := c.CheckInvariants(); err != nil {
+			c.OnViolation(err.(*Violation))
+		}
+	}
 }
 
-// Run executes the logic of the breadboard cpu until it halts
-func (c *BBCpu) Run() {
-	for !c.CL.HLT {
+// Run executes the logic of the breadboard cpu until it halts and returns why
+// it stopped, StopHalt or StopExternal. The loop may be paused and resumed
+// from other goroutines, see Pause. To stop for other reasons as well, see
+// RunContext.
+func (c *BBCpu) Run() StopReason {
+	c.warnStale()
+	c.setRunning(true)
+	defer c.setRunning(false)
+	for !c.Halted() {
+		c.checkPause()
 		c.Exec()
 	}
+	return c.haltReason()
+}
+
+// RunAt executes the logic of the breadboard cpu until it halts, at a clock
+// frequency of approximately hz full clock cycles per second, and sets Hz to
+// hz. A frequency less than or equal to zero runs as fast as possible, like
+// Run. Returns why the cpu stopped, like Run.
+func (c *BBCpu) RunAt(hz float64) StopReason {
+	if hz <= 0 {
+		return c.Run()
+	}
+	c.Hz = hz
+	c.warnStale()
+	c.setRunning(true)
+	defer c.setRunning(false)
+	p := NewPacer(hz, c.Cycles)
+	for !c.Halted() {
+		if c.checkPause() {
+			// do not catch up with the time spent paused
+			p = NewPacer(hz, c.Cycles)
+		}
+		p.Wait(c.Cycles)
+		c.Exec()
+	}
+	return c.haltReason()
+}
+
+// DefaultHz is the clock frequency of a new cpu in full clock cycles per
+// second.
+const DefaultHz = 1000
+
+// Time returns the simulated time since the cpu was created: the clock edges
+// so far, each taking half a clock period at the frequency Hz had when the
+// edge occurred.
+func (c *BBCpu) Time() time.Duration {
+	return time.Duration(c.ns)
+}
+
+// Pacer holds back a running cpu to keep a clock frequency. It is used by
+// RunAt, and may be used by code executing the cpu step by step.
+type Pacer struct {
+	hz     float64
+	start  time.Time
+	cycles uint64
+}
+
+// NewPacer creates a new pacer for a clock frequency of hz full clock cycles
+// per second, starting now at the cycle count cycles.
+func NewPacer(hz float64, cycles uint64) *Pacer {
+	return &Pacer{hz: hz, start: time.Now(), cycles: cycles}
+}
+
+// Wait sleeps until the cpu is due to execute the cycle following cycles. To
+// keep the overhead low at high frequencies, it sleeps at most once per
+// millisecond and lets the cpu catch up in between.
+func (p *Pacer) Wait(cycles uint64) {
+	due := p.start.Add(time.Duration(float64(cycles-p.cycles) / p.hz * float64(time.Second)))
+	if d := time.Until(due); d > time.Millisecond {
+		time.Sleep(d)
+	}
 }
 
 // Instruction executes the logic of the breadboard cpu until the current
 // instruction is complete. Returns immediately if clr or hlt is active.
 func (c *BBCpu) Instruction() {
+	if c.Halted() {
+		return
+	}
+
 	c.Exec()
 
-	for !(c.CL.Cnt == 4 && c.CLK.CLK) {
+	for !c.AtBoundary() && !c.Halted() {
 		c.Exec()
 	}
 
 }
 
+// Halted tells whether the clock is stopped, by the HLT instruction or by the
+// external halt input Stop.
+func (c *BBCpu) Halted() bool {
+	return c.CL.HLT || c.Stop
+}
+
 // Step executes the logic of the breadboard cpu twice, which means one full
 // clock cycle if the cpu is not halted. Synchronism to rising/falling edge of
 // clock must be checked manually.
@@ -1160,13 +1335,39 @@ func (c *BBCpu) HalfStep() {
 
 // Reset resets the breadboard cpu.
 func (c *BBCpu) Reset() {
-	c.CL.Reset()
-	c.Exec()
+	c.loaded = false
+	c.CL.Clear()
 	c.Exec()
+	for c.CL.CLR {
+		c.Exec()
+	}
 }
 
-// String implements the Stringer-interface
+// SetOperandBits splits the instruction in the instruction register into an
+// opcode and an operand of n bits, for the instruction register and the control
+// logic alike. Ben's build uses 4 bits, e.g. 5 makes a 3/5 split with 8
+// opcodes and a wider operand. Only splits with an operand of at least 4 bits
+// are supported: the microcode and Timing hold 16 opcodes, so a 5/3 split or
+// 8-bit opcodes are refused with an error, as is more than 8 bits. The
+// assembler and the reference model know the 4/4 split only.
+func (c *BBCpu) SetOperandBits(n byte) error {
+	if n < 4 {
+		return fmt.Errorf("%d operand bits leave %d opcodes, the microcode decodes %d", n, 1<<(8-n), Opcodes)
+	}
+	if n > 8 {
+		return fmt.Errorf("invalid number of operand bits %d", n)
+	}
+	c.IR.OperandBits = n
+	c.CL.OperandBits = n
+	return nil
+}
+
+// String implements the Stringer-interface. Renders the boards as text, or as
+// LEDs if Render is RenderLEDs.
 func (c *BBCpu) String() string {
+	if c.Render == RenderLEDs {
+		return c.LEDs()
+	}
 	s := fmt.Sprintf("bus:\nBUS: %08b\n\n", c.BUS)
 	s += fmt.Sprintf("areg:\n%s\n\n", c.Areg)
 	s += fmt.Sprintf("breg:\n%s\n\n", c.Breg)
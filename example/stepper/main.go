@@ -0,0 +1,129 @@
+// Stepper is a minimal interactive front end for the breadboard cpu, and an
+// example of driving the emulator by hand: it loads one of the demo programs
+// and shows the LEDs of the boards, redrawn after every half step.
+//
+//	go run ./example/stepper -program fibonacci
+//
+// The keys are:
+//
+//	space  pulse the clock, one half step per press
+//	r      toggle run mode, in which the clock pulses by itself
+//	q      quit
+//
+// The terminal is switched to unbuffered input with stty, so the example runs
+// on unix like systems only.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/oj-mik/eatersim"
+	"github.com/oj-mik/eatersim/programs"
+)
+
+func main() {
+	name := flag.String("program", "count", "demo program to run, one of "+strings.Join(programs.Names(), ", "))
+	hz := flag.Float64("hz", 4, "half steps per second in run mode")
+	flag.Parse()
+
+	if err := run(*name, *hz); err != nil {
+		fmt.Fprintln(os.Stderr, "stepper:", err)
+		os.Exit(1)
+	}
+}
+
+func run(name string, hz float64) error {
+	if hz <= 0 {
+		return fmt.Errorf("invalid frequency %g", hz)
+	}
+	p, err := programs.Load(name)
+	if err != nil {
+		return err
+	}
+	cpu, err := eatersim.New(eatersim.WithMemoryImage(p.Object.Bin))
+	if err != nil {
+		return err
+	}
+	cpu.Render = eatersim.RenderLEDs
+
+	restore, err := cbreak()
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	keys := make(chan byte)
+	go func() {
+		r := bufio.NewReader(os.Stdin)
+		for {
+			b, err := r.ReadByte()
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- b
+		}
+	}()
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / hz))
+	defer ticker.Stop()
+	running := false
+	draw(cpu, name, running)
+	for {
+		select {
+		case k, ok := <-keys:
+			switch {
+			case !ok || k == 'q':
+				return nil
+			case k == ' ' && !running:
+				cpu.HalfStep()
+			case k == 'r':
+				running = !running
+			}
+		case <-ticker.C:
+			if !running {
+				continue
+			}
+			cpu.HalfStep()
+		}
+		draw(cpu, name, running)
+	}
+}
+
+// draw redraws the screen with the LEDs of the boards.
+func draw(cpu *eatersim.BBCpu, name string, running bool) {
+	mode := "stepping, space pulses the clock"
+	if running {
+		mode = "running"
+	}
+	if cpu.Halted() {
+		mode = "halted"
+	}
+	fmt.Printf("\x1b[H\x1b[2J%s, cycle %d, %s\n\n%s\n\nspace: pulse  r: run/step  q: quit\n", name, cpu.Cycles, mode, cpu)
+}
+
+// cbreak switches the terminal to unbuffered input without echo and returns
+// a function restoring it.
+func cbreak() (restore func(), err error) {
+	state, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("standard input must be a terminal: %v", err)
+	}
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return nil, err
+	}
+	return func() { stty(strings.TrimSpace(state)) }, nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
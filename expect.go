@@ -0,0 +1,192 @@
+package eatersim
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxCycles is the cycle budget of an Expectation not stating one.
+const DefaultMaxCycles = 10000
+
+// Expectation describes the expected behavior of a program, for testing
+// programs the way 'go test' tests packages.
+type Expectation struct {
+	// Outputs holds the values the output register is expected to latch, in
+	// order
+	Outputs []byte
+
+	// MaxCycles is the number of full clock cycles the program may run
+	MaxCycles uint64
+
+	// Halt tells whether the program is expected to halt within MaxCycles. A
+	// program expected to run forever passes if its first outputs equal
+	// Outputs.
+	Halt bool
+}
+
+// ParseExpectation reads an expectation from r. Each line holds a key and a
+// value separated by a colon, text after '#' is a comment:
+//
+//	output: 3 6 9     # expected output values, may be repeated
+//	max-cycles: 1000  # cycle budget, default 10000
+//	halt: yes         # yes if the program must halt, no if it must not
+func ParseExpectation(r io.Reader) (*Expectation, error) {
+	e := &Expectation{MaxCycles: DefaultMaxCycles, Halt: true}
+
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		ln := sc.Text()
+		if i := strings.IndexByte(ln, '#'); i >= 0 {
+			ln = ln[:i]
+		}
+		if strings.TrimSpace(ln) == "" {
+			continue
+		}
+		kv := strings.SplitN(ln, ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("line %d: expecting 'key: value'", n)
+		}
+		key, val := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+
+		switch strings.ToLower(key) {
+		case "output":
+			for _, f := range strings.FieldsFunc(val, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+				v, err := strconv.ParseUint(f, 0, 8)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid output value %s", n, f)
+				}
+				e.Outputs = append(e.Outputs, byte(v))
+			}
+		case "max-cycles":
+			v, err := strconv.ParseUint(val, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid cycle count %s", n, val)
+			}
+			e.MaxCycles = v
+		case "halt":
+			switch strings.ToLower(val) {
+			case "yes", "true":
+				e.Halt = true
+			case "no", "false":
+				e.Halt = false
+			default:
+				return nil, fmt.Errorf("line %d: halt must be yes or no", n)
+			}
+		default:
+			return nil, fmt.Errorf("line %d: unknown key %s", n, key)
+		}
+	}
+	return e, sc.Err()
+}
+
+// Check runs cpu until it halts or MaxCycles full clock cycles have passed,
+// and returns an error describing the first way the program failed the
+// expectation, or nil if it passed.
+func (e *Expectation) Check(cpu *BBCpu) error {
+	var outputs []byte
+	start := cpu.Cycles
+	for !cpu.CL.HLT && cpu.Cycles-start < e.MaxCycles {
+		clk := cpu.CLK.CLK
+		cpu.Exec()
+		if cpu.CLK.CLK && !clk && cpu.CL.OI {
+			outputs = append(outputs, cpu.Oreg.BUF)
+		}
+	}
+
+	switch {
+	case e.Halt && !cpu.CL.HLT:
+		return fmt.Errorf("program did not halt within %v cycles, outputs %v", e.MaxCycles, outputs)
+	case !e.Halt && cpu.CL.HLT:
+		return fmt.Errorf("program halted after %v cycles, outputs %v", cpu.Cycles-start, outputs)
+	}
+
+	for i, want := range e.Outputs {
+		if i >= len(outputs) {
+			return fmt.Errorf("missing output %v: want %v, got outputs %v", i+1, want, outputs)
+		}
+		if outputs[i] != want {
+			return fmt.Errorf("output %v: want %v, got %v (outputs %v)", i+1, want, outputs[i], outputs)
+		}
+	}
+	if e.Halt && len(outputs) > len(e.Outputs) {
+		return fmt.Errorf("unexpected outputs: want %v, got %v", e.Outputs, outputs)
+	}
+	return nil
+}
+
+// Assertion builds an Expectation for a cpu step by step, for readable tests:
+//
+//	if err := eatersim.Expect(cpu).WithinCycles(500).OutputSequence(33, 66, 99).Halts(); err != nil {
+//		t.Error(err)
+//	}
+type Assertion struct {
+	cpu   *BBCpu
+	e     Expectation
+	conds []string
+}
+
+// Expect starts an assertion on the program loaded into cpu, with a budget of
+// DefaultMaxCycles full clock cycles and no expected outputs.
+func Expect(cpu *BBCpu) *Assertion {
+	return &Assertion{cpu: cpu, e: Expectation{MaxCycles: DefaultMaxCycles}}
+}
+
+// WithinCycles sets the number of full clock cycles the program may run.
+func (a *Assertion) WithinCycles(n uint64) *Assertion {
+	a.e.MaxCycles = n
+	return a
+}
+
+// OutputSequence appends values to the outputs the program is expected to
+// latch into the output register, in order.
+func (a *Assertion) OutputSequence(values ...byte) *Assertion {
+	a.e.Outputs = append(a.e.Outputs, values...)
+	return a
+}
+
+// Satisfies adds a watch expression which must be true at the end of the
+// run, e.g. "A == 15 && !CF", see Expr.
+func (a *Assertion) Satisfies(expr string) *Assertion {
+	a.conds = append(a.conds, expr)
+	return a
+}
+
+// Halts runs the program and returns an error if it does not halt within the
+// cycle budget, does not output exactly the expected sequence or does not
+// satisfy the expressions.
+func (a *Assertion) Halts() error {
+	a.e.Halt = true
+	return a.check()
+}
+
+// Runs runs the program for the whole cycle budget and returns an error if it
+// halts, its first outputs differ from the expected sequence or it does not
+// satisfy the expressions.
+func (a *Assertion) Runs() error {
+	a.e.Halt = false
+	return a.check()
+}
+
+func (a *Assertion) check() error {
+	exprs := make([]*Expr, len(a.conds))
+	for i, c := range a.conds {
+		e, err := ParseExpr(c, nil)
+		if err != nil {
+			return fmt.Errorf("%s: %s", c, err)
+		}
+		exprs[i] = e
+	}
+	if err := a.e.Check(a.cpu); err != nil {
+		return err
+	}
+	s := a.cpu.State()
+	for _, e := range exprs {
+		if !e.True(s) {
+			return fmt.Errorf("%s is false", e)
+		}
+	}
+	return nil
+}
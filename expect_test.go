@@ -0,0 +1,56 @@
+package eatersim
+
+import (
+	"os"
+	"testing"
+
+	"github.com/oj-mik/eatersim/assembler"
+)
+
+// fibonacci returns a cpu running programs/fibonacci.asm, which outputs the
+// fibonacci numbers up to 144 and starts over, without halting.
+func fibonacci(t *testing.T) *BBCpu {
+	t.Helper()
+	src, err := os.ReadFile("programs/fibonacci.asm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin, err := assembler.Assemble(string(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cpu, err := New(WithMemoryImage(bin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cpu
+}
+
+func TestExpect(t *testing.T) {
+	fib := []byte{0, 1, 1, 2, 3, 5, 8, 13, 21, 34, 55, 89, 144}
+	err := Expect(fibonacci(t)).WithinCycles(1000).OutputSequence(fib...).OutputSequence(fib...).Satisfies("!HLT").Runs()
+	if err != nil {
+		t.Error(err)
+	}
+
+	tests := []struct {
+		name string
+		run  func(a *Assertion) error
+		want string
+	}{
+		{"halts", func(a *Assertion) error { return a.WithinCycles(150).OutputSequence(0, 1, 1).Halts() },
+			"program did not halt within 150 cycles, outputs [0 1 1 2 3]"},
+		{"output", func(a *Assertion) error { return a.WithinCycles(150).OutputSequence(0, 1, 2).Runs() },
+			"output 3: want 2, got 1 (outputs [0 1 1 2 3])"},
+		{"missing", func(a *Assertion) error { return a.WithinCycles(20).OutputSequence(fib...).Runs() },
+			"missing output 2: want 1, got outputs [0]"},
+		{"expression", func(a *Assertion) error { return a.WithinCycles(60).Satisfies("OUT == 8").Runs() },
+			"OUT == 8 is false"},
+	}
+	for _, tt := range tests {
+		err := tt.run(Expect(fibonacci(t)))
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%s: error %v, want %s", tt.name, err, tt.want)
+		}
+	}
+}
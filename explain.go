@@ -0,0 +1,111 @@
+package eatersim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// the parts of the cpu writing to the bus, by their output signal
+var busSources = []struct {
+	sig  ControlWord
+	name string
+}{
+	{CO, "program counter"},
+	{RO, "RAM"},
+	{IO, "IR operand"},
+	{AO, "A register"},
+	{BO, "B register"},
+	{EO, "ALU"},
+}
+
+// the parts of the cpu reading from the bus, by their input signal
+var busSinks = []struct {
+	sig  ControlWord
+	name string
+}{
+	{MI, "MAR"},
+	{RI, "RAM"},
+	{II, "IR"},
+	{AI, "A register"},
+	{BI, "B register"},
+	{OI, "output register"},
+	{J, "program counter"},
+}
+
+// Explain narrates the half step of the cpu from the state before to the
+// state after it in a sentence, for following the cpu cycle by cycle, e.g.
+// "T2: IR operand $0e → bus → MAR because MI|IO are asserted". The control
+// logic sets up the control word on the falling clock edge, the boards act on
+// it on the rising edge.
+func Explain(before, after State) string {
+	switch {
+	case after.CLK && !before.CLK:
+		return fmt.Sprintf("T%d: %s", before.Step, explainEdge(before, after))
+	case !after.CLK && before.CLK:
+		signals := "no control signals"
+		if after.Control != 0 {
+			signals = after.Control.String()
+		}
+		if after.Step < 2 {
+			return fmt.Sprintf("T%d: the control logic fetches the next instruction and asserts %s", after.Step, signals)
+		}
+		return fmt.Sprintf("T%d: the control logic decodes IR $%02x and asserts %s", after.Step, after.IR, signals)
+	}
+	return fmt.Sprintf("T%d: the clock is stopped", after.Step)
+}
+
+// explainEdge narrates what the boards do on the rising clock edge, given the
+// control word and bus of the state before it.
+func explainEdge(before, after State) string {
+	w := before.Control
+	var parts []string
+
+	var from, to []string
+	for _, s := range busSources {
+		switch {
+		case w&s.sig == 0:
+		case s.sig == RO:
+			from = append(from, fmt.Sprintf("RAM[$%x]", before.MAR&0x0f))
+		case s.sig == EO && w&SU != 0:
+			from = append(from, "ALU A - B")
+		case s.sig == EO:
+			from = append(from, "ALU A + B")
+		default:
+			from = append(from, s.name)
+		}
+	}
+	for _, s := range busSinks {
+		switch {
+		case w&s.sig == 0:
+		case s.sig == RI:
+			to = append(to, fmt.Sprintf("RAM[$%x]", before.MAR&0x0f))
+		default:
+			to = append(to, s.name)
+		}
+	}
+	switch {
+	case len(from) > 0 && len(to) > 0:
+		parts = append(parts, fmt.Sprintf("%s $%02x → bus → %s", strings.Join(from, " and "), before.BUS, strings.Join(to, " and ")))
+	case len(from) > 0:
+		parts = append(parts, fmt.Sprintf("%s $%02x → bus, read by nobody", strings.Join(from, " and "), before.BUS))
+	case len(to) > 0:
+		parts = append(parts, fmt.Sprintf("%s latch the undriven bus $%02x", strings.Join(to, " and "), before.BUS))
+	}
+	if w&CE != 0 {
+		parts = append(parts, fmt.Sprintf("the program counter counts up to $%x", after.PC&0x0f))
+	}
+	if w&FI != 0 {
+		parts = append(parts, fmt.Sprintf("the flags latch CF=%d ZF=%d", b2byte(after.CF), b2byte(after.ZF)))
+	}
+	if w&HLT != 0 {
+		parts = append(parts, "the clock halts")
+	}
+	if len(parts) == 0 {
+		return "nothing happens, no control signals are asserted"
+	}
+	verb := "is"
+	if w&(w-1) != 0 {
+		verb = "are"
+	}
+	return fmt.Sprintf("%s because %s %s asserted", strings.Join(parts, ", "), w, verb)
+}
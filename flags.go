@@ -0,0 +1,120 @@
+package eatersim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CarryPolarity selects what the carry flag means after a subtraction.
+type CarryPolarity int
+
+// The carry polarities of a subtraction.
+const (
+	// CarryBorrow sets the carry flag if the subtraction borrows, which means
+	// if A < B
+	CarryBorrow CarryPolarity = iota
+
+	// CarryNoBorrow sets the carry flag if the subtraction does not borrow,
+	// which means if A >= B, like the real hardware does: it adds the ones'
+	// complement of B with a carry in of 1, and the adders carry out unless
+	// A < B
+	CarryNoBorrow
+)
+
+var carryPolarityNames = [...]string{
+	CarryBorrow:   "borrow",
+	CarryNoBorrow: "no-borrow",
+}
+
+// Implements the Stringer-interface
+func (p CarryPolarity) String() string {
+	if p < 0 || int(p) >= len(carryPolarityNames) {
+		return fmt.Sprintf("CarryPolarity(%d)", int(p))
+	}
+	return carryPolarityNames[p]
+}
+
+// ParseCarryPolarity returns the carry polarity called name, "borrow" or
+// "no-borrow". Names are not case sensitive.
+func ParseCarryPolarity(name string) (CarryPolarity, error) {
+	for p, n := range carryPolarityNames {
+		if strings.EqualFold(n, name) {
+			return CarryPolarity(p), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown carry polarity %s, expecting borrow or no-borrow", name)
+}
+
+// subCarry returns the carry flag of the subtraction a - b.
+func (p CarryPolarity) subCarry(a, b byte) bool {
+	if p == CarryNoBorrow {
+		return a >= b
+	}
+	return a < b
+}
+
+// ZeroSource selects what the zero flag is computed from.
+type ZeroSource int
+
+// The sources of the zero flag.
+const (
+	// ZeroFromALU computes the zero flag from the result of the alu, whether
+	// it is driven onto the bus or not
+	ZeroFromALU ZeroSource = iota
+
+	// ZeroFromBus computes the zero flag from the value on the bus, like the
+	// comparator of the real hardware does, so that FI without EO latches
+	// whatever another board drives onto the bus
+	ZeroFromBus
+)
+
+var zeroSourceNames = [...]string{
+	ZeroFromALU: "alu",
+	ZeroFromBus: "bus",
+}
+
+// Implements the Stringer-interface
+func (s ZeroSource) String() string {
+	if s < 0 || int(s) >= len(zeroSourceNames) {
+		return fmt.Sprintf("ZeroSource(%d)", int(s))
+	}
+	return zeroSourceNames[s]
+}
+
+// ParseZeroSource returns the zero flag source called name, "alu" or "bus".
+// Names are not case sensitive.
+func ParseZeroSource(name string) (ZeroSource, error) {
+	for s, n := range zeroSourceNames {
+		if strings.EqualFold(n, name) {
+			return ZeroSource(s), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown zero flag source %s, expecting alu or bus", name)
+}
+
+// WithSubCarry sets the carry polarity of subtractions, see Alu.
+func WithSubCarry(p CarryPolarity) Option {
+	return func(c *BBCpu) error {
+		c.ALU.SubCarry = p
+		return nil
+	}
+}
+
+// WithZeroSource sets what the zero flag is computed from, see Alu.
+func WithZeroSource(s ZeroSource) Option {
+	return func(c *BBCpu) error {
+		c.ALU.ZeroFrom = s
+		return nil
+	}
+}
+
+// WithHardwareFlags makes the flags behave like on the real hardware: a
+// subtraction sets the carry flag if it does not borrow, and the zero flag is
+// computed from the bus.
+func WithHardwareFlags() Option {
+	return func(c *BBCpu) error {
+		c.ALU.SubCarry = CarryNoBorrow
+		c.ALU.ZeroFrom = ZeroFromBus
+		return nil
+	}
+}
@@ -0,0 +1,38 @@
+package eatersim
+
+import "testing"
+
+func TestZeroSource(t *testing.T) {
+	// opcode $9 latches the flags while the instruction register drives its
+	// operand onto the bus, without the alu driving its result
+	m := DefaultMicrocode()
+	m.Set(0x9, 0, CO|MI, RO|II|CE, IO|FI)
+
+	tests := []struct {
+		name string
+		prog []byte
+		alu  bool
+		bus  bool
+	}{
+		// LDI 3, $90, HLT: the alu holds 3, the bus 0
+		{"bus zero", []byte{0x53, 0x90, 0xf0}, false, true},
+		// LDI 0, $95, HLT: the alu holds 0, the bus 5
+		{"alu zero", []byte{0x50, 0x95, 0xf0}, true, false},
+	}
+	for _, tt := range tests {
+		for _, src := range []ZeroSource{ZeroFromALU, ZeroFromBus} {
+			cpu, err := New(WithMemoryImage(tt.prog), WithMicrocode(m), WithZeroSource(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			cpu.Run()
+			want := tt.alu
+			if src == ZeroFromBus {
+				want = tt.bus
+			}
+			if cpu.ALU.ZF != want {
+				t.Errorf("%s, zero from %s: ZF %v, want %v", tt.name, src, cpu.ALU.ZF, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package eatersim
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+)
+
+// The palette of the frames drawn by Frame. Each LED color has a lit and an
+// unlit shade.
+var framePalette = color.Palette{
+	color.RGBA{0x18, 0x18, 0x18, 0xff}, // background
+	color.RGBA{0xc8, 0xc8, 0xc8, 0xff}, // labels
+	color.RGBA{0xff, 0x30, 0x30, 0xff}, // red
+	color.RGBA{0x48, 0x10, 0x10, 0xff},
+	color.RGBA{0x30, 0xe0, 0x30, 0xff}, // green
+	color.RGBA{0x10, 0x40, 0x10, 0xff},
+	color.RGBA{0xff, 0xd0, 0x20, 0xff}, // yellow
+	color.RGBA{0x48, 0x3c, 0x08, 0xff},
+	color.RGBA{0x40, 0x80, 0xff, 0xff}, // blue
+	color.RGBA{0x10, 0x20, 0x48, 0xff},
+}
+
+// palette indices of the background, the labels and the lit LED colors, the
+// unlit shade of each LED color follows its lit shade
+const (
+	frameBackground = 0
+	frameLabel      = 1
+	idxRed          = 2
+	idxGreen        = 4
+	idxYellow       = 6
+	idxBlue         = 8
+)
+
+// geometry of the frames in pixels
+const (
+	frameWidth  = 472
+	frameHeight = 232
+	ledPitch    = 16 // distance between LEDs in a row
+	ledRadius   = 5
+	rowPitch    = 24 // distance between rows
+	ctrlPitch   = 26 // distance between the LEDs of the control word
+	leftLEDs    = 56 // x of the first LED in the left column
+	rightLabels = 200
+	rightLEDs   = 248
+)
+
+// Frame draws the state as a panel of LEDs laid out like the boards of the
+// breadboard build, the bus on top, the control word at the bottom and the
+// output register as a seven segment display.
+func Frame(s State) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, frameWidth, frameHeight), framePalette)
+
+	row := func(r int) int { return 8 + r*rowPitch }
+
+	drawLabel(img, 8, row(0), "BUS")
+	drawLEDs(img, leftLEDs, row(0), uint(s.BUS), 8, idxYellow)
+
+	drawLabel(img, 8, row(1), "CLK")
+	drawLED(img, leftLEDs, row(1), s.CLK, idxBlue)
+	drawLabel(img, leftLEDs+2*ledPitch, row(1), "HLT")
+	drawLED(img, leftLEDs+5*ledPitch, row(1), s.Control&HLT != 0, idxRed)
+	drawLabel(img, rightLabels, row(1), "PC")
+	drawLEDs(img, rightLEDs, row(1), uint(s.PC), 4, idxGreen)
+
+	drawLabel(img, 8, row(2), "MAR")
+	drawLEDs(img, leftLEDs, row(2), uint(s.MAR), 4, idxYellow)
+	drawLabel(img, rightLabels, row(2), "A")
+	drawLEDs(img, rightLEDs, row(2), uint(s.A), 8, idxRed)
+
+	drawLabel(img, 8, row(3), "RAM")
+	drawLEDs(img, leftLEDs, row(3), uint(s.MEM[s.MAR&0x0f]), 8, idxRed)
+	drawLabel(img, rightLabels, row(3), "ALU")
+	drawLEDs(img, rightLEDs, row(3), uint(s.ALU), 8, idxRed)
+	drawLabel(img, rightLEDs+8*ledPitch+4, row(3), "CF")
+	drawLED(img, rightLEDs+10*ledPitch, row(3), s.CF, idxGreen)
+	drawLabel(img, rightLEDs+11*ledPitch, row(3), "ZF")
+	drawLED(img, rightLEDs+13*ledPitch, row(3), s.ZF, idxGreen)
+
+	drawLabel(img, 8, row(4), "IR")
+	drawLEDs(img, leftLEDs, row(4), uint(s.IR>>4), 4, idxBlue)
+	drawLEDs(img, leftLEDs+4*ledPitch, row(4), uint(s.IR), 4, idxYellow)
+	drawLabel(img, rightLabels, row(4), "B")
+	drawLEDs(img, rightLEDs, row(4), uint(s.B), 8, idxRed)
+
+	drawLabel(img, 8, row(5), "STEP")
+	drawLEDs(img, leftLEDs, row(5), 1<<maxStep>>s.Step, Steps, idxGreen)
+	drawLabel(img, rightLabels, row(5), "OUT")
+	drawDisplay(img, rightLEDs-ledRadius, row(5)-ledRadius, s.Out)
+
+	for i := 0; i < len(controlNames); i++ {
+		x := 8 + ledRadius + i*ctrlPitch
+		drawLabel(img, x-ledRadius, row(7), controlNames[i])
+		drawLED(img, x, row(8), s.Control&(BO>>uint(i)) != 0, idxBlue)
+	}
+	return img
+}
+
+// drawLED draws a single LED centered at x, y.
+func drawLED(img *image.Paletted, x, y int, on bool, idx uint8) {
+	if !on {
+		idx++
+	}
+	for dy := -ledRadius; dy <= ledRadius; dy++ {
+		for dx := -ledRadius; dx <= ledRadius; dx++ {
+			if dx*dx+dy*dy <= ledRadius*ledRadius {
+				img.SetColorIndex(x+dx, y+dy, idx)
+			}
+		}
+	}
+}
+
+// drawLEDs draws the n least significant bits of v as a row of LEDs starting
+// at x, most significant bit first.
+func drawLEDs(img *image.Paletted, x, y int, v uint, n int, idx uint8) {
+	for i := 0; i < n; i++ {
+		drawLED(img, x+ledRadius+i*ledPitch, y, v&(1<<uint(n-1-i)) != 0, idx)
+	}
+}
+
+// fontGlyphs is a 3x5 pixel font for the labels of the frames, each glyph as
+// five rows of three pixels.
+var fontGlyphs = map[rune][5]string{
+	'A': {"###", "#.#", "###", "#.#", "#.#"},
+	'B': {"##.", "#.#", "##.", "#.#", "##."},
+	'C': {"###", "#..", "#..", "#..", "###"},
+	'E': {"###", "#..", "##.", "#..", "###"},
+	'F': {"###", "#..", "##.", "#..", "#.."},
+	'H': {"#.#", "#.#", "###", "#.#", "#.#"},
+	'I': {"###", ".#.", ".#.", ".#.", "###"},
+	'J': {"..#", "..#", "..#", "#.#", "###"},
+	'K': {"#.#", "#.#", "##.", "#.#", "#.#"},
+	'L': {"#..", "#..", "#..", "#..", "###"},
+	'M': {"#.#", "###", "###", "#.#", "#.#"},
+	'O': {"###", "#.#", "#.#", "#.#", "###"},
+	'P': {"###", "#.#", "###", "#..", "#.."},
+	'R': {"##.", "#.#", "##.", "#.#", "#.#"},
+	'S': {"###", "#..", "###", "..#", "###"},
+	'T': {"###", ".#.", ".#.", ".#.", ".#."},
+	'U': {"#.#", "#.#", "#.#", "#.#", "###"},
+	'Z': {"###", "..#", ".#.", "#..", "###"},
+}
+
+// drawLabel draws text at twice the font size, its left edge at x and
+// vertically centered at y. Characters missing in the font are left blank.
+func drawLabel(img *image.Paletted, x, y int, text string) {
+	for _, r := range text {
+		for gy, ln := range fontGlyphs[r] {
+			for gx, c := range ln {
+				if c == '#' {
+					px, py := x+2*gx, y-5+2*gy
+					img.SetColorIndex(px, py, frameLabel)
+					img.SetColorIndex(px+1, py, frameLabel)
+					img.SetColorIndex(px, py+1, frameLabel)
+					img.SetColorIndex(px+1, py+1, frameLabel)
+				}
+			}
+		}
+		x += 8
+	}
+}
+
+// segments of the digits 0-9 of a seven segment display, bit 0 is segment a
+// and bit 6 is segment g
+var digitSegments = [10]byte{0x3f, 0x06, 0x5b, 0x4f, 0x66, 0x6d, 0x7d, 0x07, 0x7f, 0x6f}
+
+// drawDisplay draws v in decimal on a three digit seven segment display with
+// its top left corner at x, y. Leading zeros are blanked.
+func drawDisplay(img *image.Paletted, x, y int, v byte) {
+	digits := [3]int{int(v) / 100, int(v) / 10 % 10, int(v) % 10}
+	for i, d := range digits {
+		var segs byte
+		if d != 0 || i == 2 || (i == 1 && digits[0] != 0) {
+			segs = digitSegments[d]
+		}
+		drawDigit(img, x+i*20, y, segs)
+	}
+}
+
+// drawDigit draws a single digit of the seven segment display, lighting the
+// segments set in segs.
+func drawDigit(img *image.Paletted, x, y int, segs byte) {
+	const w, h = 12, 14 // size of a digit, excluding the segment width
+	rects := [7]image.Rectangle{
+		image.Rect(x+2, y, x+w, y+2),         // a
+		image.Rect(x+w, y+2, x+w+2, y+h),     // b
+		image.Rect(x+w, y+h+2, x+w+2, y+2*h), // c
+		image.Rect(x+2, y+2*h, x+w, y+2*h+2), // d
+		image.Rect(x, y+h+2, x+2, y+2*h),     // e
+		image.Rect(x, y+2, x+2, y+h),         // f
+		image.Rect(x+2, y+h, x+w, y+h+2),     // g
+	}
+	for i, r := range rects {
+		idx := uint8(idxRed + 1)
+		if segs&(1<<uint(i)) != 0 {
+			idx = idxRed
+		}
+		for py := r.Min.Y; py < r.Max.Y; py++ {
+			for px := r.Min.X; px < r.Max.X; px++ {
+				img.SetColorIndex(px, py, idx)
+			}
+		}
+	}
+}
+
+// Recorder collects frames of a running cpu into an animated GIF.
+type Recorder struct {
+	// Delay is the time each frame is shown in 100ths of a second
+	Delay int
+
+	gif gif.GIF
+}
+
+// NewRecorder creates a new recorder showing each frame for delay 100ths of a
+// second.
+func NewRecorder(delay int) *Recorder {
+	return &Recorder{Delay: delay}
+}
+
+// Add draws the state as the next frame of the animation.
+func (r *Recorder) Add(s State) {
+	r.gif.Image = append(r.gif.Image, Frame(s))
+	r.gif.Delay = append(r.gif.Delay, r.Delay)
+}
+
+// Len returns the number of frames recorded.
+func (r *Recorder) Len() int {
+	return len(r.gif.Image)
+}
+
+// Encode writes the recorded frames to w as an animated GIF, looping forever.
+func (r *Recorder) Encode(w io.Writer) error {
+	return gif.EncodeAll(w, &r.gif)
+}
+
+// Record runs the cpu until it halts or maxCycles full clock cycles have been
+// executed, and records a frame after each clock cycle. A maxCycles of zero
+// means no limit.
+func (r *Recorder) Record(cpu *BBCpu, maxCycles uint64) {
+	start := cpu.Cycles
+	r.Add(cpu.State())
+	for !cpu.CL.HLT && (maxCycles == 0 || cpu.Cycles-start < maxCycles) {
+		cpu.Step()
+		r.Add(cpu.State())
+	}
+}
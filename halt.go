@@ -0,0 +1,145 @@
+package eatersim
+
+import (
+	"context"
+	"fmt"
+)
+
+// RunOptions are the conditions RunContext stops at besides a halt.
+type RunOptions struct {
+	// MaxCycles is the number of full clock cycles to execute at most, 0 for
+	// no limit
+	MaxCycles uint64
+
+	// CheckInvariants stops at the first broken machine invariant, see
+	// CheckInvariants
+	CheckInvariants bool
+
+	// DetectLoops stops once the cpu comes back to the state of an earlier
+	// instruction, see LoopDetector
+	DetectLoops bool
+}
+
+// Halt tells why RunContext stopped.
+type Halt struct {
+	// Reason is why the cpu stopped
+	Reason StopReason
+
+	// Addr is the address of the next instruction
+	Addr byte
+
+	// Err holds the details of the reason: the *Violation for StopViolation,
+	// the *WriteFault for StopFault and the error of the context for
+	// StopCancelled
+	Err error
+}
+
+// Implements the Stringer-interface
+func (h Halt) String() string {
+	switch h.Reason {
+	case StopHalt, StopExternal:
+		return h.Reason.String()
+	case StopViolation, StopFault:
+		return h.Err.Error()
+	}
+	return fmt.Sprintf("%v at $%x", h.Reason, h.Addr)
+}
+
+// RunContext executes the logic of the breadboard cpu like Run, but stops as
+// well when ctx is done or a condition of opts holds, and returns why. It
+// hooks into OnViolation and the OnFault of the memory while it runs.
+func (c *BBCpu) RunContext(ctx context.Context, opts RunOptions) Halt {
+	c.warnStale()
+	c.setRunning(true)
+	defer c.setRunning(false)
+
+	var halt *Halt
+	stop := func(r StopReason, err error) {
+		if halt == nil {
+			halt = &Halt{Reason: r, Err: err}
+		}
+	}
+	onFault := c.RAM.OnFault
+	c.RAM.OnFault = func(f *WriteFault) {
+		stop(StopFault, f)
+		if onFault != nil {
+			onFault(f)
+		}
+	}
+	defer func() { c.RAM.OnFault = onFault }()
+	if opts.CheckInvariants {
+		onViolation := c.OnViolation
+		c.OnViolation = func(v *Violation) {
+			stop(StopViolation, v)
+			if onViolation != nil {
+				onViolation(v)
+			}
+		}
+		defer func() { c.OnViolation = onViolation }()
+	}
+
+	var loops LoopDetector
+	start := c.Cycles
+	for !c.Halted() && halt == nil {
+		if err := ctx.Err(); err != nil {
+			stop(StopCancelled, err)
+			break
+		}
+		if opts.MaxCycles > 0 && c.Cycles-start >= opts.MaxCycles {
+			stop(StopCycleLimit, nil)
+			break
+		}
+		c.checkPause()
+		c.Exec()
+		if opts.DetectLoops && c.AtBoundary() && loops.Check(c.State()) {
+			stop(StopLoop, nil)
+		}
+	}
+	if halt == nil {
+		halt = &Halt{Reason: c.haltReason()}
+	}
+	halt.Addr = c.PC.CNT
+	return *halt
+}
+
+// haltReason returns why a halted cpu stopped.
+func (c *BBCpu) haltReason() StopReason {
+	if c.CL.HLT {
+		return StopHalt
+	}
+	return StopExternal
+}
+
+// LoopDetector finds out whether a cpu without outside input runs forever,
+// by looking for a state it came back to. As the cpu is deterministic, it
+// then repeats the states in between without end. The detector keeps a
+// single state and finds every loop within twice its length after it was
+// entered, using Brent's algorithm.
+type LoopDetector struct {
+	saved       State
+	valid       bool
+	power, step int
+}
+
+// Check feeds the state of the cpu after the next instruction to the detector
+// and returns true if the cpu is in a loop. The states must be taken at the
+// same point of each instruction.
+func (d *LoopDetector) Check(s State) bool {
+	// the time passes in a loop as well
+	s.Cycles, s.Time = 0, 0
+	if d.valid && s == d.saved {
+		return true
+	}
+	d.step++
+	if !d.valid || d.step == d.power {
+		d.saved, d.valid = s, true
+		d.power, d.step = 2*d.power+1, 0
+	}
+	return false
+}
+
+// Reset makes the detector forget the states fed to it, e.g. after the
+// memory was changed from outside the cpu.
+func (d *LoopDetector) Reset() {
+	*d = LoopDetector{}
+}
@@ -0,0 +1,68 @@
+package eatersim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxStep is the highest micro instruction step of the control logic.
+const maxStep = 4
+
+// Violation is a broken machine invariant, detected by CheckInvariants.
+type Violation struct {
+	// Msg describes the broken invariant
+	Msg string
+
+	// State is the state of the cpu when the invariant was broken
+	State State
+}
+
+// Implements the error-interface
+func (v *Violation) Error() string {
+	clk := "low"
+	if v.State.CLK {
+		clk = "high"
+	}
+	return fmt.Sprintf("cycle %d, clock %s, step %d, IR $%02x, control %s: %s",
+		v.State.Cycles, clk, v.State.Step, v.State.IR, v.State.Control, v.Msg)
+}
+
+// CheckInvariants checks properties which hold for every correctly wired cpu
+// and microcode after every half step: the program counter and the memory
+// address register are within the memory, at most one board drives the bus
+// and the micro instruction step is within the steps of an instruction.
+// Returns the first broken invariant as a *Violation, or nil.
+//
+// To check the invariants while the cpu runs, set OnViolation.
+func (c *BBCpu) CheckInvariants() error {
+	violation := func(format string, a ...interface{}) error {
+		return &Violation{Msg: fmt.Sprintf(format, a...), State: c.State()}
+	}
+
+	if c.PC.CNT > 0x0f {
+		return violation("program counter $%02x outside memory", c.PC.CNT)
+	}
+	if c.MAR.BUF > 0x0f {
+		return violation("memory address register $%02x outside memory", c.MAR.BUF)
+	}
+	if c.CL.Cnt > maxStep {
+		return violation("micro instruction step %d beyond last step %d", c.CL.Cnt, maxStep)
+	}
+
+	var drivers []string
+	for _, d := range []struct {
+		name string
+		on   *bool
+	}{
+		{"PC", c.PC.CO}, {"RAM", c.RAM.RO}, {"IR", c.IR.EO},
+		{"A", c.Areg.EO}, {"B", c.Breg.EO}, {"OUT", c.Oreg.EO}, {"ALU", c.ALU.EO},
+	} {
+		if ptbool(d.on) {
+			drivers = append(drivers, d.name)
+		}
+	}
+	if len(drivers) > 1 {
+		return violation("%d boards drive the bus: %s", len(drivers), strings.Join(drivers, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+//go:build go1.23
+
+package eatersim
+
+import (
+	"context"
+	"iter"
+)
+
+// States executes the cpu full clock cycle by full clock cycle and yields its
+// state after each cycle, until the cpu halts, ctx is done or the loop over
+// the states is left:
+//
+//	for s := range cpu.States(ctx) {
+//		if s.Out == 144 {
+//			break
+//		}
+//	}
+//
+// The state of the cycle in which the cpu halts is yielded last. States
+// requires Go 1.23 or later.
+func (c *BBCpu) States(ctx context.Context) iter.Seq[State] {
+	return func(yield func(State) bool) {
+		for !c.Halted() && ctx.Err() == nil {
+			c.Step()
+			if !yield(c.State()) {
+				return
+			}
+		}
+	}
+}
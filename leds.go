@@ -0,0 +1,124 @@
+package eatersim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RenderMode selects how BBCpu.String renders the cpu.
+type RenderMode int
+
+// The render modes of BBCpu.String.
+const (
+	// RenderText dumps the buffers and active signals of each board as text
+	RenderText RenderMode = iota
+
+	// RenderLEDs draws each board as rows of colored LEDs using ANSI escape
+	// sequences, approximating the look of the breadboard build
+	RenderLEDs
+)
+
+// LEDColor is the ANSI escape sequence selecting the color of a lit LED.
+type LEDColor string
+
+// The LED colors used on the boards.
+const (
+	LEDRed    LEDColor = "\x1b[1;31m"
+	LEDGreen  LEDColor = "\x1b[1;32m"
+	LEDYellow LEDColor = "\x1b[1;33m"
+	LEDBlue   LEDColor = "\x1b[1;34m"
+)
+
+const (
+	ledOff    = "\x1b[2;37m"
+	ansiReset = "\x1b[0m"
+)
+
+// LED renders a single LED, lit in color c when on is true.
+func LED(on bool, c LEDColor) string {
+	if on {
+		return string(c) + "●" + ansiReset
+	}
+	return ledOff + "○" + ansiReset
+}
+
+// LEDs renders the n least significant bits of v as a row of LEDs lit in color
+// c, most significant bit first.
+func LEDs(v uint, n int, c LEDColor) string {
+	var b strings.Builder
+	for i := n - 1; i >= 0; i-- {
+		b.WriteString(LED(v&(1<<uint(i)) != 0, c))
+	}
+	return b.String()
+}
+
+// LEDs renders the clock and halt LEDs of the clock board.
+func (c *Clk) LEDs() string {
+	return LED(c.CLK, LEDBlue) + " " + LED(ptbool(c.HLT), LEDRed)
+}
+
+// LEDs renders the buffer of the register as a row of LEDs.
+func (r *Reg) LEDs() string {
+	return LEDs(uint(r.BUF), 8, LEDRed)
+}
+
+// LEDs renders the buffer of the instruction register as a row of LEDs, the
+// instruction in blue and the operand in yellow.
+func (r *Ireg) LEDs() string {
+	return LEDs(uint(r.BUF>>4), 4, LEDBlue) + LEDs(uint(r.BUF), 4, LEDYellow)
+}
+
+// LEDs renders the buffer of the register as a row of LEDs.
+func (r *Reg4) LEDs() string {
+	return LEDs(uint(r.BUF), 4, LEDYellow)
+}
+
+// LEDs renders the address and the content of the memory at the address as
+// rows of LEDs.
+func (m *Mem) LEDs() string {
+	addr := m.Address()
+	return LEDs(uint(addr), 4, LEDYellow) + " " + LEDs(uint(m.MEM[addr]), 8, LEDRed)
+}
+
+// LEDs renders the buffer and the carry and zero flags of the ALU as a row of
+// LEDs.
+func (a *Alu) LEDs() string {
+	return LEDs(uint(a.BUF), 8, LEDRed) + " " + LED(a.CF, LEDGreen) + LED(a.ZF, LEDGreen)
+}
+
+// LEDs renders the counter value as a row of LEDs.
+func (c *Ctr) LEDs() string {
+	return LEDs(uint(c.CNT), 4, LEDGreen)
+}
+
+// LEDs renders the micro instruction step and the control word as rows of
+// LEDs, the control word below the names of its signals.
+func (c *Ctrl) LEDs() string {
+	var names, lights strings.Builder
+	w := c.Word()
+	for i := len(controlNames) - 1; i >= 0; i-- {
+		sig := ControlWord(1) << uint(i)
+		fmt.Fprintf(&names, "%-4s", sig)
+		lights.WriteString(LED(w&sig != 0, LEDBlue) + "   ")
+	}
+	s := LEDs(0x10>>c.Cnt, 5, LEDGreen)
+	s += "\n" + strings.TrimRight(names.String(), " ")
+	s += "\n" + strings.TrimRight(lights.String(), " ")
+	return s
+}
+
+// LEDs renders all the boards as rows of LEDs.
+func (c *BBCpu) LEDs() string {
+	s := fmt.Sprintf("bus:  %s\n", LEDs(uint(c.BUS), 8, LEDYellow))
+	s += fmt.Sprintf("clk:  %s\n", c.CLK.LEDs())
+	s += fmt.Sprintf("pc:   %s\n", c.PC.LEDs())
+	s += fmt.Sprintf("mar:  %s\n", c.MAR.LEDs())
+	s += fmt.Sprintf("ram:  %s\n", c.RAM.LEDs())
+	s += fmt.Sprintf("ir:   %s\n", c.IR.LEDs())
+	s += fmt.Sprintf("areg: %s\n", c.Areg.LEDs())
+	s += fmt.Sprintf("alu:  %s\n", c.ALU.LEDs())
+	s += fmt.Sprintf("breg: %s\n", c.Breg.LEDs())
+	s += fmt.Sprintf("oreg: %s\n", c.Oreg.LEDs())
+	s += "cl:   " + strings.Replace(c.CL.LEDs(), "\n", "\n      ", -1)
+	return s
+}
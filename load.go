@@ -0,0 +1,56 @@
+package eatersim
+
+import "fmt"
+
+// Load writes image to the memory, starting at address 0, like WithMemoryImage.
+// The cpu is reset afterwards if ResetOnLoad is set. Otherwise the cpu carries
+// on from where a prior run left it, and Run warns about it, see Stale.
+func (c *BBCpu) Load(image []byte) error {
+	if len(image) > len(c.RAM.MEM) {
+		return fmt.Errorf("memory image of %d bytes exceeds the memory of %d bytes", len(image), len(c.RAM.MEM))
+	}
+	copy(c.RAM.MEM[:], image)
+	if c.ResetOnLoad {
+		c.Reset()
+		return nil
+	}
+	c.loaded = true
+	return nil
+}
+
+// WithResetOnLoad sets ResetOnLoad, so that loading a program resets the cpu.
+// Memory images loaded by options given after WithResetOnLoad reset the cpu
+// too.
+func WithResetOnLoad() Option {
+	return func(c *BBCpu) error {
+		c.ResetOnLoad = true
+		return nil
+	}
+}
+
+// Stale tells why the cpu is not ready to run a program loaded with Load, or
+// returns "" if it is: a program was loaded without a reset after a prior run
+// left the program counter or the instruction register behind, or halted the
+// cpu.
+func (c *BBCpu) Stale() string {
+	switch {
+	case !c.loaded:
+		return ""
+	case c.CL.HLT:
+		return "program loaded into a halted cpu without a reset"
+	case c.PC.CNT != c.PC.Vector || c.IR.BUF != 0:
+		return fmt.Sprintf("program loaded without a reset, the cpu continues at PC=$%x IR=$%02x", c.PC.CNT, c.IR.BUF)
+	}
+	return ""
+}
+
+// warnStale calls OnStale if the cpu is stale, once per load.
+func (c *BBCpu) warnStale() {
+	if c.OnStale == nil {
+		return
+	}
+	if reason := c.Stale(); reason != "" {
+		c.OnStale(reason)
+	}
+	c.loaded = false
+}
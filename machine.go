@@ -0,0 +1,166 @@
+package eatersim
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oj-mik/eatersim/assembler"
+)
+
+// Machine is a variant of the breadboard cpu: its microcode, the behavior of
+// its flags and the language level of its programs, so that all the tools
+// can be pointed at the same machine. It is one of the presets returned by
+// MachinePreset, or read from a configuration file by LoadMachine.
+type Machine struct {
+	// Name of the machine
+	Name string
+
+	// Microcode executed by the control logic, nil for DefaultMicrocode
+	Microcode *Microcode
+
+	// SubCarry and ZeroFrom select the behavior of the flags, see Alu
+	SubCarry CarryPolarity
+	ZeroFrom ZeroSource
+
+	// Level is the language level programs are assembled for
+	Level assembler.Level
+}
+
+// machinePresets are the machines known by name. The first is the default.
+var machinePresets = []func() Machine{
+	func() Machine {
+		return Machine{Name: "default", Level: assembler.LevelExtended}
+	},
+	func() Machine {
+		m := ClassicMicrocode()
+		return Machine{Name: "stock", Microcode: &m, SubCarry: CarryNoBorrow, ZeroFrom: ZeroFromBus, Level: assembler.LevelClassic}
+	},
+}
+
+// MachinePresets returns the names of the machine presets, default for the
+// machine created by New without options, stock for Ben's build with its
+// microcode and flags.
+func MachinePresets() []string {
+	names := make([]string, len(machinePresets))
+	for i, preset := range machinePresets {
+		names[i] = preset().Name
+	}
+	return names
+}
+
+// MachinePreset returns the machine preset called name. Names are not case
+// sensitive.
+func MachinePreset(name string) (Machine, error) {
+	for _, preset := range machinePresets {
+		if m := preset(); strings.EqualFold(m.Name, name) {
+			return m, nil
+		}
+	}
+	return Machine{}, fmt.Errorf("unknown machine %s, expecting %s", name, strings.Join(MachinePresets(), " or "))
+}
+
+// LoadMachine reads the machine described by the configuration file at path.
+// Each line holds a key and a value separated by a colon, as in YAML, text
+// after '#' is a comment:
+//
+//	name: mybuild         # name of the machine, the file name if omitted
+//	base: stock           # preset the machine starts from, default if omitted
+//	microcode: fixed.txt  # default, classic or a microcode file, relative to
+//	                      # the configuration file
+//	sub-carry: no-borrow  # borrow or no-borrow
+//	zero-from: bus        # alu or bus
+//	lang: classic         # classic or extended
+//
+// The base key must come first if given. Language levels needing more memory
+// than the 16 bytes of the cpu are refused.
+func LoadMachine(path string) (Machine, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Machine{}, err
+	}
+	defer f.Close()
+
+	m, _ := MachinePreset("default")
+	m.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		ln := sc.Text()
+		if i := strings.IndexByte(ln, '#'); i >= 0 {
+			ln = ln[:i]
+		}
+		if strings.TrimSpace(ln) == "" {
+			continue
+		}
+		kv := strings.SplitN(ln, ":", 2)
+		if len(kv) != 2 {
+			return Machine{}, fmt.Errorf("%s: line %d: expecting 'key: value'", path, n)
+		}
+		key, val := strings.TrimSpace(kv[0]), strings.Trim(strings.TrimSpace(kv[1]), `"'`)
+
+		switch strings.ToLower(key) {
+		case "name":
+			m.Name = val
+		case "base":
+			name := m.Name
+			m, err = MachinePreset(val)
+			m.Name = name
+		case "microcode":
+			m.Microcode, err = machineMicrocode(val, filepath.Dir(path))
+		case "sub-carry":
+			m.SubCarry, err = ParseCarryPolarity(val)
+		case "zero-from":
+			m.ZeroFrom, err = ParseZeroSource(val)
+		case "lang":
+			m.Level, err = assembler.ParseLevel(val)
+			if size := len(Mem{}.MEM); err == nil && m.Level.MemorySize() > size {
+				err = fmt.Errorf("language level %s needs %d bytes of memory, the cpu has %d", m.Level, m.Level.MemorySize(), size)
+			}
+		default:
+			err = fmt.Errorf("unknown key %s", key)
+		}
+		if err != nil {
+			return Machine{}, fmt.Errorf("%s: line %d: %v", path, n, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return Machine{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return m, nil
+}
+
+// machineMicrocode returns the microcode called name in a configuration file,
+// default, classic or a file relative to the directory dir.
+func machineMicrocode(name, dir string) (*Microcode, error) {
+	switch name {
+	case "default":
+		return nil, nil
+	case "classic":
+		m := ClassicMicrocode()
+		return &m, nil
+	}
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(dir, name)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m, err := ParseMicrocode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return &m, nil
+}
+
+// Options returns the options creating the cpu of the machine with New.
+func (m Machine) Options() []Option {
+	opts := []Option{WithSubCarry(m.SubCarry), WithZeroSource(m.ZeroFrom)}
+	if m.Microcode != nil {
+		opts = append(opts, WithMicrocode(*m.Microcode))
+	}
+	return opts
+}
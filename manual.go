@@ -0,0 +1,60 @@
+package eatersim
+
+import "errors"
+
+var errNotManual = errors.New("the control logic is engaged, see SetManual")
+
+// NewManualBBCpu returns a cpu with all the boards wired up, but without the
+// control logic, like the intermediate builds of Ben's series before the
+// control logic board is added. The control lines are inactive until driven
+// with SetControl, or by setting CL.Override directly, and act on the boards
+// with the next clock pulse. The control logic can be engaged later on with
+// SetManual(false).
+func NewManualBBCpu() *BBCpu {
+	c := NewBBCpu()
+	c.SetManual(true)
+	return c
+}
+
+// WithManualControl disengages the control logic, see NewManualBBCpu.
+func WithManualControl() Option {
+	return func(c *BBCpu) error {
+		c.SetManual(true)
+		return nil
+	}
+}
+
+// SetManual disengages the control logic if on, so that the control lines are
+// driven by hand with SetControl, like operating the machine before the
+// control logic board exists in Ben's series. The lines start out inactive.
+// SetManual(false) engages the control logic again, which continues with the
+// current micro instruction step.
+func (c *BBCpu) SetManual(on bool) {
+	c.CL.Manual = on
+	c.CL.Override = 0
+	if on {
+		c.CL.setWord(0)
+	}
+}
+
+// Manual tells whether the control logic is disengaged, see SetManual.
+func (c *BBCpu) Manual() bool {
+	return c.CL.Manual
+}
+
+// SetControl drives the control lines with w while the control logic is
+// disengaged, until the next call. The boards act on the lines with the next
+// clock pulse. Returns the conflict of w and leaves the lines unchanged if w
+// makes several boards write to the bus or boards read an undriven bus, see
+// ControlWord.Conflict.
+func (c *BBCpu) SetControl(w ControlWord) error {
+	if !c.CL.Manual {
+		return errNotManual
+	}
+	if err := w.Conflict(); err != nil {
+		return err
+	}
+	c.CL.Override = w
+	c.CL.setWord(w)
+	return nil
+}
@@ -0,0 +1,197 @@
+package eatersim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MemoryPageSize is the number of cells of a page of a MemoryView. The 16
+// bytes of the memory of the breadboard build are a single page, larger
+// memories are shown page by page.
+const MemoryPageSize = 16
+
+// MemoryCell is a cell of the memory as shown by a MemoryView.
+type MemoryCell struct {
+	// Addr is the address of the cell
+	Addr int `json:"addr"`
+
+	// Value is the content of the cell
+	Value byte `json:"value"`
+
+	// Selected marks the cell addressed by the memory address register
+	Selected bool `json:"selected"`
+
+	// Written marks a cell written recently, see WriteHistory
+	Written bool `json:"written"`
+}
+
+// MemoryView is a page of the memory prepared for display, so that the text
+// user interfaces and the web frontends show the memory alike. It encodes to
+// JSON for the web frontends, the text user interfaces render it with Text.
+type MemoryView struct {
+	// Page is the number of the page shown, Pages the number of pages of the
+	// memory
+	Page  int `json:"page"`
+	Pages int `json:"pages"`
+
+	// Cells are the cells of the page in the order of their addresses
+	Cells []MemoryCell `json:"cells"`
+}
+
+// NewMemoryView returns the view of the page of mem, with the cell at the
+// address selected highlighted, and the cells written recently according to
+// h. h may be nil. Pages out of range are clamped to the first or last page.
+func NewMemoryView(mem []byte, page, selected int, h *WriteHistory) MemoryView {
+	pages := (len(mem) + MemoryPageSize - 1) / MemoryPageSize
+	if page >= pages {
+		page = pages - 1
+	}
+	if page < 0 {
+		page = 0
+	}
+	v := MemoryView{Page: page, Pages: pages}
+	for addr := page * MemoryPageSize; addr < len(mem) && addr < (page+1)*MemoryPageSize; addr++ {
+		v.Cells = append(v.Cells, MemoryCell{
+			Addr:     addr,
+			Value:    mem[addr],
+			Selected: addr == selected,
+			Written:  h.Written(addr),
+		})
+	}
+	return v
+}
+
+// MemoryView returns the view of the memory of the cpu, with the cell
+// addressed by the memory address register selected and the recent writes
+// recorded by Writes highlighted.
+func (c *BBCpu) MemoryView() MemoryView {
+	return NewMemoryView(c.RAM.MEM[:], 0, int(c.RAM.Address()), c.Writes)
+}
+
+// MemoryFormat selects how MemoryView.Text renders the cells.
+type MemoryFormat int
+
+// The formats of MemoryView.Text.
+const (
+	// MemoryHex renders eight cells per line in hexadecimal
+	MemoryHex MemoryFormat = iota
+
+	// MemoryBinary renders a cell per line in binary, split into the
+	// instruction and the operand nibble
+	MemoryBinary
+)
+
+// Text renders the view as lines of text in the format f, each line starting
+// with the address of its first cell. With ansi set the selected cell is shown
+// in reverse video and the written cells in yellow. Otherwise the selected
+// cell is marked by a '>' in front of it and the written cells by a '*' after
+// them.
+func (v MemoryView) Text(f MemoryFormat, ansi bool) string {
+	perLine := 8
+	if f == MemoryBinary {
+		perLine = 1
+	}
+	var b strings.Builder
+	for i, cell := range v.Cells {
+		if i%perLine == 0 {
+			if i > 0 {
+				b.WriteByte('\n')
+			}
+			fmt.Fprintf(&b, "$%02x:", cell.Addr)
+		}
+		value := fmt.Sprintf("%02x", cell.Value)
+		if f == MemoryBinary {
+			value = fmt.Sprintf("%04b %04b", cell.Value>>4, cell.Value&0x0f)
+		}
+		switch {
+		case ansi:
+			b.WriteByte(' ')
+			if cell.Written {
+				b.WriteString(string(LEDYellow))
+			}
+			if cell.Selected {
+				b.WriteString("\x1b[7m")
+			}
+			b.WriteString(value)
+			if cell.Written || cell.Selected {
+				b.WriteString(ansiReset)
+			}
+			b.WriteByte(' ')
+		default:
+			if cell.Selected {
+				b.WriteByte('>')
+			} else {
+				b.WriteByte(' ')
+			}
+			b.WriteString(value)
+			if cell.Written {
+				b.WriteByte('*')
+			} else {
+				b.WriteByte(' ')
+			}
+		}
+	}
+	lines := strings.Split(b.String(), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WriteHistory remembers the recent writes to the memory of a cpu, for
+// MemoryView to highlight them. It learns of the writes from the states of the
+// cpu fed to Observe after every half step, which the cpu does itself for the
+// history in its Writes field.
+type WriteHistory struct {
+	// Recent is the number of full clock cycles a write counts as recent
+	Recent uint64
+
+	// written holds the cycle of the last write to each address, plus one
+	written map[int]uint64
+	prev    State
+	now     uint64
+	seen    bool
+}
+
+// NewWriteHistory creates a write history counting the writes of the last
+// recent full clock cycles as recent.
+func NewWriteHistory(recent uint64) *WriteHistory {
+	return &WriteHistory{Recent: recent, written: make(map[int]uint64)}
+}
+
+// Observe records the writes between the state fed before and s. A write is
+// RI on a rising clock edge, or any change of the memory, e.g. by the program
+// button or a debugger.
+func (h *WriteHistory) Observe(s State) {
+	if h.written == nil {
+		h.written = make(map[int]uint64)
+	}
+	h.now = s.Cycles
+	if h.seen {
+		if s.CLK && !h.prev.CLK && s.Control&RI != 0 {
+			h.written[int(s.MAR&0x0f)] = s.Cycles + 1
+		}
+		for addr := range s.MEM {
+			if s.MEM[addr] != h.prev.MEM[addr] {
+				h.written[addr] = s.Cycles + 1
+			}
+		}
+	}
+	h.prev, h.seen = s, true
+}
+
+// Written returns true if addr was written within the last Recent full clock
+// cycles. A nil history has no writes.
+func (h *WriteHistory) Written(addr int) bool {
+	if h == nil {
+		return false
+	}
+	at, ok := h.written[addr]
+	return ok && h.now+1-at < h.Recent
+}
+
+// Clear forgets all writes, e.g. after loading a program.
+func (h *WriteHistory) Clear() {
+	h.written = make(map[int]uint64)
+	h.seen = false
+}
@@ -0,0 +1,436 @@
+package eatersim
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/oj-mik/eatersim/assembler"
+)
+
+// Microcode is a microcode table, holding the control word of every micro
+// instruction step of every instruction, indexed by the flags, the opcode and
+// the step. Like the EEPROMs of the breadboard build, whose address lines
+// include the carry and the zero flag, it has room for 8 steps, of which the
+// control logic executes the first Steps, and conditional instructions differ
+// by the state of the flags.
+type Microcode [4][Opcodes][8]ControlWord
+
+// Opcodes is the number of opcodes the microcode decodes.
+const Opcodes = 16
+
+// The flags indexing the microcode, FlagCF|FlagZF with both flags set.
+const (
+	FlagCF = 1 << iota // carry flag set
+	FlagZF             // zero flag set
+)
+
+// flagNames are the names of the flags in the order of their bits.
+var flagNames = [...]string{"CF", "ZF"}
+
+// flagsString returns the names of the flags set in flags separated by
+// spaces, e.g. "CF ZF".
+func flagsString(flags int) string {
+	var s []string
+	for i, name := range flagNames {
+		if flags&(1<<uint(i)) != 0 {
+			s = append(s, name)
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+// Set sets the steps of the opcode op for every state of the flags having at
+// least the flags in flags set, e.g. 0 for every state or FlagCF for the
+// states with the carry flag set. Steps not given have no control signals.
+func (m *Microcode) Set(op, flags int, steps ...ControlWord) {
+	for f := range m {
+		if f&flags == flags {
+			m[f][op] = [8]ControlWord{}
+			copy(m[f][op][:], steps)
+		}
+	}
+}
+
+// Steps is the number of micro instruction steps the control logic executes
+// per instruction.
+const Steps = maxStep + 1
+
+// fetch holds the control words of the fetch steps shared by all instructions.
+var fetch = [2]ControlWord{CO | MI, RO | II | CE}
+
+// defaultMicrocode is the microcode of a control logic board without Table.
+var defaultMicrocode = DefaultMicrocode()
+
+// DefaultMicrocode returns the microcode of the control logic board. The
+// conditional jumps JC and JZ jump only with the carry or the zero flag set,
+// and execute just the fetch steps otherwise. SWP exchanges the registers
+// through the alu without a temporary register and leaves the flags
+// unchanged, LDX and ADX fetch the byte following the instruction and make the
+// program counter skip it.
+func DefaultMicrocode() Microcode {
+	var m Microcode
+	set := func(op, flags int, steps ...ControlWord) {
+		m.Set(op, flags, append([]ControlWord{fetch[0], fetch[1]}, steps...)...)
+	}
+	for op := 0; op < Opcodes; op++ {
+		set(op, 0)
+	}
+	set(0x1, 0, IO|MI, RO|AI)              // lda
+	set(0x2, 0, IO|MI, RO|BI, EO|AI|FI)    // add
+	set(0x3, 0, IO|MI, RO|BI, EO|AI|SU|FI) // sub
+	set(0x4, 0, IO|MI, AO|RI)              // sta
+	set(0x5, 0, IO|AI)                     // ldi
+	set(0x6, 0, IO|J)                      // jmp
+	set(0x7, FlagCF, IO|J)                 // jc
+	set(0x8, FlagZF, IO|J)                 // jz
+	set(0x9, 0, AO|BI)                     // tab
+	set(0xa, 0, BO|AI)                     // tba
+	set(0xb, 0, EO|AI, EO|SU|BI, EO|SU|AI) // swp
+	set(0xc, 0, CO|MI, RO|AI|CE)           // ldx
+	set(0xd, 0, CO|MI, RO|BI|CE, EO|AI|FI) // adx
+	set(0xe, 0, AO|OI)                     // out
+	set(0xf, 0, HLT)                       // hlt
+	return m
+}
+
+// ClassicMicrocode returns the microcode of Ben's build, which implements the
+// instructions of the classic language level only. The opcodes of the
+// extended instructions execute just the fetch steps, like NOP.
+func ClassicMicrocode() Microcode {
+	m := DefaultMicrocode()
+	for op := 0x9; op <= 0xd; op++ {
+		m.Set(op, 0, fetch[0], fetch[1])
+	}
+	return m
+}
+
+// ISA returns the instruction set the microcode implements: the opcodes
+// executing more than the fetch steps with any state of the flags, and opcode
+// 0, which is NOP and does nothing by design.
+func (m *Microcode) ISA() assembler.ISA {
+	isa := assembler.ISA(1)
+	for _, table := range m {
+		for op, steps := range table {
+			for _, w := range steps[len(fetch):Steps] {
+				if w != 0 {
+					isa |= 1 << uint(op)
+					break
+				}
+			}
+		}
+	}
+	return isa
+}
+
+// the control signals writing to and reading from the bus
+const (
+	busOutputs = CO | RO | IO | AO | BO | EO
+	busInputs  = MI | RI | II | AI | BI | OI | J
+)
+
+// Conflict returns an error if the control word makes several boards write to
+// the bus at once, or boards read the bus while no board writes to it.
+func (w ControlWord) Conflict() error {
+	if out := w & busOutputs; out&(out-1) != 0 {
+		return fmt.Errorf("several bus outputs %s", out)
+	}
+	if in := w & busInputs; in != 0 && w&busOutputs == 0 {
+		return fmt.Errorf("%s reads the bus, but no board writes to it", in)
+	}
+	return nil
+}
+
+// MicrocodeIssue is a problem found in a microcode table by Validate.
+type MicrocodeIssue struct {
+	Opcode, Step int
+
+	// Flags is the state of the flags the steps of the opcode are executed
+	// with, see FlagCF and FlagZF
+	Flags int
+
+	Msg string
+}
+
+// Implements the Stringer-interface
+func (i MicrocodeIssue) String() string {
+	if i.Flags != 0 {
+		return fmt.Sprintf("opcode $%x with %s step %d: %s", i.Opcode, flagsString(i.Flags), i.Step, i.Msg)
+	}
+	return fmt.Sprintf("opcode $%x step %d: %s", i.Opcode, i.Step, i.Msg)
+}
+
+// Validate checks the microcode for the mistakes commonly made when writing
+// control words by hand, and returns the issues found, ordered by opcode,
+// flags and step. The steps of an opcode are checked with the flags set only
+// where they differ from the steps with the flags clear. It reports
+//
+//   - steps with more than one board writing to the bus
+//   - steps reading from the bus with no board writing to it
+//   - instructions not starting with the fetch steps CO|MI and RO|II|CE
+//   - unreachable steps holding control signals, i.e. steps beyond the
+//     steps executed by the control logic or following a HLT
+func (m *Microcode) Validate() []MicrocodeIssue {
+	var issues []MicrocodeIssue
+	for flags := range m {
+		for op, steps := range m[flags] {
+			if flags == 0 || steps != m[0][op] {
+				issues = append(issues, validateSteps(op, flags, steps)...)
+			}
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		a, b := issues[i], issues[j]
+		if a.Opcode != b.Opcode {
+			return a.Opcode < b.Opcode
+		}
+		return a.Flags < b.Flags || (a.Flags == b.Flags && a.Step < b.Step)
+	})
+	return issues
+}
+
+// validateSteps returns the issues in the steps of the opcode op executed
+// with the flags, see Validate.
+func validateSteps(op, flags int, steps [8]ControlWord) []MicrocodeIssue {
+	var issues []MicrocodeIssue
+	report := func(step int, format string, a ...interface{}) {
+		issues = append(issues, MicrocodeIssue{Opcode: op, Step: step, Flags: flags, Msg: fmt.Sprintf(format, a...)})
+	}
+
+	halted := false
+	for step, w := range steps {
+		switch {
+		case w == 0:
+			continue
+		case step >= Steps:
+			report(step, "unreachable, the control logic executes %d steps", Steps)
+			continue
+		case halted:
+			report(step, "unreachable, the clock is halted in an earlier step")
+			continue
+		}
+
+		if err := w.Conflict(); err != nil {
+			report(step, "%s", err)
+		}
+		if w&HLT != 0 {
+			halted = true
+		}
+	}
+	for step, w := range fetch {
+		if steps[step] != w {
+			report(step, "fetch step is %s, expecting %s", steps[step], w)
+		}
+	}
+	return issues
+}
+
+// Implements the Stringer-interface. Lists the control words of the steps of
+// each opcode, one opcode per line. Opcodes whose steps depend on the flags
+// are followed by a line for each state of the flags where they differ from
+// the lines before, e.g. '$7 CF:', see ParseMicrocode.
+func (m *Microcode) String() string {
+	var lines []string
+	line := func(op, flags int, steps [8]ControlWord) {
+		ln := fmt.Sprintf("$%x:", op)
+		if flags != 0 {
+			ln = fmt.Sprintf("$%x %s:", op, flagsString(flags))
+		}
+		for _, w := range steps {
+			ln += fmt.Sprintf(" %-14s", w)
+		}
+		lines = append(lines, strings.TrimRight(ln, " "))
+	}
+	for op := 0; op < Opcodes; op++ {
+		// the steps set by the lines so far for each state of the flags
+		var set [len(m)][8]ControlWord
+		for flags := range m {
+			if flags != 0 && m[flags][op] == set[flags] {
+				continue
+			}
+			line(op, flags, m[flags][op])
+			for f := range set {
+				if f&flags == flags {
+					set[f] = m[flags][op]
+				}
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// InstructionTiming is the timing of a single instruction.
+type InstructionTiming struct {
+	// Cycles is the number of full clock cycles the instruction takes, up to
+	// and including the step halting the clock for an instruction halting it
+	Cycles int
+
+	// Halts is true if the instruction halts the clock
+	Halts bool
+}
+
+// Timing holds the timing of each instruction, indexed by opcode.
+type Timing [Opcodes]InstructionTiming
+
+// Timing derives the timing of each instruction from the microcode. The
+// control logic executes Steps steps per instruction, unless a step halts
+// the clock. Instructions depending on the flags take the cycles of the
+// longest of their variants, and halt only if all of them halt.
+func (m *Microcode) Timing() Timing {
+	var t Timing
+	for op := range t {
+		t[op].Halts = true
+		for flags := range m {
+			it := InstructionTiming{Cycles: Steps}
+			for step, w := range m[flags][op][:Steps] {
+				if w&HLT != 0 {
+					it = InstructionTiming{Cycles: step + 1, Halts: true}
+					break
+				}
+			}
+			if it.Cycles > t[op].Cycles {
+				t[op].Cycles = it.Cycles
+			}
+			t[op].Halts = t[op].Halts && it.Halts
+		}
+	}
+	return t
+}
+
+// Cycles returns the number of full clock cycles of the instruction in ir,
+// split 4/4 into opcode and operand as in Ben's build. Other splits are not
+// handled, see BBCpu.SetOperandBits.
+func (t *Timing) Cycles(ir byte) int {
+	return t[ir>>4].Cycles
+}
+
+// Implements the Stringer-interface. Lists the cycles of each opcode, one
+// opcode per line.
+func (t *Timing) String() string {
+	lines := make([]string, len(t))
+	for op, it := range t {
+		lines[op] = fmt.Sprintf("$%x: %d cycles", op, it.Cycles)
+		if it.Halts {
+			lines[op] += ", halts"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Microcode returns the microcode executed by the control logic, Table if set
+// or DefaultMicrocode.
+func (c *Ctrl) Microcode() Microcode {
+	if c.Table != nil {
+		return *c.Table
+	}
+	return defaultMicrocode
+}
+
+// word returns the control word of the step of the opcode op as the control
+// logic executes it with the carry flag cf and the zero flag zf. Opcodes
+// beyond the table execute the fetch steps only.
+func (m *Microcode) word(op, step byte, cf, zf bool) ControlWord {
+	if int(step) >= len(m[0][0]) {
+		return 0
+	}
+	if int(op) >= Opcodes {
+		if int(step) < len(fetch) {
+			return fetch[step]
+		}
+		return 0
+	}
+	flags := 0
+	if cf {
+		flags |= FlagCF
+	}
+	if zf {
+		flags |= FlagZF
+	}
+	return m[flags][op][step]
+}
+
+// WithMicrocode makes the control logic execute the microcode table m instead
+// of DefaultMicrocode, e.g. ClassicMicrocode or a table read by
+// ParseMicrocode.
+func WithMicrocode(m Microcode) Option {
+	return func(c *BBCpu) error {
+		c.CL.Table = &m
+		return nil
+	}
+}
+
+// ParseMicrocode reads a microcode table in the format of Microcode.String:
+// a line per opcode, the opcode followed by a colon and the control words of
+// its steps, e.g. '$1: CO|MI RO|II|CE IO|MI RO|AI'. Flags between the opcode
+// and the colon make the line set the steps for the states of the flags with
+// these flags set only, overriding the lines before, e.g. '$7 CF: CO|MI
+// RO|II|CE IO|J' for a jump taken with the carry flag set, see Microcode.Set.
+// Missing steps and opcodes have no control signals, empty lines and lines
+// starting with # are ignored.
+func ParseMicrocode(r io.Reader) (Microcode, error) {
+	var m Microcode
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		f := strings.Fields(sc.Text())
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		// the opcode and the flags up to the field ending with the colon
+		h := 0
+		for h < len(f)-1 && !strings.HasSuffix(f[h], ":") {
+			h++
+		}
+		op, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSuffix(f[0], ":"), "$"), 16, 8)
+		if err != nil || !strings.HasSuffix(f[h], ":") || op >= Opcodes {
+			return m, fmt.Errorf("line %d: invalid opcode %s, expecting $0: to $f:", n, f[0])
+		}
+		flags := 0
+		for _, name := range f[1 : h+1] {
+			name = strings.TrimSuffix(name, ":")
+			flag := parseFlag(name)
+			if flag == 0 {
+				return m, fmt.Errorf("line %d: unknown flag %s, expecting %s", n, name, strings.Join(flagNames[:], " or "))
+			}
+			flags |= flag
+		}
+		if len(f)-1-h > len(m[0][op]) {
+			return m, fmt.Errorf("line %d: %d steps, the table has room for %d", n, len(f)-1-h, len(m[0][op]))
+		}
+		steps := make([]ControlWord, len(f)-1-h)
+		for i, s := range f[h+1:] {
+			if steps[i], err = ParseControlWord(s); err != nil {
+				return m, fmt.Errorf("line %d: %s", n, err)
+			}
+		}
+		m.Set(int(op), flags, steps...)
+	}
+	return m, sc.Err()
+}
+
+// parseFlag returns the flag called name, e.g. FlagCF for "CF", or 0 for an
+// unknown name. Names are not case sensitive.
+func parseFlag(name string) int {
+	for i, n := range flagNames {
+		if strings.EqualFold(n, name) {
+			return 1 << uint(i)
+		}
+	}
+	return 0
+}
+
+// ISA returns the instruction set implemented by the microcode executed by the
+// control logic.
+func (c *BBCpu) ISA() assembler.ISA {
+	m := c.CL.Microcode()
+	return m.ISA()
+}
+
+// Timing returns the timing of each instruction, derived from the microcode
+// executed by the control logic.
+func (c *BBCpu) Timing() Timing {
+	m := c.CL.Microcode()
+	return m.Timing()
+}
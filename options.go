@@ -0,0 +1,57 @@
+package eatersim
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oj-mik/eatersim/assembler"
+)
+
+// Option configures a cpu created by New.
+type Option func(c *BBCpu) error
+
+// New creates a new breadboard cpu like NewBBCpu and applies opts in order.
+func New(opts ...Option) (*BBCpu, error) {
+	c := NewBBCpu()
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// WithMemoryImage loads image into the memory, starting at address 0. Fails if
+// the image is larger than the 16 bytes of the memory.
+func WithMemoryImage(image []byte) Option {
+	return func(c *BBCpu) error {
+		return c.Load(image)
+	}
+}
+
+// WithMemoryFile loads the memory image in the file at path, a program in the
+// Intel HEX format if the file name ends in .hex and a binary image otherwise.
+// Fails if the image does not fit into the memory.
+func WithMemoryFile(path string) Option {
+	return func(c *BBCpu) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		base := 0
+		if strings.EqualFold(filepath.Ext(path), ".hex") {
+			data, base, err = assembler.ReadIntelHex(bytes.NewReader(data))
+			if err != nil {
+				return fmt.Errorf("%s: %s", path, err)
+			}
+		}
+		if base+len(data) > len(c.RAM.MEM) {
+			return fmt.Errorf("%s: memory image exceeds the memory of %d bytes", path, len(c.RAM.MEM))
+		}
+		copy(c.RAM.MEM[base:], data)
+		return nil
+	}
+}
@@ -0,0 +1,71 @@
+package eatersim
+
+// Output is a value latched by the output register.
+type Output struct {
+	// Value is the value latched
+	Value byte `json:"value"`
+
+	// Cycle is the full clock cycle the value was latched in
+	Cycle uint64 `json:"cycle"`
+}
+
+// OutputCapture records the values latched by the output register, as the
+// register itself only holds the last one while many programs output a
+// sequence. Set BBCpu.Outputs to enable it, the cpu then records every value
+// latched by OI on a rising clock edge.
+type OutputCapture struct {
+	// Max is the number of outputs kept, the oldest are discarded beyond it.
+	// 0 keeps all outputs.
+	Max int
+
+	outputs []Output
+}
+
+// NewOutputCapture creates an output capture keeping the last max outputs, or
+// all outputs if max is 0.
+func NewOutputCapture(max int) *OutputCapture {
+	return &OutputCapture{Max: max}
+}
+
+// record adds the value v latched in cycle.
+func (o *OutputCapture) record(v byte, cycle uint64) {
+	o.outputs = append(o.outputs, Output{v, cycle})
+	if o.Max > 0 && len(o.outputs) > o.Max {
+		o.outputs = append(o.outputs[:0], o.outputs[len(o.outputs)-o.Max:]...)
+	}
+}
+
+// Outputs returns the outputs recorded since the last call to Clear, oldest
+// first. A nil capture has no outputs.
+func (o *OutputCapture) Outputs() []Output {
+	if o == nil {
+		return nil
+	}
+	return append([]Output(nil), o.outputs...)
+}
+
+// Values returns the values of the outputs recorded since the last call to
+// Clear, oldest first.
+func (o *OutputCapture) Values() []byte {
+	if o == nil {
+		return nil
+	}
+	values := make([]byte, len(o.outputs))
+	for i, out := range o.outputs {
+		values[i] = out.Value
+	}
+	return values
+}
+
+// Len returns the number of outputs recorded since the last call to Clear.
+func (o *OutputCapture) Len() int {
+	if o == nil {
+		return 0
+	}
+	return len(o.outputs)
+}
+
+// Clear discards all outputs, e.g. after loading a program.
+func (o *OutputCapture) Clear() {
+	o.outputs = nil
+}
@@ -0,0 +1,104 @@
+package eatersim
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// runState coordinates Pause and Resume with a running Run or RunAt loop.
+type runState struct {
+	// pause is 1 while a pause is requested, read without the lock by the
+	// loop at every instruction boundary
+	pause int32
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	running bool
+	paused  bool
+}
+
+func (r *runState) init() {
+	if r.cond == nil {
+		r.cond = sync.NewCond(&r.mu)
+	}
+}
+
+// Pause stops a running Run or RunAt loop at the next instruction boundary
+// and waits until it has stopped, so that the cpu may be inspected. The loop
+// waits for Resume instead of returning. Pause returns immediately if no loop
+// is running, a loop started later pauses at its first instruction boundary.
+// Pause may be called from any goroutine.
+func (c *BBCpu) Pause() {
+	r := &c.run
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.init()
+	atomic.StoreInt32(&r.pause, 1)
+	for r.running && !r.paused {
+		r.cond.Wait()
+	}
+}
+
+// Resume continues a loop stopped by Pause. Resume may be called from any
+// goroutine.
+func (c *BBCpu) Resume() {
+	r := &c.run
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.init()
+	atomic.StoreInt32(&r.pause, 0)
+	r.cond.Broadcast()
+}
+
+// Paused tells whether a running loop is stopped by Pause.
+func (c *BBCpu) Paused() bool {
+	r := &c.run
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.paused
+}
+
+// setRunning marks the start and the end of a Run or RunAt loop.
+func (c *BBCpu) setRunning(running bool) {
+	r := &c.run
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.init()
+	r.running = running
+	r.cond.Broadcast()
+}
+
+// checkPause blocks while a pause is requested, if the cpu is at an
+// instruction boundary. Returns true if it blocked.
+func (c *BBCpu) checkPause() bool {
+	r := &c.run
+	if atomic.LoadInt32(&r.pause) == 0 || !c.AtBoundary() {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paused = true
+	r.cond.Broadcast()
+	for atomic.LoadInt32(&r.pause) != 0 {
+		r.cond.Wait()
+	}
+	r.paused = false
+	return true
+}
+
+// AtBoundary tells whether the current instruction is complete, i.e. the
+// clock is high in the last micro instruction step. Stepping an instruction,
+// breakpoints and traces of instructions all stop at this point.
+func (c *BBCpu) AtBoundary() bool {
+	return atBoundary(c.CL.Cnt, c.CLK.CLK)
+}
+
+// AtBoundary tells whether the instruction is complete in the state s, see
+// BBCpu.AtBoundary.
+func (s State) AtBoundary() bool {
+	return atBoundary(s.Step, s.CLK)
+}
+
+func atBoundary(step byte, clk bool) bool {
+	return step == maxStep && clk
+}
@@ -0,0 +1,62 @@
+package eatersim
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// persistence holds the state of a battery backed memory.
+type persistence struct {
+	// path of the memory file, empty if the memory is not persistent
+	path string
+
+	// err is the error of the last save on halt
+	err error
+}
+
+// WithPersistentMemory emulates a battery backed memory kept in the file at
+// path. The memory is loaded from the file if it exists, and written back to
+// it each time the cpu halts, so that the memory survives from one session to
+// the next without programming it again. The file holds the 16 bytes of the
+// memory as they are.
+func WithPersistentMemory(path string) Option {
+	return func(c *BBCpu) error {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return err
+		case len(data) != len(c.RAM.MEM):
+			return fmt.Errorf("%s: persistent memory holds %d bytes, expecting %d", path, len(data), len(c.RAM.MEM))
+		default:
+			copy(c.RAM.MEM[:], data)
+		}
+		c.persist.path = path
+		return nil
+	}
+}
+
+// SaveMemory writes the memory to the file of a persistent memory, see
+// WithPersistentMemory. Does nothing if the memory is not persistent.
+func (c *BBCpu) SaveMemory() error {
+	if c.persist.path == "" {
+		return nil
+	}
+	return os.WriteFile(c.persist.path, c.RAM.MEM[:], 0o644)
+}
+
+// PersistErr returns the error of the last save of a persistent memory when
+// the cpu halted, or nil.
+func (c *BBCpu) PersistErr() error {
+	return c.persist.err
+}
+
+// persistOnHalt saves a persistent memory if the cpu halted since the state
+// hlt.
+func (c *BBCpu) persistOnHalt(hlt bool) {
+	if c.persist.path != "" && c.CL.HLT && !hlt {
+		c.persist.err = c.SaveMemory()
+	}
+}
@@ -0,0 +1,11 @@
+; count by 3 forever, as in Ben's first program for the output register
+ ldi 3
+ sta step
+ ldi 0
+loop:
+ add step
+ out
+ jmp loop
+
+step:
+ .byte 0
@@ -0,0 +1,22 @@
+; print the fibonacci numbers until they exceed 8 bits, then start over
+start:
+ ldi 1
+ sta y
+ ldi 0
+loop:
+ out        ; A holds x
+ add y
+ jc  start
+ sta x      ; x = x + y
+ lda y
+ out
+ add x
+ jc  start
+ sta y      ; y = y + x
+ lda x
+ jmp loop
+
+x:
+ .byte 0
+y:
+ .byte 0
@@ -0,0 +1,25 @@
+; multiply two numbers by repeated addition
+start:
+ lda product
+ add factor1
+ sta product
+ lda factor2
+ sub one
+ sta factor2
+ jz  exit
+ jmp start
+
+exit:
+ lda product
+ out
+ hlt
+
+ .org 12
+one:
+ .byte 1 ; decrementer (must be 1)
+product:
+ .byte 0 ; result
+factor1:
+ .byte 3 ; first number to multiply
+factor2:
+ .byte 5 ; second number to multiply
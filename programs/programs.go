@@ -0,0 +1,86 @@
+// Package programs holds the classic demo programs of Ben's breadboard cpu,
+// ready to load into the emulator:
+//
+//	count      count by 3 forever
+//	fibonacci  the fibonacci numbers up to 144, over and over
+//	multiply   multiply 3 by 5 by repeated addition
+//	updown     count up to 255 and back down, the conditional jump demo
+//
+// Each program comes with its expected behavior, so that it can be checked
+// like the programs passed to 'eatersim test'.
+package programs
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/oj-mik/eatersim"
+	"github.com/oj-mik/eatersim/assembler"
+)
+
+//go:embed *.asm *.expect
+var files embed.FS
+
+// Program is a demo program.
+type Program struct {
+	Name string
+
+	// Source is the assembly source code
+	Source string
+
+	// Object is the assembled program, Object.Bin its memory image
+	Object *assembler.Object
+
+	// Expect is the expected behavior of the program
+	Expect *eatersim.Expectation
+}
+
+// Names returns the names of all programs in ascending order.
+func Names() []string {
+	matches, _ := fs.Glob(files, "*.asm")
+	names := make([]string, len(matches))
+	for i, m := range matches {
+		names[i] = strings.TrimSuffix(m, ".asm")
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Load returns the program called name, assembled and with its expectation.
+func Load(name string) (*Program, error) {
+	src, err := files.ReadFile(name + ".asm")
+	if err != nil {
+		return nil, fmt.Errorf("unknown program %s", name)
+	}
+	p := &Program{Name: name, Source: string(src)}
+
+	prog, err := assembler.ParseFile(name+".asm", p.Source)
+	if err != nil {
+		return nil, err
+	}
+	if p.Object, err = assembler.AssembleObject(prog); err != nil {
+		return nil, err
+	}
+
+	exp, err := files.ReadFile(name + ".expect")
+	if err != nil {
+		return nil, err
+	}
+	if p.Expect, err = eatersim.ParseExpectation(bytes.NewReader(exp)); err != nil {
+		return nil, fmt.Errorf("%s.expect: %s", name, err)
+	}
+	return p, nil
+}
+
+// Check runs the program on a new cpu and checks it against its expectation.
+func (p *Program) Check() error {
+	cpu, err := eatersim.New(eatersim.WithMemoryImage(p.Object.Bin))
+	if err != nil {
+		return err
+	}
+	return p.Expect.Check(cpu)
+}
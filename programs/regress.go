@@ -0,0 +1,153 @@
+package programs
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/oj-mik/eatersim"
+)
+
+// Outcome is the architectural result of running a program with a microcode
+// table, as compared by CompareMicrocode.
+type Outcome struct {
+	// Outputs holds the values latched by the output register, in order
+	Outputs []byte
+
+	// Halted tells whether the program halted
+	Halted bool
+
+	// Cycles is the number of full clock cycles until the program halted or
+	// latched its last expected output
+	Cycles uint64
+
+	// State is the state of the cpu at the end of the run
+	State eatersim.State
+}
+
+// outcomeSlack is the factor by which a program may take more clock cycles
+// than its expectation allows before the run is given up, as a changed
+// microcode may be slower.
+const outcomeSlack = 4
+
+// Outcome runs the program on a new cpu executing the microcode m, until it
+// halts, latches as many outputs as it is expected to, or runs for several
+// times the cycles its expectation allows.
+func (p *Program) Outcome(m eatersim.Microcode) (Outcome, error) {
+	cpu, err := eatersim.New(eatersim.WithMemoryImage(p.Object.Bin), eatersim.WithMicrocode(m))
+	if err != nil {
+		return Outcome{}, err
+	}
+	cpu.Outputs = eatersim.NewOutputCapture(0)
+	limit := outcomeSlack * p.Expect.MaxCycles
+	for !cpu.CL.HLT && cpu.Cycles < limit {
+		if !p.Expect.Halt && cpu.Outputs.Len() >= len(p.Expect.Outputs) {
+			break
+		}
+		cpu.Exec()
+	}
+	o := Outcome{Outputs: cpu.Outputs.Values()}
+	o.Halted = cpu.CL.HLT
+	o.Cycles = cpu.Cycles
+	o.State = cpu.State()
+	return o, nil
+}
+
+// Difference is a difference in the outcome of a program between two
+// microcode tables.
+type Difference struct {
+	// Program is the name of the program
+	Program string
+
+	// Field names what differs, e.g. "cycles", "outputs" or "A"
+	Field string
+
+	// Old and New are the values with the old and the new microcode
+	Old, New string
+}
+
+// CompareMicrocode runs all programs with the microcode tables old and new and
+// returns the differences in their outcome, ordered by program: the outputs,
+// whether they halt, the registers, flags and memory at the end, and the
+// clock cycles they take. An empty result means the new microcode behaves
+// like the old one for the programs.
+func CompareMicrocode(old, new eatersim.Microcode) ([]Difference, error) {
+	var diffs []Difference
+	for _, name := range Names() {
+		p, err := Load(name)
+		if err != nil {
+			return nil, err
+		}
+		a, err := p.Outcome(old)
+		if err != nil {
+			return nil, err
+		}
+		b, err := p.Outcome(new)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, compareOutcomes(name, a, b)...)
+	}
+	return diffs, nil
+}
+
+// compareOutcomes returns the differences between the outcomes a and b of the
+// program called name. Of the outputs only the first difference is reported.
+func compareOutcomes(name string, a, b Outcome) []Difference {
+	var diffs []Difference
+	for i := 0; i < len(a.Outputs) || i < len(b.Outputs); i++ {
+		old, new := outputAt(a.Outputs, i), outputAt(b.Outputs, i)
+		if old != new {
+			diffs = append(diffs, Difference{name, fmt.Sprintf("output %d", i+1), old, new})
+			break
+		}
+	}
+
+	fields := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"halted", a.Halted, b.Halted},
+		{"A", a.State.A, b.State.A},
+		{"B", a.State.B, b.State.B},
+		{"OUT", a.State.Out, b.State.Out},
+		{"PC", a.State.PC, b.State.PC},
+		{"CF", a.State.CF, b.State.CF},
+		{"ZF", a.State.ZF, b.State.ZF},
+	}
+	for i := range a.State.MEM {
+		fields = append(fields, struct {
+			name     string
+			old, new interface{}
+		}{fmt.Sprintf("MEM[%d]", i), a.State.MEM[i], b.State.MEM[i]})
+	}
+	fields = append(fields, struct {
+		name     string
+		old, new interface{}
+	}{"cycles", a.Cycles, b.Cycles})
+
+	for _, f := range fields {
+		if f.old != f.new {
+			diffs = append(diffs, Difference{name, f.name, fmt.Sprint(f.old), fmt.Sprint(f.new)})
+		}
+	}
+	return diffs
+}
+
+// outputAt returns the output at index i as text, or "none" if there is none.
+func outputAt(outputs []byte, i int) string {
+	if i >= len(outputs) {
+		return "none"
+	}
+	return fmt.Sprint(outputs[i])
+}
+
+// WriteDifferences writes diffs to w as a table with a row per difference.
+func WriteDifferences(w io.Writer, diffs []Difference) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROGRAM\tFIELD\tOLD\tNEW")
+	for _, d := range diffs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", d.Program, d.Field, d.Old, d.New)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,16 @@
+; count up to 255 and back down to 0 forever, Ben's demo of the conditional
+; jumps
+up:
+ out
+ add one
+ jc  down
+ jmp up
+down:
+ sub one
+ out
+ jz  up
+ jmp down
+
+ .org 15
+one:
+ .byte 1
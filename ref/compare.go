@@ -0,0 +1,123 @@
+package ref
+
+import (
+	"fmt"
+
+	"github.com/oj-mik/eatersim"
+)
+
+// Mismatch is a difference in the architectural state of the emulator and the
+// reference model after an instruction.
+type Mismatch struct {
+	// Step is the number of instructions executed, starting at 1
+	Step int
+
+	// Addr is the address of the instruction
+	Addr byte
+
+	// Field names the part of the state that differs, e.g. "A" or "MEM[14]"
+	Field string
+
+	// Want is the value of the reference model, Got the value of the
+	// emulator
+	Want, Got interface{}
+}
+
+// Implements the error-interface
+func (m *Mismatch) Error() string {
+	return fmt.Sprintf("step %d, instruction at $%x: %s is %v, reference model has %v", m.Step, m.Addr, m.Field, m.Got, m.Want)
+}
+
+// State returns the architectural state of the emulated cpu.
+func State(cpu *eatersim.BBCpu) Machine {
+	return Machine{
+		A:      cpu.Areg.BUF,
+		B:      cpu.Breg.BUF,
+		Out:    cpu.Oreg.BUF,
+		PC:     cpu.PC.CNT & 0x0f,
+		CF:     cpu.ALU.CF,
+		ZF:     cpu.ALU.ZF,
+		Halted: cpu.CL.HLT,
+		MEM:    cpu.RAM.MEM,
+
+		NoBorrowCarry: cpu.ALU.SubCarry == eatersim.CarryNoBorrow,
+	}
+}
+
+// Compare runs bin on a new emulated cpu and on the reference model side by
+// side, for up to maxSteps instructions or until both halt, and returns the
+// first difference in their architectural state as a *Mismatch.
+func Compare(bin []byte, maxSteps int) error {
+	cpu := eatersim.NewBBCpu()
+	if _, err := cpu.RAM.Write(bin); err != nil {
+		return err
+	}
+	return CompareCPU(cpu, maxSteps)
+}
+
+// CompareCPU works like Compare, starting from the current state of cpu. The
+// cpu must be at the start of an instruction.
+func CompareCPU(cpu *eatersim.BBCpu, maxSteps int) error {
+	m := State(cpu)
+	for step := 1; step <= maxSteps && !m.Halted; step++ {
+		addr := m.PC
+		m.Step()
+		if err := instruction(cpu); err != nil {
+			return fmt.Errorf("step %d, instruction at $%x: %s", step, addr, err)
+		}
+		if err := diff(m, State(cpu)); err != nil {
+			err.Step, err.Addr = step, addr
+			return err
+		}
+	}
+	return nil
+}
+
+// maxInstructionCycles is the number of clock cycles after which an
+// instruction is considered hung.
+const maxInstructionCycles = 8
+
+// instruction executes one instruction like BBCpu.Instruction, but gives up if
+// the instruction does not complete in time.
+func instruction(cpu *eatersim.BBCpu) error {
+	if cpu.CL.HLT {
+		return nil
+	}
+	start := cpu.Cycles
+	cpu.Exec()
+	for !cpu.AtBoundary() && !cpu.CL.HLT {
+		if cpu.Cycles-start > maxInstructionCycles {
+			return fmt.Errorf("instruction did not complete within %d clock cycles", maxInstructionCycles)
+		}
+		cpu.Exec()
+	}
+	return nil
+}
+
+// diff returns the first difference of want and got, or nil.
+func diff(want, got Machine) *Mismatch {
+	fields := []struct {
+		name      string
+		want, got interface{}
+	}{
+		{"A", want.A, got.A},
+		{"B", want.B, got.B},
+		{"OUT", want.Out, got.Out},
+		{"PC", want.PC, got.PC},
+		{"CF", want.CF, got.CF},
+		{"ZF", want.ZF, got.ZF},
+		{"halted", want.Halted, got.Halted},
+	}
+	for i := range want.MEM {
+		fields = append(fields, struct {
+			name      string
+			want, got interface{}
+		}{fmt.Sprintf("MEM[%d]", i), want.MEM[i], got.MEM[i]})
+	}
+	for _, f := range fields {
+		if f.want != f.got {
+			return &Mismatch{Field: f.name, Want: f.want, Got: f.got}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package ref
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/oj-mik/eatersim"
+)
+
+var genOpcodes = []byte{opNOP, opLDA, opADD, opSUB, opSTA, opLDI, opJMP, opJC, opJZ, opTAB, opTBA, opSWP, opLDX, opADX, opOUT}
+
+// Generate returns a random program of 16 bytes: a few instructions ending
+// with HLT, followed by random data. Jumps stay within the instructions and
+// memory accesses mostly go to the data, but may modify the code. The operand
+// byte of LDX and ADX is the instruction following them.
+func Generate(r *rand.Rand) []byte {
+	bin := make([]byte, 16)
+	n := 4 + r.Intn(9) // number of instructions, HLT included
+
+	for i := 0; i < n-1; i++ {
+		op := genOpcodes[r.Intn(len(genOpcodes))]
+		var arg int
+		switch op {
+		case opJMP, opJC, opJZ:
+			arg = r.Intn(n)
+		case opLDA, opADD, opSUB, opSTA:
+			arg = n + r.Intn(16-n)
+			if r.Intn(8) == 0 {
+				arg = r.Intn(16)
+			}
+		default:
+			arg = r.Intn(16)
+		}
+		bin[i] = op<<4 | byte(arg)
+	}
+	bin[n-1] = opHLT << 4
+
+	for i := n; i < len(bin); i++ {
+		bin[i] = byte(r.Intn(256))
+	}
+	return bin
+}
+
+// Check runs bin on the emulator and the reference model for up to maxSteps
+// instructions and returns their first difference. A panic of the emulator
+// and an instruction that does not complete are returned as errors too.
+func Check(bin []byte, maxSteps int) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("emulator panicked: %v", r)
+		}
+	}()
+	cpu := eatersim.NewBBCpu()
+	if _, err := cpu.RAM.Write(bin); err != nil {
+		return err
+	}
+	return CompareCPU(cpu, maxSteps)
+}
@@ -0,0 +1,116 @@
+// Package ref is a behavioral reference model of the breadboard cpu. It
+// interprets programs instruction by instruction, without boards, signals or
+// clock cycles, so that it is simple enough to be obviously correct. Compare
+// checks the cycle accurate emulator against it.
+package ref
+
+// opcodes of the instructions, as the reference model interprets them
+const (
+	opNOP = 0x0
+	opLDA = 0x1
+	opADD = 0x2
+	opSUB = 0x3
+	opSTA = 0x4
+	opLDI = 0x5
+	opJMP = 0x6
+	opJC  = 0x7
+	opJZ  = 0x8
+	opTAB = 0x9
+	opTBA = 0xa
+	opSWP = 0xb
+	opLDX = 0xc
+	opADX = 0xd
+	opOUT = 0xe
+	opHLT = 0xf
+)
+
+// Machine is the architectural state of the cpu.
+type Machine struct {
+	A, B, Out byte
+	PC        byte
+	CF, ZF    bool
+	Halted    bool
+	MEM       [16]byte
+
+	// NoBorrowCarry makes SUB set CF if it does not borrow, like the real
+	// hardware, instead of if it borrows
+	NoBorrowCarry bool
+}
+
+// New creates a machine with bin loaded at address 0.
+func New(bin []byte) *Machine {
+	m := new(Machine)
+	copy(m.MEM[:], bin)
+	return m
+}
+
+// Step executes one instruction. Does nothing if the machine is halted.
+// Opcodes without instruction do nothing, like NOP.
+func (m *Machine) Step() {
+	if m.Halted {
+		return
+	}
+	ir := m.MEM[m.PC]
+	m.PC = (m.PC + 1) & 0x0f
+	op, arg := ir>>4, ir&0x0f
+
+	switch op {
+	case opLDA:
+		m.A = m.MEM[arg]
+	case opADD:
+		m.B = m.MEM[arg]
+		sum := int(m.A) + int(m.B)
+		m.CF, m.ZF = sum > 0xff, byte(sum) == 0
+		m.A = byte(sum)
+	case opSUB:
+		m.B = m.MEM[arg]
+		m.CF, m.ZF = m.A < m.B, m.A == m.B
+		if m.NoBorrowCarry {
+			m.CF = !m.CF
+		}
+		m.A -= m.B
+	case opSTA:
+		m.MEM[arg] = m.A
+	case opLDI:
+		m.A = arg
+	case opJMP:
+		m.PC = arg
+	case opJC:
+		if m.CF {
+			m.PC = arg
+		}
+	case opJZ:
+		if m.ZF {
+			m.PC = arg
+		}
+	case opTAB:
+		m.B = m.A
+	case opTBA:
+		m.A = m.B
+	case opSWP:
+		m.A, m.B = m.B, m.A
+	case opLDX:
+		m.A = m.MEM[m.PC]
+		m.PC = (m.PC + 1) & 0x0f
+	case opADX:
+		m.B = m.MEM[m.PC]
+		m.PC = (m.PC + 1) & 0x0f
+		sum := int(m.A) + int(m.B)
+		m.CF, m.ZF = sum > 0xff, byte(sum) == 0
+		m.A = byte(sum)
+	case opOUT:
+		m.Out = m.A
+	case opHLT:
+		m.Halted = true
+	}
+}
+
+// Run executes instructions until the machine halts or maxSteps instructions
+// have been executed. Returns the number of instructions executed.
+func (m *Machine) Run(maxSteps int) int {
+	n := 0
+	for ; n < maxSteps && !m.Halted; n++ {
+		m.Step()
+	}
+	return n
+}
@@ -0,0 +1,45 @@
+package ref
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/oj-mik/eatersim/programs"
+)
+
+func TestComparePrograms(t *testing.T) {
+	for _, name := range programs.Names() {
+		p, err := programs.Load(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Compare(p.Object.Bin, 1000); err != nil {
+			t.Errorf("%s: %s", name, err)
+		}
+	}
+}
+
+func TestCompareGenerated(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		bin := Generate(r)
+		if err := Check(bin, 1000); err != nil {
+			t.Fatalf("program % x: %s", bin, err)
+		}
+	}
+}
+
+func FuzzCompare(f *testing.F) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 32; i++ {
+		f.Add(Generate(r))
+	}
+	f.Fuzz(func(t *testing.T, bin []byte) {
+		if len(bin) > 16 {
+			bin = bin[:16]
+		}
+		if err := Check(bin, 1000); err != nil {
+			t.Errorf("program % x: %s", bin, err)
+		}
+	})
+}
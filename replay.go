@@ -0,0 +1,288 @@
+package eatersim
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
+)
+
+// InputKind identifies an external input to the cpu.
+type InputKind int
+
+// The external inputs recorded by a Journal.
+const (
+	// InputReset presses the reset button
+	InputReset InputKind = iota
+
+	// InputProg sets the RUN/PROG switch of the memory, Value is 1 for
+	// programming mode
+	InputProg
+
+	// InputSwitches sets the address and data switches of the memory to Addr
+	// and Value
+	InputSwitches
+
+	// InputProgram presses the program button of the memory
+	InputProgram
+
+	// InputPoke writes Value to the memory at Addr, bypassing the bus
+	InputPoke
+
+	// InputStop sets the external halt input, Value is 1 to stop the clock
+	InputStop
+)
+
+var inputKindNames = [...]string{
+	InputReset:    "reset",
+	InputProg:     "prog",
+	InputSwitches: "switches",
+	InputProgram:  "program",
+	InputPoke:     "poke",
+	InputStop:     "stop",
+}
+
+// Implements the Stringer-interface
+func (k InputKind) String() string {
+	if k < 0 || int(k) >= len(inputKindNames) {
+		return fmt.Sprintf("InputKind(%d)", int(k))
+	}
+	return inputKindNames[k]
+}
+
+// MarshalText implements the encoding.TextMarshaler-interface.
+func (k InputKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler-interface.
+func (k *InputKind) UnmarshalText(text []byte) error {
+	for i, name := range inputKindNames {
+		if name == string(text) {
+			*k = InputKind(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown input %s", text)
+}
+
+// Input is an external input to the cpu, applied before the half step with
+// the index HalfStep.
+type Input struct {
+	HalfStep uint64    `json:"half_step"`
+	Kind     InputKind `json:"kind"`
+	Addr     byte      `json:"addr,omitempty"`
+	Value    byte      `json:"value,omitempty"`
+}
+
+// Replay is a recorded run which can be replayed bit exactly.
+type Replay struct {
+	// MEM is the content of the memory at the start of the run
+	MEM [0x10]byte `json:"mem"`
+
+	// Inputs holds the inputs of the run in order
+	Inputs []Input `json:"inputs"`
+
+	// HalfSteps is the number of half steps executed
+	HalfSteps uint64 `json:"half_steps"`
+
+	// Hash is the trace hash of the run, see Journal
+	Hash string `json:"hash"`
+}
+
+// Journal records the external inputs to a cpu for a later replay. All inputs
+// and clock pulses must pass through the journal while it records.
+//
+// The trace hash of the journal is a 64 bit FNV-1a hash of the state of the
+// cpu after each half step and at the end of the run. A replay with the same
+// hash went through exactly the same states.
+type Journal struct {
+	cpu  *BBCpu
+	rep  Replay
+	hash hash.Hash64
+}
+
+// NewJournal starts recording the inputs to cpu. The cpu must be in the state
+// returned by NewBBCpu except for the content of its memory.
+func NewJournal(cpu *BBCpu) *Journal {
+	j := &Journal{cpu: cpu, hash: fnv.New64a()}
+	j.rep.MEM = cpu.RAM.MEM
+	return j
+}
+
+// CPU returns the cpu the journal records.
+func (j *Journal) CPU() *BBCpu {
+	return j.cpu
+}
+
+func (j *Journal) input(kind InputKind, addr, value byte) {
+	j.rep.Inputs = append(j.rep.Inputs, Input{HalfStep: j.rep.HalfSteps, Kind: kind, Addr: addr, Value: value})
+}
+
+// Exec executes one half step of the cpu.
+func (j *Journal) Exec() {
+	j.cpu.Exec()
+	j.rep.HalfSteps++
+	hashState(j.hash, j.cpu.State())
+}
+
+// Step executes two half steps of the cpu, see BBCpu.Step.
+func (j *Journal) Step() {
+	j.Exec()
+	j.Exec()
+}
+
+// Instruction executes the cpu until the current instruction is complete, see
+// BBCpu.Instruction.
+func (j *Journal) Instruction() {
+	if j.cpu.Halted() {
+		return
+	}
+	j.Exec()
+	for !j.cpu.AtBoundary() && !j.cpu.Halted() {
+		j.Exec()
+	}
+}
+
+// Reset presses the reset button, see BBCpu.Reset.
+func (j *Journal) Reset() {
+	j.input(InputReset, 0, 0)
+	j.cpu.CL.Clear()
+	j.Exec()
+	for j.cpu.CL.CLR {
+		j.Exec()
+	}
+}
+
+// SetProg sets the RUN/PROG switch of the memory.
+func (j *Journal) SetProg(prog bool) {
+	j.input(InputProg, 0, b2byte(prog))
+	j.cpu.RAM.Prog = prog
+}
+
+// SetSwitches sets the address and data switches of the memory.
+func (j *Journal) SetSwitches(addr, data byte) {
+	j.input(InputSwitches, addr, data)
+	j.cpu.RAM.AddrSW, j.cpu.RAM.DataSW = addr, data
+}
+
+// Program presses the program button of the memory.
+func (j *Journal) Program() {
+	j.input(InputProgram, 0, 0)
+	j.cpu.RAM.Program()
+}
+
+// Poke writes v to the memory at addr.
+func (j *Journal) Poke(addr, v byte) {
+	j.input(InputPoke, addr, v)
+	j.cpu.RAM.MEM[addr&0x0f] = v
+}
+
+// SetStop sets the external halt input of the cpu.
+func (j *Journal) SetStop(stop bool) {
+	j.input(InputStop, 0, b2byte(stop))
+	j.cpu.Stop = stop
+}
+
+// Replay returns the recording so far.
+func (j *Journal) Replay() *Replay {
+	r := j.rep
+	r.Inputs = append([]Input(nil), j.rep.Inputs...)
+	r.Hash = finalHash(j.hash, j.cpu.State())
+	return &r
+}
+
+// Run replays the recording on a new cpu. Returns the cpu, and an error if
+// the trace hash of the replay differs from the recorded one.
+func (r *Replay) Run() (*BBCpu, error) {
+	cpu := NewBBCpu()
+	cpu.RAM.MEM = r.MEM
+	j := NewJournal(cpu)
+
+	inputs := r.Inputs
+	for {
+		for len(inputs) > 0 && inputs[0].HalfStep <= j.rep.HalfSteps {
+			in := inputs[0]
+			inputs = inputs[1:]
+			switch in.Kind {
+			case InputReset:
+				j.Reset()
+			case InputProg:
+				j.SetProg(in.Value != 0)
+			case InputSwitches:
+				j.SetSwitches(in.Addr, in.Value)
+			case InputProgram:
+				j.Program()
+			case InputPoke:
+				j.Poke(in.Addr, in.Value)
+			case InputStop:
+				j.SetStop(in.Value != 0)
+			default:
+				return cpu, fmt.Errorf("half step %d: unknown input %v", in.HalfStep, in.Kind)
+			}
+		}
+		if j.rep.HalfSteps >= r.HalfSteps {
+			break
+		}
+		j.Exec()
+	}
+
+	if h := finalHash(j.hash, cpu.State()); h != r.Hash {
+		return cpu, fmt.Errorf("replay diverged, trace hash %s, recorded %s", h, r.Hash)
+	}
+	return cpu, nil
+}
+
+// WriteTo writes the recording to w as JSON.
+func (r *Replay) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(data, '\n'))
+	return int64(n), err
+}
+
+// ReadReplay reads a recording written by Replay.WriteTo.
+func ReadReplay(r io.Reader) (*Replay, error) {
+	rep := new(Replay)
+	if err := json.NewDecoder(r).Decode(rep); err != nil {
+		return nil, err
+	}
+	return rep, nil
+}
+
+// hashState adds s to the trace hash h.
+func hashState(h hash.Hash64, s State) {
+	var buf [23]byte
+	binary.LittleEndian.PutUint64(buf[0:], s.Cycles)
+	buf[8] = b2byte(s.CLK)
+	buf[9] = s.BUS
+	buf[10], buf[11], buf[12], buf[13] = s.A, s.B, s.Out, s.IR
+	buf[14], buf[15], buf[16] = s.MAR, s.PC, s.ALU
+	buf[17] = b2byte(s.CF)<<1 | b2byte(s.ZF)
+	buf[18] = s.Step
+	binary.LittleEndian.PutUint32(buf[19:], uint32(s.Control))
+	h.Write(buf[:])
+	h.Write(s.MEM[:])
+}
+
+// finalHash returns the trace hash of h with the final state s added, leaving
+// h unchanged.
+func finalHash(h hash.Hash64, s State) string {
+	var sum [8]byte
+	binary.LittleEndian.PutUint64(sum[:], h.Sum64())
+	f := fnv.New64a()
+	f.Write(sum[:])
+	hashState(f, s)
+	return fmt.Sprintf("%016x", f.Sum64())
+}
+
+func b2byte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
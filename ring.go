@@ -0,0 +1,81 @@
+package eatersim
+
+import "fmt"
+
+// Ring represents the micro instruction step counter of the control logic, a
+// 74LS161 counter whose value is decoded by a 74LS138 into the T-state lines
+// T0-T4. The counter is incremented on falling clock edge, and the decoded T5
+// line resets it to T0.
+type Ring struct {
+	// counter value, the index of the active T-state
+	Cnt byte
+
+	// T holds the decoded T-state lines, T[n] is active in T-state n
+	T [Steps]bool
+
+	// control signals
+	// read only
+	// CLK is the clock pulse
+	// CLR resets the counter to T0
+	CLK, CLR *bool
+
+	// helper states
+	clkprev, clkfe bool
+}
+
+// NewRing creates a new step counter and initialize it's signals with the
+// signals passed in the function call
+func NewRing(clk, clr *bool) *Ring {
+	r := new(Ring)
+	r.CLK = clk
+	r.CLR = clr
+	r.T[0] = true
+	return r
+}
+
+// Executes the logic of the step counter once. Updates the internal states.
+func (r *Ring) Exec() {
+	r.clkfe = !ptbool(r.CLK) && r.clkprev
+	r.clkprev = ptbool(r.CLK)
+
+	if r.clkfe {
+		r.Cnt++
+	}
+	if int(r.Cnt) >= len(r.T) || ptbool(r.CLR) {
+		r.Cnt = 0
+	}
+	for i := range r.T {
+		r.T[i] = int(r.Cnt) == i
+	}
+}
+
+// Implements the Stringer-interface
+func (r *Ring) String() string {
+	s := fmt.Sprintf("CNT: %03b, T%d", r.Cnt, r.Cnt)
+	s += "\nactive control signals: "
+	f := false
+	if ptbool(r.CLK) {
+		s += "CLK"
+		f = true
+	}
+	if ptbool(r.CLR) {
+		if f {
+			s += ", "
+		}
+		s += "CLR"
+		f = true
+	}
+	if !f {
+		s += "none"
+	}
+	return s
+}
+
+// LEDs renders the T-state lines as a row of LEDs, T0 leftmost.
+func (r *Ring) LEDs() string {
+	var s string
+	for _, t := range r.T {
+		s += LED(t, LEDGreen)
+	}
+	return s
+}
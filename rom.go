@@ -0,0 +1,71 @@
+package eatersim
+
+import (
+	"errors"
+	"fmt"
+)
+
+// WriteFault is a refused write to a read-only address of the memory.
+type WriteFault struct {
+	// Addr is the address written to
+	Addr byte
+
+	// Value is the refused value
+	Value byte
+}
+
+// Implements the error-interface
+func (f *WriteFault) Error() string {
+	return fmt.Sprintf("write of $%02x to read-only address $%x", f.Value, f.Addr)
+}
+
+// SetROM writes data to the memory starting at addr and marks the addresses
+// written as read-only. Returns an error and writes nothing if data exceeds
+// the memory.
+func (m *Mem) SetROM(addr byte, data []byte) error {
+	if int(addr)+len(data) > len(m.MEM) {
+		return errors.New("rom exceeds the memory")
+	}
+	copy(m.MEM[addr:], data)
+	m.Protect(addr, addr+byte(len(data)))
+	return nil
+}
+
+// Protect marks the addresses from start up to but not including end as
+// read-only.
+func (m *Mem) Protect(start, end byte) {
+	for a := start; a < end && a < 0x10; a++ {
+		m.ROM |= 1 << a
+	}
+}
+
+// ReadOnly tells whether addr is read-only.
+func (m *Mem) ReadOnly(addr byte) bool {
+	return m.ROM&(1<<(addr&0x0f)) != 0
+}
+
+// store writes v to the memory at addr unless addr is read-only. Refused
+// writes are reported to OnFault.
+func (m *Mem) store(addr, v byte) {
+	addr &= 0x0f
+	if !m.ReadOnly(addr) {
+		m.MEM[addr] = v
+		return
+	}
+	if m.OnFault != nil {
+		m.OnFault(&WriteFault{Addr: addr, Value: v})
+	}
+}
+
+// SetBootROM maps the startup code in code to a read-only region of the
+// memory starting at addr, makes addr the reset vector of the program counter
+// and resets the cpu, so that it starts there now and after each reset. The
+// rest of the memory remains writable.
+func (c *BBCpu) SetBootROM(addr byte, code []byte) error {
+	if err := c.RAM.SetROM(addr, code); err != nil {
+		return err
+	}
+	c.PC.Vector = addr
+	c.Reset()
+	return nil
+}
@@ -0,0 +1,52 @@
+package eatersim
+
+import "testing"
+
+func TestResetAtBothClockPhases(t *testing.T) {
+	// LDI 7, OUT, HLT
+	want := []byte{0x57, 0xe0, 0xf0}
+	// LDI 1, OUT, HLT
+	stale := []byte{0x51, 0xe0, 0xf0}
+
+	resets := []struct {
+		name  string
+		reset func(cpu *BBCpu)
+	}{
+		{"cpu", (*BBCpu).Reset},
+		{"circuit", func(cpu *BBCpu) { cpu.Circuit().Reset() }},
+	}
+	for _, r := range resets {
+		for _, rom := range []bool{false, true} {
+			// reset after an even and an odd number of half steps, with the clock
+			// low and high, at every step of the first instructions
+			for n := 0; n < 12; n++ {
+				cpu := NewBBCpu()
+				if rom {
+					copy(cpu.RAM.MEM[0x0:], stale)
+					if err := cpu.SetBootROM(0xc, want); err != nil {
+						t.Fatal(err)
+					}
+				} else {
+					copy(cpu.RAM.MEM[0x0:], want)
+					copy(cpu.RAM.MEM[0xa:], stale)
+				}
+				for i := 0; i < n; i++ {
+					cpu.Exec()
+				}
+				r.reset(cpu)
+				if cpu.CL.CLR {
+					t.Fatalf("%s reset, rom %v, after %d half steps: CLR still active", r.name, rom, n)
+				}
+				for i := 0; i < 100 && !cpu.Halted(); i++ {
+					cpu.Exec()
+				}
+				if !cpu.Halted() {
+					t.Fatalf("%s reset, rom %v, after %d half steps: not halted", r.name, rom, n)
+				}
+				if cpu.Oreg.BUF != 7 {
+					t.Errorf("%s reset, rom %v, after %d half steps: output %d, want 7", r.name, rom, n, cpu.Oreg.BUF)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,397 @@
+// Package script runs test benches for the breadboard cpu written as simple
+// line based scripts, so that benches for exercises can be written and changed
+// without writing or compiling Go.
+//
+// Each line holds a command and its arguments separated by whitespace, text
+// after '#' is a comment. Numbers are decimal, or hexadecimal and binary with
+// the prefixes $, 0x, % and 0b. A bench for the multiplication program:
+//
+//	load mul.asm          # load a program into memory
+//	mem 14 3 5            # set the factors
+//	halt 500              # run until the cpu halts, at most 500 cycles
+//	expect out 15         # check a register
+//	expect outputs 15     # check all values latched by the output register
+//
+// The commands are:
+//
+//	load FILE             load a program, see Bench.Load and BBCpu.Load
+//	mem ADDR VALUE...     write values to memory starting at ADDR
+//	reset                 press the reset button
+//	prog on|off           set the RUN/PROG switch of the memory
+//	addr VALUE            set the address switches of the memory
+//	data VALUE            set the data switches of the memory
+//	program               press the program button of the memory
+//	run CYCLES            run full clock cycles, stops early if the cpu halts
+//	step INSTRUCTIONS     run instructions, stops early if the cpu halts
+//	halt [CYCLES]         run until the cpu halts, fails after CYCLES cycles
+//	at CYCLE COMMAND...   run a command once the cpu reaches the clock cycle
+//	expect NAME VALUE     check a register, flag or control signal
+//	expect outputs VALUE...  check the values latched by the output register
+//	expect mem ADDR VALUE check a memory location
+//	assert EXPR           check that a watch expression is true, see eatersim.Expr
+//	print                 print the state of the cpu
+//	echo TEXT...          print a message
+//
+// The names for expect are the registers bus, a, b, out, ir, mar, pc, alu and
+// step, the signals clk, cf, zf and the control signals, e.g. HLT or RO.
+package script
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/oj-mik/eatersim"
+)
+
+// Error is a failure of a script command.
+type Error struct {
+	Line int
+	Msg  string
+}
+
+// Implements the error-interface
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// Bench runs scripts against a cpu.
+type Bench struct {
+	CPU *eatersim.BBCpu
+
+	// Load returns the memory image of the program named by the load command.
+	// Scripts using load fail if Load is nil.
+	Load func(name string) ([]byte, error)
+
+	// Out receives the output of print and echo, discarded if nil
+	Out io.Writer
+
+	// Outputs holds the values latched by the output register so far
+	Outputs []byte
+
+	pending []event
+}
+
+// event is a command scheduled by the at command.
+type event struct {
+	cycle uint64
+	line  int
+	args  []string
+}
+
+// New creates a bench for cpu.
+func New(cpu *eatersim.BBCpu) *Bench {
+	return &Bench{CPU: cpu}
+}
+
+// Run reads the script from r and executes it line by line. Returns an *Error
+// for the first failing command, or nil if the script passed.
+func (b *Bench) Run(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		ln := sc.Text()
+		if i := strings.IndexByte(ln, '#'); i >= 0 {
+			ln = ln[:i]
+		}
+		args := strings.Fields(ln)
+		if len(args) == 0 {
+			continue
+		}
+		if err := b.exec(n, args); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// exec executes a single command.
+func (b *Bench) exec(line int, args []string) error {
+	err := b.command(line, args)
+	if err != nil {
+		if _, ok := err.(*Error); !ok {
+			err = &Error{Line: line, Msg: err.Error()}
+		}
+	}
+	return err
+}
+
+func (b *Bench) command(line int, args []string) error {
+	cpu := b.CPU
+	cmd, args := strings.ToLower(args[0]), args[1:]
+
+	switch cmd {
+	case "load":
+		if len(args) != 1 {
+			return errors.New("expecting a file name")
+		}
+		if b.Load == nil {
+			return errors.New("loading programs is not supported")
+		}
+		bin, err := b.Load(args[0])
+		if err != nil {
+			return err
+		}
+		return cpu.Load(bin)
+	case "mem":
+		if len(args) < 2 {
+			return errors.New("expecting an address and values")
+		}
+		addr, err := number(args[0], 0x0f)
+		if err != nil {
+			return err
+		}
+		vs, err := numbers(args[1:])
+		if err != nil {
+			return err
+		}
+		if int(addr)+len(vs) > len(cpu.RAM.MEM) {
+			return errors.New("values exceed the memory")
+		}
+		copy(cpu.RAM.MEM[addr:], vs)
+	case "reset":
+		cpu.Reset()
+	case "prog":
+		if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+			return errors.New("expecting on or off")
+		}
+		cpu.RAM.Prog = args[0] == "on"
+	case "addr", "data":
+		if len(args) != 1 {
+			return errors.New("expecting a value")
+		}
+		max := byte(0xff)
+		if cmd == "addr" {
+			max = 0x0f
+		}
+		v, err := number(args[0], max)
+		if err != nil {
+			return err
+		}
+		if cmd == "addr" {
+			cpu.RAM.AddrSW = v
+		} else {
+			cpu.RAM.DataSW = v
+		}
+	case "program":
+		cpu.RAM.Program()
+	case "run", "step", "halt":
+		return b.run(cmd, args)
+	case "at":
+		if len(args) < 2 {
+			return errors.New("expecting a clock cycle and a command")
+		}
+		c, err := strconv.ParseUint(args[0], 0, 64)
+		if err != nil {
+			return fmt.Errorf("invalid clock cycle %s", args[0])
+		}
+		if c < cpu.Cycles {
+			return fmt.Errorf("clock cycle %d has passed", c)
+		}
+		b.pending = append(b.pending, event{c, line, args[1:]})
+		sort.SliceStable(b.pending, func(i, j int) bool { return b.pending[i].cycle < b.pending[j].cycle })
+	case "expect":
+		return b.expect(args)
+	case "assert":
+		e, err := eatersim.ParseExpr(strings.Join(args, " "), nil)
+		if err != nil {
+			return err
+		}
+		if !e.True(cpu.State()) {
+			return fmt.Errorf("%s is false", e)
+		}
+	case "print":
+		if b.Out != nil {
+			fmt.Fprintln(b.Out, cpu)
+		}
+	case "echo":
+		if b.Out != nil {
+			fmt.Fprintln(b.Out, strings.Join(args, " "))
+		}
+	default:
+		return fmt.Errorf("unknown command %s", cmd)
+	}
+	return nil
+}
+
+// run executes the run, step and halt commands.
+func (b *Bench) run(cmd string, args []string) error {
+	cpu := b.CPU
+	n := uint64(eatersim.DefaultMaxCycles)
+	if len(args) > 1 || (len(args) == 0 && cmd != "halt") {
+		return fmt.Errorf("expecting a count after %s", cmd)
+	}
+	if len(args) == 1 {
+		var err error
+		n, err = strconv.ParseUint(args[0], 0, 64)
+		if err != nil {
+			return fmt.Errorf("invalid count %s", args[0])
+		}
+	}
+
+	start := cpu.Cycles
+	for i := uint64(0); !cpu.CL.HLT; {
+		if (cmd != "step" && cpu.Cycles-start >= n) || (cmd == "step" && i >= n) {
+			break
+		}
+		if err := b.halfStep(); err != nil {
+			return err
+		}
+		if cmd == "step" && cpu.AtBoundary() {
+			i++
+		}
+	}
+	if cmd == "halt" && !cpu.CL.HLT {
+		return fmt.Errorf("cpu did not halt within %d cycles", n)
+	}
+	return nil
+}
+
+// halfStep runs the commands due and executes one half step of the cpu.
+func (b *Bench) halfStep() error {
+	cpu := b.CPU
+	for len(b.pending) > 0 && b.pending[0].cycle <= cpu.Cycles {
+		e := b.pending[0]
+		b.pending = b.pending[1:]
+		if err := b.exec(e.line, e.args); err != nil {
+			return err
+		}
+	}
+
+	clk := cpu.CLK.CLK
+	cpu.HalfStep()
+	if cpu.CLK.CLK && !clk && cpu.CL.OI {
+		b.Outputs = append(b.Outputs, cpu.Oreg.BUF)
+	}
+	return nil
+}
+
+// expect executes the expect command.
+func (b *Bench) expect(args []string) error {
+	if len(args) < 1 {
+		return errors.New("expecting a name and a value")
+	}
+	name := args[0]
+
+	switch strings.ToLower(name) {
+	case "outputs":
+		want, err := numbers(args[1:])
+		if err != nil {
+			return err
+		}
+		if string(want) != string(b.Outputs) {
+			return fmt.Errorf("outputs: want %v, got %v", want, b.Outputs)
+		}
+		return nil
+	case "mem":
+		if len(args) != 3 {
+			return errors.New("expecting an address and a value")
+		}
+		addr, err := number(args[1], 0x0f)
+		if err != nil {
+			return err
+		}
+		want, err := number(args[2], 0xff)
+		if err != nil {
+			return err
+		}
+		if got := b.CPU.RAM.MEM[addr]; got != want {
+			return fmt.Errorf("mem %d: want %d, got %d", addr, want, got)
+		}
+		return nil
+	}
+
+	if len(args) != 2 {
+		return errors.New("expecting a name and a value")
+	}
+	got, ok := value(b.CPU.State(), name)
+	if !ok {
+		return fmt.Errorf("unknown name %s", name)
+	}
+	want, err := number(args[1], 0xff)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("%s: want %d, got %d", name, want, got)
+	}
+	return nil
+}
+
+// value returns the register, flag or control signal called name in s. Flags
+// and signals are 1 if active and 0 otherwise.
+func value(s eatersim.State, name string) (byte, bool) {
+	switch strings.ToLower(name) {
+	case "bus":
+		return s.BUS, true
+	case "a":
+		return s.A, true
+	case "b":
+		return s.B, true
+	case "out":
+		return s.Out, true
+	case "ir":
+		return s.IR, true
+	case "mar":
+		return s.MAR, true
+	case "pc":
+		return s.PC, true
+	case "alu":
+		return s.ALU, true
+	case "step":
+		return s.Step, true
+	case "clk":
+		return b2i(s.CLK), true
+	case "cf":
+		return b2i(s.CF), true
+	case "zf":
+		return b2i(s.ZF), true
+	}
+	for i := 0; i < 32; i++ {
+		w := eatersim.ControlWord(1 << uint(i))
+		if strings.EqualFold(w.String(), name) {
+			return b2i(s.Control&w != 0), true
+		}
+	}
+	return 0, false
+}
+
+// number parses s as a value of at most max.
+func number(s string, max byte) (byte, error) {
+	t, base := s, 10
+	switch {
+	case strings.HasPrefix(s, "$"):
+		t, base = s[1:], 16
+	case strings.HasPrefix(s, "%"):
+		t, base = s[1:], 2
+	case strings.HasPrefix(s, "0x"), strings.HasPrefix(s, "0b"):
+		base = 0
+	}
+	v, err := strconv.ParseUint(t, base, 8)
+	if err != nil || v > uint64(max) {
+		return 0, fmt.Errorf("invalid value %s", s)
+	}
+	return byte(v), nil
+}
+
+func numbers(args []string) ([]byte, error) {
+	vs := make([]byte, len(args))
+	for i, a := range args {
+		v, err := number(a, 0xff)
+		if err != nil {
+			return nil, err
+		}
+		vs[i] = v
+	}
+	return vs, nil
+}
+
+func b2i(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/oj-mik/eatersim"
+	"github.com/oj-mik/eatersim/assembler"
+)
+
+// metrics are the statistics of the server exported in the Prometheus text
+// format at /metrics. Guarded by the mutex of the server.
+type metrics struct {
+	// retired counts the executed instructions by opcode
+	retired [16]uint64
+
+	// hz is the clock frequency achieved while running, measured over
+	// windows of hzWindow
+	hz          float64
+	windowStart time.Time
+	windowCycle uint64
+}
+
+const hzWindow = time.Second
+
+// startWindow starts measuring the clock frequency at cycle.
+func (m *metrics) startWindow(cycle uint64) {
+	m.windowStart = time.Now()
+	m.windowCycle = cycle
+}
+
+// tick updates the clock frequency when a measuring window has passed.
+func (m *metrics) tick(cycle uint64) {
+	if d := time.Since(m.windowStart); d >= hzWindow {
+		m.hz = float64(cycle-m.windowCycle) / d.Seconds()
+		m.startWindow(cycle)
+	}
+}
+
+// exec executes one half step of the cpu and counts the instruction if it
+// completes. Must be called with mu held.
+func (s *Server) exec() {
+	cpu := s.dbg.CPU
+	clk, hlt := cpu.CLK.CLK, cpu.CL.HLT
+	cpu.Exec()
+	// instructions end on the rising edge of the last micro step, or when the
+	// cpu halts
+	if (cpu.AtBoundary() && !clk) || (cpu.CL.HLT && !hlt) {
+		if op := cpu.CL.Opcode(); int(op) < len(s.metrics.retired) {
+			s.metrics.retired[op]++
+		}
+	}
+}
+
+// instruction executes the cpu until the current instruction is complete, like
+// BBCpu.InstructionBudget, taking the half steps from budget. Must be called
+// with mu held.
+func (s *Server) instruction(budget *int) error {
+	cpu := s.dbg.CPU
+	if cpu.CL.HLT {
+		return nil
+	}
+	for {
+		if *budget <= 0 {
+			return eatersim.ErrBudget
+		}
+		*budget--
+		s.exec()
+		if cpu.AtBoundary() || cpu.CL.HLT {
+			return nil
+		}
+	}
+}
+
+// micro executes the cpu until after the next rising clock edge, like
+// Debugger.Micro, taking the half steps from budget. Must be called with mu
+// held.
+func (s *Server) micro(budget *int) error {
+	cpu := s.dbg.CPU
+	if cpu.CL.HLT {
+		return nil
+	}
+	for {
+		if *budget <= 0 {
+			return eatersim.ErrBudget
+		}
+		*budget--
+		s.exec()
+		if cpu.CLK.CLK || cpu.CL.HLT {
+			return nil
+		}
+	}
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	m := s.metrics
+	cycles := s.dbg.CPU.Cycles
+	clients := len(s.clients)
+	running := s.stop != nil
+	s.mu.Unlock()
+	if !running {
+		m.hz = 0
+	}
+
+	var b strings.Builder
+	metric := func(name, typ, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	metric("eatersim_clock_hz", "gauge", "Achieved clock frequency in full clock cycles per second while running.")
+	fmt.Fprintf(&b, "eatersim_clock_hz %g\n", m.hz)
+	metric("eatersim_cycles_total", "counter", "Full clock cycles executed.")
+	fmt.Fprintf(&b, "eatersim_cycles_total %d\n", cycles)
+	metric("eatersim_instructions_total", "counter", "Instructions executed by the server, by opcode.")
+	for op, n := range m.retired {
+		name, ok := assembler.MnemonicOf(byte(op << 4))
+		if !ok {
+			name = fmt.Sprintf("%#x", op)
+		}
+		fmt.Fprintf(&b, "eatersim_instructions_total{opcode=%q} %d\n", strings.ToLower(name), n)
+	}
+	metric("eatersim_websocket_clients", "gauge", "Connected WebSocket clients.")
+	fmt.Fprintf(&b, "eatersim_websocket_clients %d\n", clients)
+	metric("eatersim_running", "gauge", "Whether the cpu is running, 1 or 0.")
+	if running {
+		b.WriteString("eatersim_running 1\n")
+	} else {
+		b.WriteString("eatersim_running 0\n")
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
@@ -0,0 +1,513 @@
+// Package server exposes a breadboard cpu over HTTP, so that web frontends can
+// control the emulator and display its state without reimplementing it.
+//
+// The machine is controlled by these requests:
+//
+//	GET    /state             the current state as JSON
+//	POST   /load              load a program and reset, the body is assembly
+//	                          source, or a memory image if the content type is
+//	                          application/octet-stream; a program using
+//	                          instructions the microcode does not implement is
+//	                          refused, keeping the loaded one
+//	POST   /run?hz=N          run until halt, breakpoint or stop, at N full clock
+//	                          cycles per second, as fast as possible if omitted
+//	POST   /stop              stop running
+//	POST   /step?n=N          execute N instructions, one if omitted
+//	POST   /micro             execute one micro instruction
+//	POST   /reset             reset the cpu
+//	GET    /memory            the memory as JSON, with the cell addressed by
+//	                          the memory address register and the cells
+//	                          written in the last 8 clock cycles marked
+//	GET    /outputs           the last 1024 values latched by the output
+//	                          register since the last load or reset as JSON,
+//	                          each with the clock cycle it was latched in
+//	GET    /diagram           the architecture as a Graphviz DOT graph, with
+//	                          the active data paths highlighted
+//	GET    /breakpoints       the breakpoint addresses as JSON
+//	POST   /breakpoints?addr=A    set a breakpoint
+//	DELETE /breakpoints?addr=A    clear a breakpoint
+//
+// Step and micro requests execute at most about a million half steps each and
+// fail with status 503 if they do not complete within them, after executing
+// as far as they got.
+//
+// Metrics in the Prometheus text format are served at /metrics: the achieved
+// clock frequency, the executed clock cycles, the executed instructions by
+// opcode and the number of connected WebSocket clients.
+//
+// A WebSocket connection to /ws receives the state as a JSON text message after
+// every full clock cycle and after every change made by a request. Messages are
+// dropped for clients that can not keep up.
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/oj-mik/eatersim"
+	"github.com/oj-mik/eatersim/assembler"
+)
+
+// recentWrites is the number of clock cycles a write is marked in the memory
+// view.
+const recentWrites = 8
+
+// capturedOutputs is the number of outputs kept for the outputs request.
+const capturedOutputs = 1024
+
+// requestBudget is the number of half steps a step or micro request executes
+// at most, so that each request completes in bounded time.
+const requestBudget = 1 << 20
+
+// clientBuffer is the number of messages queued for a WebSocket client before
+// messages are dropped.
+const clientBuffer = 256
+
+// Server serves a breadboard cpu over HTTP and WebSocket.
+type Server struct {
+	// mu guards the cpu and the fields below
+	mu      sync.Mutex
+	dbg     *eatersim.Debugger
+	stop    chan struct{} // closed to stop a running cpu, nil if not running
+	clients map[chan []byte]bool
+	metrics metrics
+
+	mux *http.ServeMux
+}
+
+// New creates a new server controlling cpu.
+func New(cpu *eatersim.BBCpu) *Server {
+	s := &Server{
+		dbg:     eatersim.NewDebugger(cpu),
+		clients: make(map[chan []byte]bool),
+		mux:     http.NewServeMux(),
+	}
+	if cpu.Writes == nil {
+		cpu.Writes = eatersim.NewWriteHistory(recentWrites)
+	}
+	if cpu.Outputs == nil {
+		cpu.Outputs = eatersim.NewOutputCapture(capturedOutputs)
+	}
+	s.mux.HandleFunc("/state", s.handleState)
+	s.mux.HandleFunc("/load", s.post(s.handleLoad))
+	s.mux.HandleFunc("/run", s.post(s.handleRun))
+	s.mux.HandleFunc("/stop", s.post(s.handleStop))
+	s.mux.HandleFunc("/step", s.post(s.handleStep))
+	s.mux.HandleFunc("/micro", s.post(s.handleMicro))
+	s.mux.HandleFunc("/reset", s.post(s.handleReset))
+	s.mux.HandleFunc("/memory", s.handleMemory)
+	s.mux.HandleFunc("/outputs", s.handleOutputs)
+	s.mux.HandleFunc("/diagram", s.handleDiagram)
+	s.mux.HandleFunc("/breakpoints", s.handleBreakpoints)
+	s.mux.HandleFunc("/ws", s.handleWS)
+	s.mux.HandleFunc("/metrics", s.handleMetrics)
+	return s
+}
+
+// Implements the http.Handler-interface
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// post restricts h to POST requests.
+func (s *Server) post(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	st := s.dbg.CPU.State()
+	s.mu.Unlock()
+	writeJSON(w, st)
+}
+
+func (s *Server) handleMemory(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	v := s.dbg.CPU.MemoryView()
+	s.mu.Unlock()
+	writeJSON(w, v)
+}
+
+func (s *Server) handleOutputs(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	outputs := s.dbg.CPU.Outputs.Outputs()
+	s.mu.Unlock()
+	if outputs == nil {
+		outputs = []eatersim.Output{}
+	}
+	writeJSON(w, outputs)
+}
+
+func (s *Server) handleDiagram(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	st := s.dbg.CPU.State()
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "text/vnd.graphviz")
+	eatersim.WriteDot(w, st)
+}
+
+func (s *Server) handleLoad(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, assembler.DefaultMaxSourceSize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var obj *assembler.Object
+	image := r.Header.Get("Content-Type") == "application/octet-stream"
+	if image {
+		if len(body) > len(eatersim.Mem{}.MEM) {
+			http.Error(w, fmt.Sprintf("image of %d bytes exceeds the memory of %d bytes", len(body), len(eatersim.Mem{}.MEM)), http.StatusRequestEntityTooLarge)
+			return
+		}
+		obj, err = imageObject(body)
+	} else {
+		if len(body) > assembler.DefaultMaxSourceSize {
+			http.Error(w, "source too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		obj, err = sourceObject(string(body))
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	bin := obj.Bin
+	if image {
+		bin = body
+	}
+
+	s.mu.Lock()
+	if errs := obj.CheckISA(s.dbg.CPU.ISA()); len(errs) > 0 {
+		s.mu.Unlock()
+		if image {
+			errs = addressErrors(obj, errs)
+		}
+		http.Error(w, joinErrors(errs), http.StatusUnprocessableEntity)
+		return
+	}
+	s.halt()
+	// keep the loaded program if the new one fails to verify
+	old := s.dbg.CPU.RAM.MEM
+	s.dbg.CPU.RAM.MEM = [0x10]byte{}
+	if err = s.dbg.Load(bin); err != nil {
+		s.dbg.CPU.RAM.MEM = old
+	} else {
+		s.dbg.CPU.Writes.Clear()
+		s.dbg.CPU.Outputs.Clear()
+	}
+	s.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	s.changed(w)
+}
+
+// sourceObject assembles the program src.
+func sourceObject(src string) (*assembler.Object, error) {
+	prog, err := assembler.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return assembler.AssembleObject(prog)
+}
+
+// imageObject disassembles the memory image bin and assembles it again, so
+// that its instructions can be checked like those of a source. Every byte is
+// taken for an instruction, as by the disassembler.
+func imageObject(bin []byte) (*assembler.Object, error) {
+	var b strings.Builder
+	if err := assembler.Disassemble(&b, bin, assembler.DisasmOptions{}); err != nil {
+		return nil, err
+	}
+	return sourceObject(b.String())
+}
+
+// addressErrors replaces the positions in the disassembly of an image by the
+// addresses of the instructions for the errors errs of obj.
+func addressErrors(obj *assembler.Object, errs []error) []error {
+	for i, err := range errs {
+		e, ok := err.(*assembler.Error)
+		if !ok {
+			continue
+		}
+		for addr, s := range obj.Owner {
+			if s != nil && s.Pos() == e.Pos {
+				errs[i] = fmt.Errorf("$%x: %s", addr, e.Msg)
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// joinErrors combines errs, e.g. of CheckISA, into one message, one error per
+// line.
+func joinErrors(errs []error) string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	var hz float64
+	if v := r.FormValue("hz"); v != "" {
+		var err error
+		if hz, err = strconv.ParseFloat(v, 64); err != nil {
+			http.Error(w, "invalid hz: "+v, http.StatusBadRequest)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		http.Error(w, "already running", http.StatusConflict)
+		return
+	}
+	s.stop = make(chan struct{})
+	s.metrics.startWindow(s.dbg.CPU.Cycles)
+	go s.run(hz, s.stop)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleStop(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.halt()
+	s.mu.Unlock()
+	s.changed(w)
+}
+
+func (s *Server) handleStep(w http.ResponseWriter, r *http.Request) {
+	n := 1
+	var err error
+	if v := r.FormValue("n"); v != "" {
+		if n, err = strconv.Atoi(v); err != nil || n < 1 {
+			http.Error(w, "invalid n: "+v, http.StatusBadRequest)
+			return
+		}
+	}
+	s.mu.Lock()
+	s.halt()
+	budget := requestBudget
+	for i := 0; i < n && !s.dbg.CPU.CL.HLT && err == nil; i++ {
+		err = s.instruction(&budget)
+	}
+	s.mu.Unlock()
+	s.changedErr(w, err)
+}
+
+func (s *Server) handleMicro(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.halt()
+	budget := requestBudget
+	err := s.micro(&budget)
+	s.mu.Unlock()
+	s.changedErr(w, err)
+}
+
+func (s *Server) handleReset(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.halt()
+	s.dbg.Reset()
+	s.dbg.CPU.Outputs.Clear()
+	s.mu.Unlock()
+	s.changed(w)
+}
+
+func (s *Server) handleBreakpoints(w http.ResponseWriter, r *http.Request) {
+	var set func(byte)
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		set = s.dbg.SetBreakpoint
+	case http.MethodDelete:
+		set = s.dbg.ClearBreakpoint
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if set != nil {
+		addr, err := strconv.ParseUint(r.FormValue("addr"), 0, 4)
+		if err != nil {
+			http.Error(w, "invalid addr: "+r.FormValue("addr"), http.StatusBadRequest)
+			return
+		}
+		set(byte(addr))
+	}
+	addrs := []int{}
+	for _, a := range s.dbg.Breakpoints() {
+		addrs = append(addrs, int(a))
+	}
+	writeJSON(w, addrs)
+}
+
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	c, err := upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer c.Close()
+
+	msgs := make(chan []byte, clientBuffer)
+	s.mu.Lock()
+	s.clients[msgs] = true
+	msgs <- s.stateMessage()
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, msgs)
+		s.mu.Unlock()
+	}()
+
+	// the client only sends control frames, which are handled here until
+	// the connection closes
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			op, payload, err := c.readFrame()
+			if err != nil {
+				return
+			}
+			switch op {
+			case wsPing:
+				c.writeFrame(wsPong, payload)
+			case wsClose:
+				c.writeFrame(wsClose, payload)
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case m := <-msgs:
+			if err := c.writeFrame(wsText, m); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// run executes the cpu cycle by cycle until it halts, reaches a breakpoint or
+// stop is closed.
+func (s *Server) run(hz float64, stop chan struct{}) {
+	var p *eatersim.Pacer
+	if hz > 0 {
+		s.mu.Lock()
+		s.dbg.CPU.Hz = hz
+		p = eatersim.NewPacer(hz, s.dbg.CPU.Cycles)
+		s.mu.Unlock()
+	}
+
+	for first := true; ; first = false {
+		s.mu.Lock()
+		select {
+		case <-stop:
+			s.mu.Unlock()
+			return
+		default:
+		}
+		cpu := s.dbg.CPU
+		// stepping leaves the clock high, complete the cycle so that each
+		// Step below starts on a low clock
+		if cpu.CLK.CLK {
+			s.exec()
+		}
+		// breakpoints stop before the instruction at the breakpoint is
+		// fetched, i.e. at the start of the first micro step
+		atStart := cpu.CL.Cnt == 0 && !cpu.CLK.CLK
+		if cpu.CL.HLT || (!first && atStart && s.dbg.StopsAt(s.dbg.PC())) {
+			s.halt()
+			s.mu.Unlock()
+			return
+		}
+		s.exec()
+		s.exec()
+		s.broadcast(s.stateMessage())
+		cycles := cpu.Cycles
+		s.metrics.tick(cycles)
+		s.mu.Unlock()
+
+		if p != nil {
+			p.Wait(cycles)
+		}
+	}
+}
+
+// halt stops a running cpu. Must be called with mu held.
+func (s *Server) halt() {
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+// changed broadcasts the state to all clients after a request changed it, and
+// responds with the state.
+func (s *Server) changed(w http.ResponseWriter) {
+	s.mu.Lock()
+	st := s.dbg.CPU.State()
+	s.broadcast(s.stateMessage())
+	s.mu.Unlock()
+	writeJSON(w, st)
+}
+
+// changedErr works like changed, but responds with err instead of the state
+// if a request could not complete within its budget.
+func (s *Server) changedErr(w http.ResponseWriter, err error) {
+	if err == nil {
+		s.changed(w)
+		return
+	}
+	s.mu.Lock()
+	s.broadcast(s.stateMessage())
+	s.mu.Unlock()
+	http.Error(w, err.Error(), http.StatusServiceUnavailable)
+}
+
+// stateMessage returns the current state as a JSON message. Must be called
+// with mu held.
+func (s *Server) stateMessage() []byte {
+	b, _ := json.Marshal(s.dbg.CPU.State())
+	return b
+}
+
+// broadcast queues m for all WebSocket clients, dropping it for clients whose
+// queue is full. Must be called with mu held.
+func (s *Server) broadcast(m []byte) {
+	for c := range s.clients {
+		select {
+		case c <- m:
+		default:
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
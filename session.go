@@ -0,0 +1,141 @@
+package eatersim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/oj-mik/eatersim/assembler"
+)
+
+// Session is a saved debug session: the state of the cpu together with the
+// breakpoints, snapshots and symbol table of a debugger, so that a long
+// investigation can be continued later on, see Debugger.Session.
+type Session struct {
+	// State is the state of the cpu
+	State State `json:"state"`
+
+	// Breakpoints holds the breakpoints at instructions
+	Breakpoints []SessionBreakpoint `json:"breakpoints,omitempty"`
+
+	// Signals holds the conditions of the signal breakpoints in order
+	Signals []string `json:"signal_breakpoints,omitempty"`
+
+	// Watches holds watch expressions of a front end, the debugger itself
+	// has none
+	Watches []string `json:"watches,omitempty"`
+
+	// Snapshots holds the named snapshots
+	Snapshots map[string]State `json:"snapshots,omitempty"`
+
+	// Program is the program loaded by LoadObject, nil if there is none
+	Program *SessionProgram `json:"program,omitempty"`
+}
+
+// SessionBreakpoint is a breakpoint of a saved debug session.
+type SessionBreakpoint struct {
+	Addr byte `json:"addr"`
+
+	// Cond is the condition of a conditional breakpoint, empty otherwise
+	Cond string `json:"cond,omitempty"`
+}
+
+// SessionProgram is the symbol table of the program of a saved debug session.
+// The source code is not saved, so a restored session annotates addresses
+// with labels only.
+type SessionProgram struct {
+	Bin     []byte          `json:"bin"`
+	Symbols map[string]byte `json:"symbols"`
+	Labels  []string        `json:"labels"`
+}
+
+// Session returns the current debug session. Watches is left empty.
+func (d *Debugger) Session() *Session {
+	s := &Session{State: d.CPU.State(), Snapshots: make(map[string]State)}
+	for _, addr := range d.Breakpoints() {
+		b := SessionBreakpoint{Addr: addr}
+		if cond := d.Condition(addr); cond != nil {
+			b.Cond = cond.String()
+		}
+		s.Breakpoints = append(s.Breakpoints, b)
+	}
+	for _, cond := range d.SignalBreakpoints() {
+		s.Signals = append(s.Signals, cond.String())
+	}
+	for name, snap := range d.snapshots {
+		s.Snapshots[name] = snap
+	}
+	if d.Program != nil {
+		s.Program = &SessionProgram{Bin: d.Program.Bin, Symbols: d.Program.Symbols, Labels: d.Program.Labels}
+	}
+	return s
+}
+
+// RestoreSession continues the debug session s, replacing the breakpoints,
+// snapshots and program of the debugger and the state of the cpu. The undo
+// history is cleared. Nothing is changed if a condition of s fails to parse.
+func (d *Debugger) RestoreSession(s *Session) error {
+	var prog *assembler.Object
+	var symbols map[string]byte
+	if s.Program != nil {
+		prog = &assembler.Object{Bin: s.Program.Bin, Symbols: s.Program.Symbols, Labels: s.Program.Labels}
+		symbols = prog.Symbols
+	}
+	conditions := make(map[byte]*Expr)
+	for _, b := range s.Breakpoints {
+		if b.Cond == "" {
+			continue
+		}
+		cond, err := ParseExpr(b.Cond, symbols)
+		if err != nil {
+			return fmt.Errorf("breakpoint at $%x: %s", b.Addr, err)
+		}
+		conditions[b.Addr&0x0f] = cond
+	}
+	var signals []*Expr
+	for _, src := range s.Signals {
+		cond, err := ParseExpr(src, symbols)
+		if err != nil {
+			return fmt.Errorf("signal breakpoint %s: %s", src, err)
+		}
+		signals = append(signals, cond)
+	}
+
+	d.CPU.Restore(s.State)
+	d.Program = prog
+	d.breakpoints = make(map[byte]bool)
+	for _, b := range s.Breakpoints {
+		d.breakpoints[b.Addr&0x0f] = true
+	}
+	d.conditions = conditions
+	d.signals = nil
+	for _, cond := range signals {
+		d.AddSignalBreakpoint(cond)
+	}
+	d.hit = nil
+	d.snapshots = make(map[string]State)
+	for name, snap := range s.Snapshots {
+		d.snapshots[name] = snap
+	}
+	d.undo, d.redo = nil, nil
+	return nil
+}
+
+// WriteTo writes the session to w as JSON.
+func (s *Session) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(data, '\n'))
+	return int64(n), err
+}
+
+// ReadSession reads a session written by Session.WriteTo.
+func ReadSession(r io.Reader) (*Session, error) {
+	s := new(Session)
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
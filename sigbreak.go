@@ -0,0 +1,82 @@
+package eatersim
+
+// signalBreak is a breakpoint on a condition of the signals, see
+// AddSignalBreakpoint.
+type signalBreak struct {
+	cond *Expr
+
+	// held tells whether the condition held after the last half step
+	held bool
+}
+
+// AddSignalBreakpoint adds a breakpoint on a condition of the signals and
+// registers, e.g. 'RI && MAR == $e' or 'SU'. Run stops at the half step in
+// which the condition starts to hold, in the middle of an instruction if
+// need be. A condition already holding when it is added triggers the next
+// time it starts to hold.
+func (d *Debugger) AddSignalBreakpoint(cond *Expr) {
+	d.signals = append(d.signals, &signalBreak{cond: cond, held: cond.True(d.CPU.State())})
+}
+
+// SignalBreakpoints returns the conditions of the signal breakpoints in the
+// order they were added.
+func (d *Debugger) SignalBreakpoints() []*Expr {
+	conds := make([]*Expr, len(d.signals))
+	for i, b := range d.signals {
+		conds[i] = b.cond
+	}
+	return conds
+}
+
+// RemoveSignalBreakpoint removes the signal breakpoint with the index i in
+// SignalBreakpoints.
+func (d *Debugger) RemoveSignalBreakpoint(i int) {
+	if i >= 0 && i < len(d.signals) {
+		d.signals = append(d.signals[:i], d.signals[i+1:]...)
+	}
+}
+
+// SignalHit returns the condition of the signal breakpoint Run last stopped
+// at, or nil.
+func (d *Debugger) SignalHit() *Expr {
+	return d.hit
+}
+
+// instruction executes the cpu until the current instruction is complete like
+// BBCpu.Instruction. With signal breakpoints it executes half step by half
+// step and stops early at the half step a signal breakpoint triggers. Returns
+// true in that case.
+func (d *Debugger) instruction() bool {
+	c := d.CPU
+	if len(d.signals) == 0 {
+		c.Instruction()
+		return false
+	}
+	if c.Halted() {
+		return false
+	}
+	for {
+		c.Exec()
+		if d.checkSignals() {
+			return true
+		}
+		if c.AtBoundary() || c.Halted() {
+			return false
+		}
+	}
+}
+
+// checkSignals evaluates the signal breakpoints after a half step and records
+// the first one starting to hold as the hit. Returns true if one did.
+func (d *Debugger) checkSignals() bool {
+	s := d.CPU.State()
+	d.hit = nil
+	for _, b := range d.signals {
+		held := b.cond.True(s)
+		if held && !b.held && d.hit == nil {
+			d.hit = b.cond
+		}
+		b.held = held
+	}
+	return d.hit != nil
+}
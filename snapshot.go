@@ -0,0 +1,85 @@
+package eatersim
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Change is a difference between two states of the cpu, see Diff.
+type Change struct {
+	// Name is the name of the register, flag or memory location, e.g. "A",
+	// "ZF" or "MEM[$e]"
+	Name string
+
+	// Old and New are the values in the first and second state
+	Old, New string
+}
+
+// Implements the Stringer-interface. Returns e.g. "A: $03 -> $09".
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Name, c.Old, c.New)
+}
+
+// Diff returns every difference between the states a and b: the clock cycles
+// and time, the clock, the bus and registers, the flags, the step counter,
+// the control word and the memory, in that order.
+func Diff(a, b State) []Change {
+	var d []Change
+	add := func(name, old, new string) {
+		if old != new {
+			d = append(d, Change{name, old, new})
+		}
+	}
+	hex := func(v byte) string { return fmt.Sprintf("$%02x", v) }
+	flag := func(v bool) string { return fmt.Sprint(b2byte(v)) }
+
+	add("CYCLES", fmt.Sprint(a.Cycles), fmt.Sprint(b.Cycles))
+	add("TIME", a.Time.String(), b.Time.String())
+	add("CLK", flag(a.CLK), flag(b.CLK))
+	add("BUS", hex(a.BUS), hex(b.BUS))
+	add("A", hex(a.A), hex(b.A))
+	add("B", hex(a.B), hex(b.B))
+	add("OUT", hex(a.Out), hex(b.Out))
+	add("IR", hex(a.IR), hex(b.IR))
+	add("MAR", hex(a.MAR), hex(b.MAR))
+	add("PC", hex(a.PC), hex(b.PC))
+	add("ALU", hex(a.ALU), hex(b.ALU))
+	add("CF", flag(a.CF), flag(b.CF))
+	add("ZF", flag(a.ZF), flag(b.ZF))
+	add("STEP", fmt.Sprint(a.Step), fmt.Sprint(b.Step))
+	add("CONTROL", a.Control.String(), b.Control.String())
+	for i := range a.MEM {
+		add(fmt.Sprintf("MEM[$%x]", i), hex(a.MEM[i]), hex(b.MEM[i]))
+	}
+	return d
+}
+
+// Snapshot saves the current state of the cpu under name, replacing an
+// earlier snapshot of the same name, and returns it.
+func (d *Debugger) Snapshot(name string) State {
+	s := d.CPU.State()
+	d.snapshots[name] = s
+	return s
+}
+
+// SnapshotNamed returns the snapshot saved under name, and whether there is
+// one.
+func (d *Debugger) SnapshotNamed(name string) (State, bool) {
+	s, ok := d.snapshots[name]
+	return s, ok
+}
+
+// DeleteSnapshot removes the snapshot saved under name.
+func (d *Debugger) DeleteSnapshot(name string) {
+	delete(d.snapshots, name)
+}
+
+// Snapshots returns the names of all snapshots in ascending order.
+func (d *Debugger) Snapshots() []string {
+	var names []string
+	for name := range d.snapshots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,197 @@
+package eatersim
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ControlWord holds the control signals of the control logic board as a bit
+// mask, in the order of the control word in Ben's build with HLT as the most
+// significant bit of it. The B register output, which Ben's build lacks, is
+// added above HLT.
+type ControlWord uint32
+
+// The control signals of the control word.
+const (
+	FI  ControlWord = 1 << iota // flags in
+	J                           // jump
+	CO                          // counter out
+	CE                          // counter enable
+	OI                          // output register in
+	BI                          // b register in
+	SU                          // subtract
+	EO                          // alu out
+	AO                          // a register out
+	AI                          // a register in
+	II                          // instruction register in
+	IO                          // instruction register out
+	RO                          // ram out
+	RI                          // ram in
+	MI                          // memory address register in
+	HLT                         // halt
+	BO                          // b register out
+)
+
+// numSignals is the number of control signals in a control word.
+const numSignals = len(controlNames)
+
+var controlNames = [...]string{"BO", "HLT", "MI", "RI", "RO", "IO", "II", "AI", "AO", "EO", "SU", "BI", "OI", "CE", "CO", "J", "FI"}
+
+// Implements the Stringer-interface. Returns the active signals separated by
+// '|', e.g. "CO|MI", or "none".
+func (w ControlWord) String() string {
+	var s []string
+	for i, name := range controlNames {
+		if w&(BO>>uint(i)) != 0 {
+			s = append(s, name)
+		}
+	}
+	if len(s) == 0 {
+		return "none"
+	}
+	return strings.Join(s, "|")
+}
+
+// ParseControlWord parses control signals separated by '|' as returned by
+// ControlWord.String, e.g. "CO|MI" or "none". The names are not case
+// sensitive.
+func ParseControlWord(s string) (ControlWord, error) {
+	var w ControlWord
+	if strings.EqualFold(s, "none") {
+		return 0, nil
+	}
+	for _, name := range strings.Split(s, "|") {
+		sig := controlByName(strings.ToUpper(strings.TrimSpace(name)))
+		if sig == 0 {
+			return 0, fmt.Errorf("unknown signal %s", name)
+		}
+		w |= sig
+	}
+	return w, nil
+}
+
+// Word returns the currently active control signals as a control word.
+func (c *Ctrl) Word() ControlWord {
+	var w ControlWord
+	for _, f := range []struct {
+		on  bool
+		sig ControlWord
+	}{
+		{c.BO, BO}, {c.HLT, HLT}, {c.MI, MI}, {c.RI, RI}, {c.RO, RO},
+		{c.IO, IO}, {c.II, II}, {c.AI, AI}, {c.AO, AO},
+		{c.EO, EO}, {c.SU, SU}, {c.BI, BI}, {c.OI, OI},
+		{c.CE, CE}, {c.CO, CO}, {c.J, J}, {c.FI, FI},
+	} {
+		if f.on {
+			w |= f.sig
+		}
+	}
+	return w
+}
+
+// setWord activates exactly the control signals in w.
+func (c *Ctrl) setWord(w ControlWord) {
+	for _, f := range []struct {
+		on  *bool
+		sig ControlWord
+	}{
+		{&c.BO, BO}, {&c.HLT, HLT}, {&c.MI, MI}, {&c.RI, RI}, {&c.RO, RO},
+		{&c.IO, IO}, {&c.II, II}, {&c.AI, AI}, {&c.AO, AO},
+		{&c.EO, EO}, {&c.SU, SU}, {&c.BI, BI}, {&c.OI, OI},
+		{&c.CE, CE}, {&c.CO, CO}, {&c.J, J}, {&c.FI, FI},
+	} {
+		*f.on = w&f.sig != 0
+	}
+}
+
+// State is a snapshot of the visible state of the breadboard cpu.
+type State struct {
+	// Cycles is the number of full clock cycles since the cpu was created
+	Cycles uint64 `json:"cycles"`
+
+	// Time is the simulated time since the cpu was created
+	Time time.Duration `json:"time_ns"`
+
+	// CLK is the clock signal
+	CLK bool `json:"clk"`
+
+	// BUS is the value on the data bus
+	BUS byte `json:"bus"`
+
+	// register buffers
+	A   byte `json:"a"`
+	B   byte `json:"b"`
+	Out byte `json:"out"`
+	IR  byte `json:"ir"`
+	MAR byte `json:"mar"`
+	PC  byte `json:"pc"`
+	ALU byte `json:"alu"`
+
+	// flags
+	CF bool `json:"cf"`
+	ZF bool `json:"zf"`
+
+	// Step is the micro instruction counter of the control logic
+	Step byte `json:"step"`
+
+	// Control holds the active control signals
+	Control ControlWord `json:"control"`
+
+	// MEM is the content of the memory
+	MEM [0x10]byte `json:"mem"`
+}
+
+// Restore puts the cpu into the state s as returned by State, e.g. to go on
+// with a saved debug session, so that the next half step continues from s.
+// The frequency, the switches of the memory and the options of the cpu are
+// left unchanged.
+func (c *BBCpu) Restore(s State) {
+	c.Cycles = s.Cycles
+	c.ns = float64(s.Time)
+	c.CLK.CLK = s.CLK
+	c.BUS = s.BUS
+	c.Areg.BUF, c.Breg.BUF, c.Oreg.BUF = s.A, s.B, s.Out
+	c.IR.BUF, c.MAR.BUF, c.PC.CNT = s.IR, s.MAR, s.PC
+	c.ALU.BUF, c.ALU.CF, c.ALU.ZF = s.ALU, s.CF, s.ZF
+	c.RAM.MEM = s.MEM
+
+	c.Ring.Cnt = s.Step
+	for i := range c.Ring.T {
+		c.Ring.T[i] = int(s.Step) == i
+	}
+	c.CL.Cnt = s.Step
+	c.CL.CLR, c.CL.clrrst = false, 0
+	c.CL.setWord(s.Control)
+
+	// the boards detect the clock edges from the previous clock signal
+	for _, prev := range []*bool{
+		&c.Ring.clkprev, &c.CL.clkprev, &c.Areg.clkprev, &c.Breg.clkprev,
+		&c.Oreg.clkprev, &c.ALU.clkprev, &c.MAR.clkprev, &c.RAM.clkprev,
+		&c.PC.clkprev, &c.IR.clkprev,
+	} {
+		*prev = s.CLK
+	}
+}
+
+// State returns a snapshot of the visible state of the cpu.
+func (c *BBCpu) State() State {
+	return State{
+		Cycles:  c.Cycles,
+		Time:    c.Time(),
+		CLK:     c.CLK.CLK,
+		BUS:     c.BUS,
+		A:       c.Areg.BUF,
+		B:       c.Breg.BUF,
+		Out:     c.Oreg.BUF,
+		IR:      c.IR.BUF,
+		MAR:     c.MAR.BUF,
+		PC:      c.PC.CNT,
+		ALU:     c.ALU.BUF,
+		CF:      c.ALU.CF,
+		ZF:      c.ALU.ZF,
+		Step:    c.CL.Cnt,
+		Control: c.CL.Word(),
+		MEM:     c.RAM.MEM,
+	}
+}
@@ -0,0 +1,79 @@
+package eatersim
+
+import "context"
+
+// Backpressure selects what StreamStates does with a state the receiver is
+// not ready for.
+type Backpressure int
+
+// The back-pressure policies of StreamStates.
+const (
+	// Block holds the cpu until the receiver takes the state, so that no
+	// state is lost
+	Block Backpressure = iota
+
+	// DropOldest keeps the cpu running and replaces a state not yet taken by
+	// the newer one, so that the receiver gets the latest state, e.g. to
+	// draw a user interface
+	DropOldest
+)
+
+// StreamStates runs the cpu on a new goroutine and sends its state every
+// every full clock cycles, and once more when the cpu halts. The channel is
+// closed when the cpu halts or ctx is done. bp selects what happens with
+// states the receiver is not ready for. The cpu must not be changed while it
+// streams, except through Pause and Resume.
+func (c *BBCpu) StreamStates(ctx context.Context, every int, bp Backpressure) <-chan State {
+	if every < 1 {
+		every = 1
+	}
+	ch := make(chan State, 1)
+	go func() {
+		defer close(ch)
+		c.setRunning(true)
+		defer c.setRunning(false)
+		start := c.Cycles
+		for !c.Halted() {
+			if ctx.Err() != nil {
+				return
+			}
+			c.checkPause()
+			c.Exec()
+			if c.Halted() || c.CLK.CLK || (c.Cycles-start)%uint64(every) != 0 {
+				continue
+			}
+			if !send(ctx, ch, c.State(), bp) {
+				return
+			}
+		}
+		send(ctx, ch, c.State(), Block)
+	}()
+	return ch
+}
+
+// send sends s to ch according to bp. Returns false if ctx is done.
+func send(ctx context.Context, ch chan State, s State, bp Backpressure) bool {
+	if bp == DropOldest {
+		for {
+			select {
+			case ch <- s:
+				return true
+			case <-ctx.Done():
+				return false
+			default:
+			}
+			// drop the state waiting in the channel, unless the receiver
+			// has just taken it
+			select {
+			case <-ch:
+			default:
+			}
+		}
+	}
+	select {
+	case ch <- s:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
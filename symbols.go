@@ -0,0 +1,76 @@
+package eatersim
+
+import (
+	"fmt"
+
+	"github.com/oj-mik/eatersim/assembler"
+)
+
+// LoadObject loads an assembled program like Load and keeps its symbol table,
+// so that addresses are annotated with the labels and statements of the
+// program, see Annotate, and breakpoints can be set by label.
+func (d *Debugger) LoadObject(obj *assembler.Object) error {
+	if err := d.Load(obj.Bin); err != nil {
+		return err
+	}
+	d.Program = obj
+	return nil
+}
+
+// Symbols returns the labels and symbols of the loaded program, or nil if it
+// was not loaded by LoadObject.
+func (d *Debugger) Symbols() map[string]byte {
+	if d.Program == nil {
+		return nil
+	}
+	return d.Program.Symbols
+}
+
+// Label returns the first label of the loaded program at addr, or an empty
+// string.
+func (d *Debugger) Label(addr byte) string {
+	if d.Program == nil {
+		return ""
+	}
+	for _, l := range d.Program.Labels {
+		if v, ok := d.Program.Symbols[l]; ok && v == addr&0x0f {
+			return l
+		}
+	}
+	return ""
+}
+
+// Annotate describes addr in terms of the loaded program, as the label at
+// addr followed by the statement that produced the byte there, e.g.
+// "adder (.byte 33)". The statement is left out once the byte in memory
+// differs from the assembled one. Returns an empty string if there is nothing
+// to say.
+func (d *Debugger) Annotate(addr byte) string {
+	addr &= 0x0f
+	if d.Program == nil {
+		return ""
+	}
+	label := d.Label(addr)
+	text := ""
+	if int(addr) < len(d.Program.Bin) && d.Program.Bin[addr] == d.Peek(addr) {
+		text = d.Program.StmtText(int(addr))
+	}
+	switch {
+	case label != "" && text != "":
+		return fmt.Sprintf("%s (%s)", label, text)
+	case label != "":
+		return label
+	}
+	return text
+}
+
+// SetBreakpointAt sets a breakpoint at the label called name of the loaded
+// program.
+func (d *Debugger) SetBreakpointAt(name string) error {
+	addr, ok := d.Symbols()[name]
+	if !ok {
+		return fmt.Errorf("unknown label %s", name)
+	}
+	d.SetBreakpoint(addr)
+	return nil
+}
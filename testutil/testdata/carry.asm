@@ -0,0 +1,14 @@
+; add until the carry flag is set, then output the sum past 255
+ lda start
+loop:
+ add step
+ jc  done
+ jmp loop
+done:
+ out
+ hlt
+
+start:
+ .byte 240
+step:
+ .byte 7
@@ -0,0 +1,234 @@
+// Package testutil provides helpers for tests of programs running on the
+// breadboard cpu: loading programs, recording a canonical execution trace and
+// comparing it against golden files.
+//
+// Golden files are rewritten with the actual output instead of compared when
+// the test binary runs with a boolean -update flag, which the test package
+// defines itself:
+//
+//	var _ = flag.Bool("update", false, "update golden files")
+//
+// and runs as
+//
+//	go test ./... -update
+package testutil
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oj-mik/eatersim"
+	"github.com/oj-mik/eatersim/assembler"
+)
+
+// Update makes Golden write the golden files instead of comparing them, like
+// the -update flag of the test binary.
+var Update bool
+
+// update tells whether to write the golden files, if Update is set or the
+// boolean -update flag is defined and set.
+func update() bool {
+	if Update {
+		return true
+	}
+	f := flag.Lookup("update")
+	if f == nil {
+		return false
+	}
+	g, ok := f.Value.(flag.Getter)
+	if !ok {
+		return false
+	}
+	b, _ := g.Get().(bool)
+	return b
+}
+
+// DefaultMaxCycles is the cycle limit of the helpers taking no limit.
+const DefaultMaxCycles = 10000
+
+// Load creates a new cpu with the program at path in its memory. Assembly
+// source (.asm) is assembled, any other file is loaded as a memory image.
+// Fails the test if the program can not be loaded.
+func Load(t testing.TB, path string) *eatersim.BBCpu {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := data
+	if strings.EqualFold(filepath.Ext(path), ".asm") {
+		prog, err := assembler.ParseFile(path, string(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj, err := assembler.AssembleObject(prog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bin = obj.Bin
+	}
+	cpu := eatersim.NewBBCpu()
+	if _, err := cpu.RAM.Write(bin); err != nil {
+		t.Fatalf("%s: %s", path, err)
+	}
+	return cpu
+}
+
+// errNoHalt is returned by Trace if the program did not halt in time.
+var errNoHalt = errors.New("program did not halt")
+
+// Trace runs cpu until it halts and returns its canonical trace, one line per
+// instruction with the address of the instruction, the instruction and the
+// registers after it, e.g.
+//
+//	$1 add 14   A=$05 B=$03 OUT=  0 CF=0 ZF=0
+//
+// An error is returned along with the trace so far if the cpu does not halt
+// within maxCycles full clock cycles.
+func Trace(cpu *eatersim.BBCpu, maxCycles uint64) (string, error) {
+	var b strings.Builder
+	start := cpu.Cycles
+	for !cpu.CL.HLT {
+		if cpu.Cycles-start >= maxCycles {
+			return b.String(), errNoHalt
+		}
+		addr := cpu.PC.CNT & 0x0f
+		cpu.Instruction()
+		fmt.Fprintln(&b, TraceLine(cpu, addr))
+	}
+	return b.String(), nil
+}
+
+// TraceLine describes the instruction just executed from addr and the
+// resulting registers of cpu, as a line of the canonical trace.
+func TraceLine(cpu *eatersim.BBCpu, addr byte) string {
+	instr := fmt.Sprintf(".byte $%02x", cpu.IR.BUF)
+	if name, arg, ok := assembler.Decode(cpu.IR.BUF); ok {
+		instr = strings.ToLower(name)
+		if _, operand, _ := assembler.Opcode(name); operand {
+			instr += fmt.Sprintf(" %d", arg)
+		}
+	}
+	return fmt.Sprintf("$%x %-9s A=$%02x B=$%02x OUT=%3d CF=%d ZF=%d",
+		addr, instr, cpu.Areg.BUF, cpu.Breg.BUF, cpu.Oreg.BUF, b2i(cpu.ALU.CF), b2i(cpu.ALU.ZF))
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// RunGolden loads the program at path, traces it until it halts and compares
+// the trace with the golden file of the same name with the extension
+// replaced by .golden.
+func RunGolden(t testing.TB, path string) {
+	t.Helper()
+	cpu := Load(t, path)
+	trace, err := Trace(cpu, DefaultMaxCycles)
+	if err != nil {
+		t.Errorf("%s: %s after %d cycles", path, err, DefaultMaxCycles)
+	}
+	Golden(t, strings.TrimSuffix(path, filepath.Ext(path))+".golden", trace)
+}
+
+// Golden compares got with the content of the golden file at path, and reports
+// a line by line diff if they differ. With -update, the golden file is
+// written with got instead.
+func Golden(t testing.TB, path, got string) {
+	t.Helper()
+	if update() {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%s, run with -update to create it", err)
+	}
+	if !bytes.Equal(want, []byte(got)) {
+		t.Errorf("%s differs, run with -update to accept:\n%s", path, Diff(string(want), got))
+	}
+}
+
+// Diff returns a line by line diff turning want into got. Removed lines are
+// prefixed with '-', added lines with '+' and unchanged lines with ' '. Runs of
+// unchanged lines are shortened to three lines of context around changes.
+func Diff(want, got string) string {
+	a := strings.SplitAfter(want, "\n")
+	b := strings.SplitAfter(got, "\n")
+
+	// longest common subsequence of lines, lcs[i][j] is its length for
+	// a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type line struct {
+		op   byte
+		text string
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', a[i]})
+			i, j = i+1, j+1
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, line{'+', b[j]})
+			j++
+		}
+	}
+
+	const context = 3
+	var s strings.Builder
+	elided := false
+	for k, l := range lines {
+		if l.op == ' ' {
+			near := false
+			for d := -context; d <= context && !near; d++ {
+				near = k+d >= 0 && k+d < len(lines) && lines[k+d].op != ' '
+			}
+			if !near {
+				if !elided {
+					s.WriteString("...\n")
+				}
+				elided = true
+				continue
+			}
+		}
+		elided = false
+		if l.text == "" {
+			continue
+		}
+		s.WriteByte(l.op)
+		s.WriteString(strings.TrimSuffix(l.text, "\n"))
+		s.WriteByte('\n')
+	}
+	return s.String()
+}
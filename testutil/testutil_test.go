@@ -0,0 +1,45 @@
+package testutil
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var _ = flag.Bool("update", false, "update golden files")
+
+func TestRunGolden(t *testing.T) {
+	RunGolden(t, "testdata/carry.asm")
+}
+
+func TestTraceNoHalt(t *testing.T) {
+	cpu := Load(t, "../programs/count.asm")
+	trace, err := Trace(cpu, 40)
+	if err != errNoHalt {
+		t.Errorf("error %v, want %v", err, errNoHalt)
+	}
+	Golden(t, "testdata/count.golden", trace)
+}
+
+func TestGoldenUpdate(t *testing.T) {
+	if update() {
+		t.Skip("writes its own golden file with -update")
+	}
+	path := filepath.Join(t.TempDir(), "update.golden")
+	if err := flag.Set("update", "true"); err != nil {
+		t.Fatal(err)
+	}
+	Golden(t, path, "$0 hlt\n")
+	if err := flag.Set("update", "false"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "$0 hlt\n" {
+		t.Errorf("golden file %q, want %q", got, "$0 hlt\n")
+	}
+	Golden(t, path, "$0 hlt\n")
+}
@@ -0,0 +1,67 @@
+package eatersim
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// ClockTiming models the timing of the astable 555 timer driving the clock
+// board: an asymmetric duty cycle, as the timer charges through both of its
+// resistors but discharges through one only, and jitter of each phase. It is
+// off by default, the clock then toggles in phases of equal length. Set
+// Clk.Timing to enable it, the clock then tracks the length of its phases,
+// and the duty cycles of the LEDs are weighted by them.
+//
+// The lengths are measured in nominal clock periods, so that the timing holds
+// at any clock frequency. The jitter is drawn from a seeded source, a run is
+// reproducible given the seed.
+type ClockTiming struct {
+	// Duty is the fraction of the period the clock is high, 0.5 for a
+	// symmetric clock
+	Duty float64
+
+	// Jitter is the maximum deviation of the length of a phase from its
+	// nominal length, as a fraction of the nominal length
+	Jitter float64
+
+	rand *rand.Rand
+}
+
+// NewClockTiming creates a clock timing with the duty cycle duty, between 0
+// and 1 exclusive, and the jitter jitter, from 0 up to 1 exclusive, drawing
+// the jitter from a source seeded with seed.
+func NewClockTiming(duty, jitter float64, seed int64) (*ClockTiming, error) {
+	if duty <= 0 || duty >= 1 {
+		return nil, fmt.Errorf("clock duty cycle %v out of range, expecting a value between 0 and 1", duty)
+	}
+	if jitter < 0 || jitter >= 1 {
+		return nil, fmt.Errorf("clock jitter %v out of range, expecting a value from 0 up to 1", jitter)
+	}
+	return &ClockTiming{Duty: duty, Jitter: jitter, rand: rand.New(rand.NewSource(seed))}, nil
+}
+
+// phase returns the length of the next phase of the clock in nominal clock
+// periods, the high phase if high is true.
+func (t *ClockTiming) phase(high bool) float64 {
+	w := t.Duty
+	if !high {
+		w = 1 - w
+	}
+	if t.Jitter > 0 && t.rand != nil {
+		w *= 1 + t.Jitter*(2*t.rand.Float64()-1)
+	}
+	return w
+}
+
+// WithClockTiming makes the clock of the cpu run with an asymmetric duty
+// cycle and jitter, see ClockTiming.
+func WithClockTiming(duty, jitter float64, seed int64) Option {
+	return func(c *BBCpu) error {
+		t, err := NewClockTiming(duty, jitter, seed)
+		if err != nil {
+			return err
+		}
+		c.CLK.Timing = t
+		return nil
+	}
+}
@@ -0,0 +1,100 @@
+package eatersim
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// traceBoards tells for each board a TraceFilter can select whether its state
+// differs between two states, named like the boards of BBCpu.Circuit.
+var traceBoards = map[string]func(a, b State) bool{
+	"clk":  func(a, b State) bool { return a.CLK != b.CLK },
+	"ring": func(a, b State) bool { return a.Step != b.Step },
+	"cl":   func(a, b State) bool { return a.Control != b.Control },
+	"bus":  func(a, b State) bool { return a.BUS != b.BUS },
+	"areg": func(a, b State) bool { return a.A != b.A },
+	"breg": func(a, b State) bool { return a.B != b.B },
+	"oreg": func(a, b State) bool { return a.Out != b.Out },
+	"alu":  func(a, b State) bool { return a.ALU != b.ALU || a.CF != b.CF || a.ZF != b.ZF },
+	"mar":  func(a, b State) bool { return a.MAR != b.MAR },
+	"ram":  func(a, b State) bool { return a.MEM != b.MEM },
+	"pc":   func(a, b State) bool { return a.PC != b.PC },
+	"ir":   func(a, b State) bool { return a.IR != b.IR },
+}
+
+// ParseTraceBoards parses a comma separated list of board names for
+// TraceFilter.Boards, e.g. "areg,oreg". The names are those of the boards of
+// BBCpu.Circuit and bus for the bus, they are not case sensitive.
+func ParseTraceBoards(s string) ([]string, error) {
+	var boards []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := traceBoards[name]; !ok {
+			names := make([]string, 0, len(traceBoards))
+			for n := range traceBoards {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown board %s, expecting %s", name, strings.Join(names, ", "))
+		}
+		boards = append(boards, name)
+	}
+	return boards, nil
+}
+
+// TraceFilter selects the records of a trace, so that traces of long runs stay
+// small enough to be useful. A trace records a state after every half step
+// or instruction, and asks Keep whether to write it. The zero value keeps all
+// records.
+type TraceFilter struct {
+	// Boards keeps only records in which the state of one of the boards
+	// changed, see ParseTraceBoards. Empty keeps records regardless.
+	Boards []string
+
+	// Signals keeps only records in which one of the control signals is
+	// active. 0 keeps records regardless.
+	Signals ControlWord
+
+	// Instructions keeps only records at the end of an instruction, the
+	// rising clock edge of its last step, or when the cpu halts. It selects
+	// the instructions from a trace of half steps.
+	Instructions bool
+
+	// Every samples the records passing the other conditions, keeping the
+	// first and then every Every-th. 0 and 1 keep all of them.
+	Every int
+
+	// passed counts the records passing the other conditions
+	passed int
+}
+
+// Keep tells whether to record the state after, given the state before it was
+// recorded, e.g. the state before the half step or instruction.
+func (f *TraceFilter) Keep(before, after State) bool {
+	if f.Instructions && !after.AtBoundary() && after.Control&HLT == 0 {
+		return false
+	}
+	if f.Signals != 0 && after.Control&f.Signals == 0 {
+		return false
+	}
+	if len(f.Boards) > 0 {
+		changed := false
+		for _, name := range f.Boards {
+			if differ := traceBoards[strings.ToLower(name)]; differ != nil && differ(before, after) {
+				changed = true
+				break
+			}
+		}
+		if !changed {
+			return false
+		}
+	}
+	f.passed++
+	return f.Every <= 1 || (f.passed-1)%f.Every == 0
+}
+
+// Reset restarts the sampling of Every.
+func (f *TraceFilter) Reset() {
+	f.passed = 0
+}
@@ -0,0 +1,294 @@
+package eatersim
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TraceEvent is a record of a trace: the state of the cpu after a half step.
+type TraceEvent struct {
+	// Addr is the address the instruction executing was fetched from
+	Addr byte `json:"addr"`
+
+	// Instruction marks the event ending the instruction, on the rising
+	// clock edge of its last step or when the cpu halts
+	Instruction bool `json:"instruction"`
+
+	State
+}
+
+// TraceSink is where a Tracer writes its events, e.g. a file in one of the
+// formats of the sinks provided, a database or a socket.
+type TraceSink interface {
+	// Write records the event e
+	Write(e TraceEvent) error
+}
+
+// Tracer writes the states of a cpu to a sink, so that one tracer serves all
+// formats. Set BBCpu.Trace to enable it, the cpu then feeds it its state after
+// every half step.
+type Tracer struct {
+	// Sink receives the events
+	Sink TraceSink
+
+	// Filter selects the events written, all if nil
+	Filter *TraceFilter
+
+	// Err is the first error returned by the sink, no events are written
+	// after it
+	Err error
+
+	prev State
+	addr byte
+	seen bool
+}
+
+// NewTracer creates a tracer writing all events to sink.
+func NewTracer(sink TraceSink) *Tracer {
+	return &Tracer{Sink: sink}
+}
+
+// Observe writes the event of the state s if the filter keeps it.
+func (t *Tracer) Observe(s State) {
+	if t.Err != nil {
+		return
+	}
+	prev := t.prev
+	if !t.seen {
+		prev, t.addr, t.seen = s, s.PC, true
+	}
+	t.prev = s
+	e := TraceEvent{Addr: t.addr, State: s}
+	e.Instruction = s.AtBoundary() || s.Control&HLT != 0
+	if e.Instruction {
+		t.addr = s.PC
+	}
+	if t.Filter != nil && !t.Filter.Keep(prev, s) {
+		return
+	}
+	t.Err = t.Sink.Write(e)
+}
+
+// Flush flushes the output of the sink if it has a Flush method, like the CSV
+// sink buffering its output, and returns the first error of the sink.
+func (t *Tracer) Flush() error {
+	if f, ok := t.Sink.(interface{ Flush() error }); ok && t.Err == nil {
+		t.Err = f.Flush()
+	}
+	return t.Err
+}
+
+// JSONLSink writes events as JSON lines, an object per event.
+type JSONLSink struct {
+	enc *json.Encoder
+}
+
+// NewJSONLSink creates a sink writing JSON lines to w.
+func NewJSONLSink(w io.Writer) *JSONLSink {
+	return &JSONLSink{json.NewEncoder(w)}
+}
+
+// Write writes e as a line.
+func (s *JSONLSink) Write(e TraceEvent) error {
+	return s.enc.Encode(e)
+}
+
+// CSVSink writes events as CSV, a header row naming the columns followed by a
+// row per event. Call Flush when done.
+type CSVSink struct {
+	w      *csv.Writer
+	header bool
+}
+
+// NewCSVSink creates a sink writing CSV to w.
+func NewCSVSink(w io.Writer) *CSVSink {
+	return &CSVSink{w: csv.NewWriter(w)}
+}
+
+// Write writes e as a row.
+func (s *CSVSink) Write(e TraceEvent) error {
+	if !s.header {
+		s.header = true
+		if err := s.w.Write([]string{"cycles", "time_ns", "addr", "instruction", "clk", "bus", "a", "b", "out", "ir", "mar", "pc", "alu", "cf", "zf", "step", "control"}); err != nil {
+			return err
+		}
+	}
+	u := func(v byte) string { return strconv.Itoa(int(v)) }
+	b := func(v bool) string { return strconv.Itoa(int(b2u(v))) }
+	return s.w.Write([]string{
+		strconv.FormatUint(e.Cycles, 10), strconv.FormatInt(e.Time.Nanoseconds(), 10),
+		u(e.Addr), b(e.Instruction), b(e.CLK), u(e.BUS), u(e.A), u(e.B), u(e.Out),
+		u(e.IR), u(e.MAR), u(e.PC), u(e.ALU), b(e.CF), b(e.ZF), u(e.Step), e.Control.String(),
+	})
+}
+
+// Flush writes the buffered rows.
+func (s *CSVSink) Flush() error {
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// TraceBuffer keeps events in memory, e.g. to examine them in tests.
+type TraceBuffer struct {
+	// Max is the number of events kept, the oldest are discarded beyond it.
+	// 0 keeps all events.
+	Max int
+
+	// Events holds the events kept, oldest first
+	Events []TraceEvent
+}
+
+// Write appends e.
+func (b *TraceBuffer) Write(e TraceEvent) error {
+	b.Events = append(b.Events, e)
+	if b.Max > 0 && len(b.Events) > b.Max {
+		b.Events = append(b.Events[:0], b.Events[len(b.Events)-b.Max:]...)
+	}
+	return nil
+}
+
+// VCDSink writes events as a Value Change Dump for waveform viewers like
+// GTKWave: the clock, the bus, the registers, the flags, the step counter,
+// the control word and each of its control lines, and the memory cells. The
+// time unit is a half clock period, the dump starts with the first event.
+type VCDSink struct {
+	w     io.Writer
+	err   error
+	vars  []vcdVar
+	last  []uint64
+	start uint64
+	begun bool
+}
+
+// vcdVar is a variable of a VCD dump, its value taken from an event. Variables
+// with a scope are grouped in a scope of that name.
+type vcdVar struct {
+	scope string
+	name  string
+	width int
+	value func(e TraceEvent) uint64
+}
+
+// vcdVars returns the variables dumped by a VCDSink.
+func vcdVars() []vcdVar {
+	vars := append([]vcdVar(nil), vcdRegisters...)
+	vars = append(vars, vcdVar{"control", "word", numSignals, func(e TraceEvent) uint64 { return uint64(e.Control) }})
+	for i, name := range controlNames {
+		sig := BO >> uint(i)
+		vars = append(vars, vcdVar{"control", strings.ToLower(name), 1, func(e TraceEvent) uint64 {
+			return b2u(e.Control&sig != 0)
+		}})
+	}
+	for addr := 0; addr < len(State{}.MEM); addr++ {
+		addr := addr
+		vars = append(vars, vcdVar{"ram", fmt.Sprintf("mem%x", addr), 8, func(e TraceEvent) uint64 {
+			return uint64(e.MEM[addr])
+		}})
+	}
+	return vars
+}
+
+// vcdRegisters are the variables of the top scope dumped by a VCDSink.
+var vcdRegisters = []vcdVar{
+	{"", "clk", 1, func(e TraceEvent) uint64 { return b2u(e.CLK) }},
+	{"", "bus", 8, func(e TraceEvent) uint64 { return uint64(e.BUS) }},
+	{"", "a", 8, func(e TraceEvent) uint64 { return uint64(e.A) }},
+	{"", "b", 8, func(e TraceEvent) uint64 { return uint64(e.B) }},
+	{"", "alu", 8, func(e TraceEvent) uint64 { return uint64(e.ALU) }},
+	{"", "out", 8, func(e TraceEvent) uint64 { return uint64(e.Out) }},
+	{"", "ir", 8, func(e TraceEvent) uint64 { return uint64(e.IR) }},
+	{"", "mar", 4, func(e TraceEvent) uint64 { return uint64(e.MAR) }},
+	{"", "pc", 4, func(e TraceEvent) uint64 { return uint64(e.PC) }},
+	{"", "cf", 1, func(e TraceEvent) uint64 { return b2u(e.CF) }},
+	{"", "zf", 1, func(e TraceEvent) uint64 { return b2u(e.ZF) }},
+	{"", "step", 3, func(e TraceEvent) uint64 { return uint64(e.Step) }},
+}
+
+// NewVCDSink creates a sink writing a VCD to w.
+func NewVCDSink(w io.Writer) *VCDSink {
+	return &VCDSink{w: w, vars: vcdVars()}
+}
+
+// vcdID returns the identifier code of the i-th variable.
+func vcdID(i int) string {
+	const first, n = '!', '~' - '!' + 1
+	id := string(rune(first + i%n))
+	for i /= n; i > 0; i /= n {
+		id += string(rune(first + i%n))
+	}
+	return id
+}
+
+// printf writes to the output unless writing failed before.
+func (s *VCDSink) printf(format string, a ...interface{}) {
+	if s.err == nil {
+		_, s.err = fmt.Fprintf(s.w, format, a...)
+	}
+}
+
+// Write writes the values of the variables changed since the previous event.
+func (s *VCDSink) Write(e TraceEvent) error {
+	// the half steps since the first event, the rising clock edge counts
+	// the cycle
+	t := 2 * e.Cycles
+	if e.CLK {
+		t--
+	}
+	if !s.begun {
+		s.begun, s.start = true, t
+		s.printf("$version eatersim $end\n$comment one time unit is a half clock period $end\n$timescale 1 ns $end\n")
+		s.printf("$scope module cpu $end\n")
+		scope := ""
+		for i, v := range s.vars {
+			if v.scope != scope {
+				if scope != "" {
+					s.printf("$upscope $end\n")
+				}
+				if scope = v.scope; scope != "" {
+					s.printf("$scope module %s $end\n", scope)
+				}
+			}
+			s.printf("$var wire %d %s %s $end\n", v.width, vcdID(i), v.name)
+		}
+		if scope != "" {
+			s.printf("$upscope $end\n")
+		}
+		s.printf("$upscope $end\n$enddefinitions $end\n")
+	}
+	changed := false
+	for i, v := range s.vars {
+		val := v.value(e)
+		if s.last != nil && s.last[i] == val {
+			continue
+		}
+		if !changed {
+			changed = true
+			if t < s.start {
+				t = s.start
+			}
+			s.printf("#%d\n", t-s.start)
+		}
+		if v.width == 1 {
+			s.printf("%d%s\n", val, vcdID(i))
+		} else {
+			s.printf("b%b %s\n", val, vcdID(i))
+		}
+	}
+	if s.last == nil {
+		s.last = make([]uint64, len(s.vars))
+	}
+	for i, v := range s.vars {
+		s.last[i] = v.value(e)
+	}
+	return s.err
+}
+
+// Flush returns the first error writing the output. The VCD sink does not
+// buffer, it writes through to the writer.
+func (s *VCDSink) Flush() error {
+	return s.err
+}
@@ -0,0 +1,134 @@
+package eatersim
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Trigger fires when the bus matches a pattern while some control signals
+// are active, like the trigger of a logic analyzer. It matches a state if
+// BUS&Mask == Value&Mask and all signals in Control are active. Triggers in
+// BBCpu.Triggers are checked after every half step, and call Func on the half
+// step they start to match. For a breakpoint, see Expr.
+type Trigger struct {
+	// Value is the pattern on the bus, Mask selects the bus lines it
+	// applies to
+	Value, Mask byte
+
+	// Control holds the signals which must be active, none for any
+	Control ControlWord
+
+	// Func is called with the state when the trigger fires, may be nil
+	Func func(s State)
+
+	// matched tells whether the trigger matched after the last half step
+	matched bool
+}
+
+// ParseTrigger parses a trigger given as a bus pattern, optionally followed
+// by the signals which must be active separated by '|', e.g. '$ff',
+// '%1111xxxx RO' or '$x0 AO|OI'. Patterns are decimal, $hexadecimal or
+// %binary numbers, in which the digit x marks don't care bus lines.
+func ParseTrigger(s string) (*Trigger, error) {
+	f := strings.Fields(s)
+	if len(f) == 0 || len(f) > 2 {
+		return nil, fmt.Errorf("invalid trigger %q, expecting a bus pattern and signals", s)
+	}
+	t := new(Trigger)
+	var err error
+	if t.Value, t.Mask, err = parseBusPattern(f[0]); err != nil {
+		return nil, err
+	}
+	if len(f) == 2 {
+		if t.Control, err = ParseControlWord(f[1]); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// parseBusPattern parses a bus pattern as described in ParseTrigger.
+func parseBusPattern(s string) (value, mask byte, err error) {
+	bits, digits := 0, s
+	switch {
+	case strings.HasPrefix(s, "$"):
+		bits, digits = 4, s[1:]
+	case strings.HasPrefix(s, "%"):
+		bits, digits = 1, s[1:]
+	default:
+		v, err := strconv.ParseUint(s, 10, 8)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid bus pattern %s", s)
+		}
+		return byte(v), 0xff, nil
+	}
+	if digits == "" || len(digits)*bits > 8 {
+		return 0, 0, fmt.Errorf("invalid bus pattern %s", s)
+	}
+	for _, d := range digits {
+		value <<= bits
+		mask <<= bits
+		if d == 'x' || d == 'X' {
+			continue
+		}
+		v, err := strconv.ParseUint(string(d), 1<<bits, 8)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid bus pattern %s", s)
+		}
+		value |= byte(v)
+		mask |= 1<<bits - 1
+	}
+	// the bus lines above the digits given must be 0
+	mask |= byte(0xff) << (len(digits) * bits)
+	return value, mask, nil
+}
+
+// Match tells whether the trigger matches the state s.
+func (t *Trigger) Match(s State) bool {
+	return s.BUS&t.Mask == t.Value&t.Mask && s.Control&t.Control == t.Control
+}
+
+// Expr returns the trigger as a watch expression, e.g. to stop at it with
+// Debugger.AddSignalBreakpoint.
+func (t *Trigger) Expr() *Expr {
+	return &Expr{src: t.String(), eval: func(s *State) int {
+		if t.Match(*s) {
+			return 1
+		}
+		return 0
+	}}
+}
+
+// Implements the Stringer-interface. Returns the trigger in the syntax of
+// ParseTrigger with the bus pattern in binary.
+func (t *Trigger) String() string {
+	var b strings.Builder
+	b.WriteByte('%')
+	for i := 7; i >= 0; i-- {
+		switch {
+		case t.Mask&(1<<i) == 0:
+			b.WriteByte('x')
+		case t.Value&(1<<i) != 0:
+			b.WriteByte('1')
+		default:
+			b.WriteByte('0')
+		}
+	}
+	if t.Control != 0 {
+		b.WriteString(" " + t.Control.String())
+	}
+	return b.String()
+}
+
+// checkTriggers calls the triggers starting to match after a half step.
+func (c *BBCpu) checkTriggers() {
+	s := c.State()
+	for _, t := range c.Triggers {
+		m := t.Match(s)
+		if m && !t.matched && t.Func != nil {
+			t.Func(s)
+		}
+		t.matched = m
+	}
+}
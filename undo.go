@@ -0,0 +1,101 @@
+package eatersim
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// mutation is a manual change of the cpu made through the debugger, see Poke
+// and SetRegister.
+type mutation struct {
+	name     string
+	old, new byte
+	set      func(v byte)
+}
+
+func (m mutation) change() Change {
+	return Change{m.name, fmt.Sprintf("$%02x", m.old), fmt.Sprintf("$%02x", m.new)}
+}
+
+// mutate applies and records a manual change, discarding the changes undone
+// so far.
+func (d *Debugger) mutate(name string, old, new byte, set func(v byte)) {
+	set(new)
+	d.undo = append(d.undo, mutation{name, old, new, set})
+	d.redo = nil
+}
+
+// registerSetter returns the function setting the register called name and
+// its canonical name, or nil.
+func (d *Debugger) registerSetter(name string) (func(v byte), string) {
+	c := d.CPU
+	switch strings.ToUpper(name) {
+	case "A", "AREG":
+		return func(v byte) { c.Areg.BUF = v }, "A"
+	case "B", "BREG":
+		return func(v byte) { c.Breg.BUF = v }, "B"
+	case "OUT", "OREG":
+		return func(v byte) { c.Oreg.BUF = v }, "OUT"
+	case "IR":
+		return func(v byte) { c.IR.BUF = v }, "IR"
+	case "MAR":
+		return func(v byte) { c.MAR.BUF = v & 0x0f }, "MAR"
+	case "PC":
+		return func(v byte) { c.PC.CNT = v & 0x0f }, "PC"
+	}
+	return nil, ""
+}
+
+// SetRegister sets the register called name, one of A, B, OUT, IR, MAR and PC,
+// to v. The change can be undone, see Undo.
+func (d *Debugger) SetRegister(name string, v byte) error {
+	set, reg := d.registerSetter(name)
+	if set == nil {
+		return fmt.Errorf("unknown register %s", name)
+	}
+	var old byte
+	switch s := d.CPU.State(); reg {
+	case "A":
+		old = s.A
+	case "B":
+		old = s.B
+	case "OUT":
+		old = s.Out
+	case "IR":
+		old = s.IR
+	case "MAR":
+		old, v = s.MAR, v&0x0f
+	case "PC":
+		old, v = s.PC, v&0x0f
+	}
+	d.mutate(reg, old, v, set)
+	return nil
+}
+
+// Undo reverts the last manual change made by Poke or SetRegister that was
+// not undone yet, regardless of the instructions executed since, and returns
+// it.
+func (d *Debugger) Undo() (Change, error) {
+	if len(d.undo) == 0 {
+		return Change{}, errors.New("nothing to undo")
+	}
+	m := d.undo[len(d.undo)-1]
+	d.undo = d.undo[:len(d.undo)-1]
+	m.set(m.old)
+	d.redo = append(d.redo, m)
+	return m.change(), nil
+}
+
+// Redo applies the last change reverted by Undo again and returns it. Any new
+// change made by Poke or SetRegister discards the changes left to redo.
+func (d *Debugger) Redo() (Change, error) {
+	if len(d.redo) == 0 {
+		return Change{}, errors.New("nothing to redo")
+	}
+	m := d.redo[len(d.redo)-1]
+	d.redo = d.redo[:len(d.redo)-1]
+	m.set(m.new)
+	d.undo = append(d.undo, m)
+	return m.change(), nil
+}
@@ -0,0 +1,43 @@
+package eatersim
+
+import "fmt"
+
+// VerifyError is a difference between the memory and the image loaded into it.
+type VerifyError struct {
+	// Addr is the address of the difference
+	Addr byte
+
+	// Want is the byte of the image, Got the byte read back from memory
+	Want, Got byte
+}
+
+// Implements the error-interface
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("verify failed at $%x: expected $%02x, read $%02x", e.Addr, e.Want, e.Got)
+}
+
+// Verify reads the memory back and compares it with image, loaded at address
+// 0 like Write does, as EEPROM programmers verify after programming. Returns a
+// *VerifyError for the first difference, or an error if the image is larger
+// than the memory.
+func (m *Mem) Verify(image []byte) error {
+	if len(image) > len(m.MEM) {
+		return fmt.Errorf("image of %d bytes exceeds the memory of %d bytes", len(image), len(m.MEM))
+	}
+	for i, v := range image {
+		if m.MEM[i] != v {
+			return &VerifyError{Addr: byte(i), Want: v, Got: m.MEM[i]}
+		}
+	}
+	return nil
+}
+
+// Checksum returns the sum of all bytes of the memory, the checksum EEPROM
+// programmers show for an image.
+func (m *Mem) Checksum() uint16 {
+	var sum uint16
+	for _, v := range m.MEM {
+		sum += uint16(v)
+	}
+	return sum
+}
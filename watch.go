@@ -0,0 +1,323 @@
+package eatersim
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr is a watch expression over the state of the cpu, e.g. 'A + B',
+// 'MEM[14]' or 'PC == loop'. The same expressions are used by watch windows,
+// conditional breakpoints and assertions.
+//
+// Operands are numbers (decimal, $hexadecimal or %binary), registers,
+// flags, control signals, memory locations MEM[addr] and symbols. The names
+// of registers, flags and control signals are not case sensitive:
+//
+//	A, AREG, B, BREG, OUT, OREG, IR, MAR, PC, ALU, BUS  registers and the bus
+//	STEP, CYCLES                   micro instruction step and clock cycles
+//	CLK, CF, ZF                    clock and flags, 1 if set and 0 otherwise
+//	HLT, MI, RI, RO, ...           control signals, 1 if active
+//
+// Other names are looked up in the symbol table passed to ParseExpr, e.g. the
+// labels and symbols of an assembled program.
+//
+// The operators, from lowest to highest precedence, are || and &&, the
+// comparisons == != < <= > >=, + - | ^, * / & << >>, and the unary - ! ~.
+// Values are integers, comparisons and logical operators yield 1 for true
+// and 0 for false.
+type Expr struct {
+	src  string
+	eval func(s *State) int
+}
+
+// ParseExpr parses a watch expression, resolving names not denoting parts of
+// the cpu in symbols. symbols may be nil.
+func ParseExpr(src string, symbols map[string]byte) (*Expr, error) {
+	toks, err := exprTokens(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks, symbols: symbols}
+	eval, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected %s", p.toks[p.pos])
+	}
+	return &Expr{src: src, eval: eval}, nil
+}
+
+// Eval evaluates the expression for the state s.
+func (e *Expr) Eval(s State) int {
+	return e.eval(&s)
+}
+
+// True tells whether the expression evaluates to a value other than 0.
+func (e *Expr) True(s State) bool {
+	return e.eval(&s) != 0
+}
+
+// Implements the Stringer-interface. Returns the source of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// exprTokens splits an expression into numbers, names and operators.
+func exprTokens(src string) ([]string, error) {
+	var toks []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+			continue
+		case c == '$' || c == '%' || unicode.IsDigit(rune(c)) || unicode.IsLetter(rune(c)) || c == '_':
+			j := i + 1
+			for j < len(src) && (unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j])) || src[j] == '_') {
+				j++
+			}
+			toks = append(toks, src[i:j])
+			i = j
+			continue
+		}
+		if i+1 < len(src) {
+			switch op := src[i : i+2]; op {
+			case "||", "&&", "==", "!=", "<=", ">=", "<<", ">>":
+				toks = append(toks, op)
+				i += 2
+				continue
+			}
+		}
+		if !strings.ContainsRune("<>+-|^*/&!~()[]", rune(c)) {
+			return nil, fmt.Errorf("unexpected character %q", c)
+		}
+		toks = append(toks, string(c))
+		i++
+	}
+	return toks, nil
+}
+
+type exprParser struct {
+	toks    []string
+	pos     int
+	symbols map[string]byte
+}
+
+// binaryLevels holds the binary operators by increasing precedence.
+var binaryLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-", "|", "^"},
+	{"*", "/", "&", "<<", ">>"},
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+// binary parses a sequence of operands joined by the operators of the given
+// precedence level or higher.
+func (p *exprParser) binary(level int) (func(*State) int, error) {
+	if level == len(binaryLevels) {
+		return p.unary()
+	}
+	x, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		found := false
+		for _, o := range binaryLevels[level] {
+			found = found || o == op
+		}
+		if !found {
+			return x, nil
+		}
+		p.pos++
+		y, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = binaryOp(op, x, y)
+	}
+}
+
+func binaryOp(op string, x, y func(*State) int) func(*State) int {
+	b := func(v bool) int {
+		if v {
+			return 1
+		}
+		return 0
+	}
+	switch op {
+	case "||":
+		return func(s *State) int { return b(x(s) != 0 || y(s) != 0) }
+	case "&&":
+		return func(s *State) int { return b(x(s) != 0 && y(s) != 0) }
+	case "==":
+		return func(s *State) int { return b(x(s) == y(s)) }
+	case "!=":
+		return func(s *State) int { return b(x(s) != y(s)) }
+	case "<":
+		return func(s *State) int { return b(x(s) < y(s)) }
+	case "<=":
+		return func(s *State) int { return b(x(s) <= y(s)) }
+	case ">":
+		return func(s *State) int { return b(x(s) > y(s)) }
+	case ">=":
+		return func(s *State) int { return b(x(s) >= y(s)) }
+	case "+":
+		return func(s *State) int { return x(s) + y(s) }
+	case "-":
+		return func(s *State) int { return x(s) - y(s) }
+	case "|":
+		return func(s *State) int { return x(s) | y(s) }
+	case "^":
+		return func(s *State) int { return x(s) ^ y(s) }
+	case "*":
+		return func(s *State) int { return x(s) * y(s) }
+	case "/":
+		return func(s *State) int {
+			if d := y(s); d != 0 {
+				return x(s) / d
+			}
+			return 0
+		}
+	case "&":
+		return func(s *State) int { return x(s) & y(s) }
+	case "<<":
+		return func(s *State) int { return x(s) << uint(y(s)&63) }
+	}
+	return func(s *State) int { return x(s) >> uint(y(s)&63) }
+}
+
+func (p *exprParser) unary() (func(*State) int, error) {
+	switch op := p.peek(); op {
+	case "-", "!", "~":
+		p.pos++
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "-":
+			return func(s *State) int { return -x(s) }, nil
+		case "!":
+			return func(s *State) int {
+				if x(s) == 0 {
+					return 1
+				}
+				return 0
+			}, nil
+		}
+		return func(s *State) int { return ^x(s) }, nil
+	}
+	return p.operand()
+}
+
+func (p *exprParser) operand() (func(*State) int, error) {
+	t := p.peek()
+	p.pos++
+	switch {
+	case t == "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case t == "(":
+		x, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return x, nil
+	case t[0] == '$' || t[0] == '%' || unicode.IsDigit(rune(t[0])):
+		base, digits := 10, t
+		switch t[0] {
+		case '$':
+			base, digits = 16, t[1:]
+		case '%':
+			base, digits = 2, t[1:]
+		}
+		v, err := strconv.ParseInt(digits, base, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", t)
+		}
+		return func(*State) int { return int(v) }, nil
+	case strings.EqualFold(t, "MEM"):
+		if p.peek() != "[" {
+			return nil, fmt.Errorf("expecting [ after %s", t)
+		}
+		p.pos++
+		addr, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != "]" {
+			return nil, fmt.Errorf("missing ]")
+		}
+		p.pos++
+		return func(s *State) int { return int(s.MEM[addr(s)&0x0f]) }, nil
+	}
+	if !unicode.IsLetter(rune(t[0])) && t[0] != '_' {
+		return nil, fmt.Errorf("unexpected %s", t)
+	}
+	if f := stateValue(strings.ToUpper(t)); f != nil {
+		return f, nil
+	}
+	if v, ok := p.symbols[t]; ok {
+		return func(*State) int { return int(v) }, nil
+	}
+	return nil, fmt.Errorf("unknown name %s", t)
+}
+
+// stateValue returns the function reading the part of the cpu called name, or
+// nil.
+func stateValue(name string) func(*State) int {
+	b := func(v bool) int {
+		if v {
+			return 1
+		}
+		return 0
+	}
+	switch name {
+	case "A", "AREG":
+		return func(s *State) int { return int(s.A) }
+	case "B", "BREG":
+		return func(s *State) int { return int(s.B) }
+	case "OUT", "OREG":
+		return func(s *State) int { return int(s.Out) }
+	case "IR":
+		return func(s *State) int { return int(s.IR) }
+	case "MAR":
+		return func(s *State) int { return int(s.MAR) }
+	case "PC":
+		return func(s *State) int { return int(s.PC) }
+	case "ALU":
+		return func(s *State) int { return int(s.ALU) }
+	case "BUS":
+		return func(s *State) int { return int(s.BUS) }
+	case "STEP":
+		return func(s *State) int { return int(s.Step) }
+	case "CYCLES":
+		return func(s *State) int { return int(s.Cycles) }
+	case "CLK":
+		return func(s *State) int { return b(s.CLK) }
+	case "CF":
+		return func(s *State) int { return b(s.CF) }
+	case "ZF":
+		return func(s *State) int { return b(s.ZF) }
+	}
+	if sig := controlByName(name); sig != 0 {
+		return func(s *State) int { return b(s.Control&sig != 0) }
+	}
+	return nil
+}